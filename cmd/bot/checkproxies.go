@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"text/tabwriter"
+	"time"
+	"visasolution/internal/proxycheck"
+	"visasolution/internal/worker"
+)
+
+const defaultProxyCheckTimeout = 15 * time.Second
+
+// checkProxiesCmd проверяет все прокси из proxies.json перед запуском бота.
+// Завершается с ошибкой, если не осталось ни одного рабочего российского прокси или не работает иностранный.
+// Пример: bot check-proxies -target https://example.com -json
+func checkProxiesCmd(args []string) error {
+	fs := flag.NewFlagSet("check-proxies", flag.ContinueOnError)
+	proxiesPath := fs.String("proxies", configPath(proxiesFilename), "path to proxies file")
+	target := fs.String("target", baseURL, "URL requested through each proxy")
+	timeout := fs.Duration("timeout", defaultProxyCheckTimeout, "timeout of a single proxy check")
+	asJSON := fs.Bool("json", false, "print results as JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	proxiesManager, err := worker.LoadProxies(*proxiesPath)
+	if err != nil {
+		return err
+	}
+
+	checker := proxycheck.NewChecker(*target, *timeout)
+	results := checker.CheckAll(context.Background(), proxycheck.Targets(proxiesManager))
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(results); err != nil {
+			return err
+		}
+	} else {
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "KIND\tPROXY\tSTATUS\tCODE\tLATENCY\tERROR")
+		for _, r := range results {
+			fmt.Fprintf(tw, "%s\t%s://%s\t%s\t%d\t%s\t%s\n",
+				r.Kind, r.Scheme, net.JoinHostPort(r.Host, r.Port), r.Status, r.StatusCode, r.Latency.Round(time.Millisecond), r.Error)
+		}
+		tw.Flush()
+	}
+
+	return proxycheck.CheckUsable(results)
+}
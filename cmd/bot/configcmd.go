@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	cfg "visasolution/internal/config"
+	"visasolution/internal/worker"
+)
+
+const redactedValue = "***"
+
+// configCmd подкоманды для работы с конфигурацией.
+// Пример: bot config print --redacted
+func configCmd(args []string) error {
+	if len(args) == 0 || args[0] != "print" {
+		return errors.New("usage: config print [-file path] [-redacted]")
+	}
+
+	return configPrintCmd(args[1:])
+}
+
+// configPrintCmd выводит итоговую конфигурацию с источником каждого значения (default, file, env)
+// и завершается с ошибкой, если конфигурация некорректна
+func configPrintCmd(args []string) error {
+	fs := flag.NewFlagSet("config print", flag.ContinueOnError)
+	filePath := fs.String("file", configFile(), "path to config file (.env, .yaml, .toml)")
+	redacted := fs.Bool("redacted", false, "hide secret values")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	values, err := cfg.ReadConfigFile(*filePath)
+	if err != nil {
+		return err
+	}
+
+	// Профиль из конфигурации нужен только без файла профилей, некорректный файл - ошибка
+	_, profilesErr := worker.LoadProfiles(configPath(profilesFilename))
+	needDefaultProfile := errors.Is(profilesErr, os.ErrNotExist)
+	if profilesErr != nil && !needDefaultProfile {
+		return profilesErr
+	}
+
+	_, settings, _ := cfg.Resolve(values)
+	if err := printSettings(os.Stdout, settings, *redacted); err != nil {
+		return err
+	}
+
+	_, err = cfg.ParseConfigValues(values, needDefaultProfile)
+	return err
+}
+
+// printSettings выводит параметры конфигурации таблицей. redacted - значения секретов заменяются на "***"
+func printSettings(w io.Writer, settings []cfg.Setting, redacted bool) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "KEY\tVALUE\tSOURCE")
+	for _, s := range settings {
+		value := s.Value
+		if redacted && s.Secret && value != "" {
+			value = redactedValue
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", s.Key, value, s.Source)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	cfg "visasolution/internal/config"
+)
+
+func TestPrintSettings(t *testing.T) {
+	settings := []cfg.Setting{
+		{Key: "SMTP_HOST", Value: "smtp.example.com", Source: cfg.SourceFile},
+		{Key: "SMTP_PASSWORD", Value: "smtp-password", Source: cfg.SourceEnv, Secret: true},
+		{Key: "HTTP_TOKEN", Value: "", Source: cfg.SourceDefault, Secret: true},
+	}
+
+	tests := []struct {
+		name     string
+		redacted bool
+		want     []string
+		notWant  []string
+	}{
+		{
+			name:     "redacted",
+			redacted: true,
+			want:     []string{"smtp.example.com", "SMTP_PASSWORD  ***"},
+			notWant:  []string{"smtp-password", "HTTP_TOKEN     ***"},
+		},
+		{
+			name:    "plain",
+			want:    []string{"smtp.example.com", "smtp-password"},
+			notWant: []string{"***"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+			if err := printSettings(&out, settings, tt.redacted); err != nil {
+				t.Fatalf("printSettings() error: %v", err)
+			}
+
+			for _, s := range tt.want {
+				if !strings.Contains(out.String(), s) {
+					t.Errorf("output does not contain %q:\n%s", s, out.String())
+				}
+			}
+			for _, s := range tt.notWant {
+				if strings.Contains(out.String(), s) {
+					t.Errorf("output contains %q:\n%s", s, out.String())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"visasolution/internal/history"
+	"visasolution/internal/service"
+)
+
+const defaultHistoryLimit = 20
+
+// historyCmd выводит историю выполнений бота с фильтрацией.
+// Пример: bot history -available -since 72h -proxy 1.2.3.4
+func historyCmd(args []string) error {
+	fs := flag.NewFlagSet("history", flag.ContinueOnError)
+	dbPath := fs.String("db", path.Join(logFolder, historyFilename), "path to history database")
+	profile := fs.String("profile", "", "show only runs of the profile")
+	proxyHost := fs.String("proxy", "", "show only runs through the proxy host")
+	since := fs.Duration("since", 0, "show only runs started within the duration, e.g. 24h")
+	onlyAvailable := fs.Bool("available", false, "show only runs where appointments were available")
+	onlyErrors := fs.Bool("errors", false, "show only failed runs")
+	limit := fs.Int("limit", defaultHistoryLimit, "max number of runs to show, 0 - no limit")
+	showSlots := fs.Bool("slots", false, "print open dates and time slots of each run")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(*dbPath); err != nil {
+		return fmt.Errorf("history database not found: %w", err)
+	}
+
+	store, err := history.Open(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	filter := history.Filter{
+		Profile:       *profile,
+		ProxyHost:     *proxyHost,
+		OnlyAvailable: *onlyAvailable,
+		OnlyErrors:    *onlyErrors,
+		Limit:         *limit,
+	}
+	if *since > 0 {
+		filter.Since = time.Now().Add(-*since)
+	}
+
+	runs, err := store.List(filter)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tPROFILE\tSTARTED\tDURATION\tPROXY\tAUTH\tCAPTCHA\tAVAILABLE\tSLOTS\tERROR")
+	for _, r := range runs {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\t%d/%d (invalid %d)\t%s\t%d\t%s\n",
+			r.ID,
+			r.Profile,
+			r.StartedAt.Format(time.DateTime),
+			r.FinishedAt.Sub(r.StartedAt),
+			r.ProxyHost,
+			yesNo(r.AuthorizationNeeded),
+			r.CaptchaSolved, r.CaptchaAttempts, r.CaptchaInvalid,
+			availability(r),
+			service.CountSlots(r.Days),
+			r.ErrorClass,
+		)
+
+		if *showSlots {
+			for _, day := range r.Days {
+				fmt.Fprintf(tw, "\t%s\t%s\n", day.Date.Format(time.DateOnly), strings.Join(day.Slots, ", "))
+			}
+		}
+	}
+
+	return tw.Flush()
+}
+
+func yesNo(v bool) string {
+	if v {
+		return "yes"
+	}
+	return "no"
+}
+
+// availability возвращает результат проверки доступности записи или "-", если проверка не выполнялась
+func availability(r history.Run) string {
+	if !r.AvailabilityChecked {
+		return "-"
+	}
+	return yesNo(r.Available)
+}
@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"log"
@@ -9,9 +11,15 @@ import (
 	"os/signal"
 	"path"
 	"syscall"
+	"time"
+	"visasolution/internal/api"
 	"visasolution/internal/app"
 
 	cfg "visasolution/internal/config"
+	"visasolution/internal/history"
+	"visasolution/internal/notify"
+	"visasolution/internal/pages"
+	"visasolution/internal/replay"
 	"visasolution/internal/service"
 	"visasolution/internal/worker"
 )
@@ -22,11 +30,15 @@ const (
 )
 
 const (
-	proxiesFilePath    = "./proxies.json"
+	configFilename     = ".env"
+	proxiesFilename    = "proxies.json"
+	profilesFilename   = "profiles.json"
 	logFolder          = "logs/"
 	logFilename        = "app.log"
+	historyFilename    = "history.db"
+	notifyStateFile    = "notify_state.json"
+	proxyHealthFile    = "proxy_health.json"
 	tmpFolder          = "tmp/"
-	cookieFilename     = "cookies.json"
 	screenshotFilename = "screenshot.png"
 )
 
@@ -35,7 +47,20 @@ const (
 	processCaptchaMaxTries = 5
 )
 
+// commands подкоманды бота. Без подкоманды запускается основной цикл
+var commands = map[string]func(args []string) error{
+	"history":       historyCmd,
+	"check-proxies": checkProxiesCmd,
+	"config":        configCmd,
+	"selectors":     selectorsCmd,
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		runCommand(os.Args[1], os.Args[2:])
+		return
+	}
+
 	logFile, err := setupLogger()
 	if err != nil {
 		log.Fatalln("Failed to setup logger:", err)
@@ -46,80 +71,254 @@ func main() {
 	ctx, cancel := setupSignalHandler()
 	defer cancel()
 
-	config, err := cfg.LoadConfig()
+	profilesFilePath := configPath(profilesFilename)
+	profiles, err := worker.LoadProfiles(profilesFilePath)
+	if errors.Is(err, os.ErrNotExist) {
+		log.Println("Profiles file not found, using profile from env:", profilesFilePath)
+		profilesFilePath = ""
+	} else if err != nil {
+		log.Fatalln("Failed to load profiles from JSON:", err)
+	}
+
+	configFilePath := configFile()
+	config, err := cfg.LoadConfig(configFilePath, profilesFilePath == "")
 	if err != nil {
 		log.Fatalln(err)
 	}
+	if profilesFilePath == "" {
+		profiles = []cfg.Profile{config.DefaultProfile()}
+	}
 
+	proxiesFilePath := configPath(proxiesFilename)
 	proxiesManager, err := worker.LoadProxies(proxiesFilePath)
 	if err != nil {
-		log.Println("Failed to load proxies from JSON:", err)
+		log.Fatalln("Failed to load proxies from JSON:", err)
+	}
+	if len(proxiesManager.ProxiesRU()) == 0 {
+		log.Fatalln("No russian proxies found in", proxiesFilePath)
+	}
+
+	err = proxiesManager.LoadHealth(path.Join(logFolder, proxyHealthFile))
+	if err != nil {
+		log.Println("Failed to load proxy health, starting with empty state:", err)
+	}
+	log.Println("Starting with proxy:", proxiesManager.SelectBestRU().Host)
+
+	sitePages, err := loadPages(config.SelectorsFile)
+	if err != nil {
+		log.Fatalln("Failed to load selector pack:", err)
+	}
+	log.Println("Selector pack:", sitePages.Pack)
+
+	var recorder *replay.Recorder
+	if config.RecordDir != "" {
+		recorder = replay.NewRecorder(config.RecordDir)
+		log.Println("Page recording enabled:", config.RecordDir)
 	}
 
 	services := service.NewService(service.Deps{
 		SeleniumURL:       config.SeleniumUrl,
 		BaseURL:           baseURL,
 		MaxTries:          connectionMaxTries,
-		BlsEmail:          config.BlsEmail,
-		BlsPassword:       config.BlsPassword,
+		Pages:             sitePages,
+		Recorder:          recorder,
 		ChatApiKey:        config.ChatApiKey,
+		CaptchaSolver:     config.CaptchaSolver,
+		ImgurFallback:     config.ImgurFallback,
 		ImgurClientId:     config.ImgurClientId,
 		ImgurClientSecret: config.ImgurClientSecret,
 		EmailDeps: service.EmailDeps{
-			Host:               config.SmtpHost,
-			Port:               config.SmtpPort,
-			Username:           config.SmtpUsername,
-			Password:           config.Password,
-			ScreenshotFilePath: path.Join(tmpFolder, screenshotFilename),
+			Host:     config.SmtpHost,
+			Port:     config.SmtpPort,
+			Username: config.SmtpUsername,
+			Password: config.SmtpPassword,
+		},
+		TelegramDeps: service.TelegramDeps{
+			Token:   config.TelegramBotToken,
+			BaseURL: config.TelegramBaseURL,
+			ChatIDs: config.TelegramChatIDs,
 		},
 	})
 
-	workers := worker.NewWorker(services, worker.Deps{
-		BaseURL:         baseURL,
-		VisaTypeURL:     visaTypeVerificationURL,
-		TmpFolder:       tmpFolder,
-		CookieFile:      cookieFilename,
-		NotifiedEmail:   config.NotifiedEmail,
-		CaptchaMaxTries: processCaptchaMaxTries,
-		ScreenshotFile:  screenshotFilename,
-	})
-
-	err = workers.MakePreparation()
+	sessionKey, err := config.SessionKeyBytes()
 	if err != nil {
-		log.Fatalln("Make preparation error:", err)
+		log.Fatalln("Session key error:", err)
 	}
 
-	err = services.Chat.ClientInitWithProxy(proxiesManager.ProxyForeign)
-	if err != nil {
-		log.Fatalln("Chat client init error:", err)
+	workers := make([]*worker.Worker, 0, len(profiles))
+	for _, profile := range profiles {
+		w := worker.NewWorker(services, worker.Deps{
+			BaseURL:         baseURL,
+			VisaTypeURL:     visaTypeVerificationURL,
+			TmpFolder:       path.Join(tmpFolder, profile.Name),
+			ScreenshotFile:  screenshotFilename,
+			ExtensionFolder: tmpFolder,
+			Profile:         profile,
+			CaptchaMaxTries: processCaptchaMaxTries,
+
+			CurrentProxy:         proxiesManager.CurrentRU,
+			SessionTTL:           config.SessionTTL,
+			SessionRefreshBefore: config.SessionRefreshBefore,
+			SessionKey:           sessionKey,
+
+			Recorder: recorder,
+		})
+
+		err = w.MakePreparation()
+		if err != nil {
+			log.Fatalln("Make preparation error:", err)
+		}
+
+		workers = append(workers, w)
 	}
-	log.Println("Chat API client initialized")
+	log.Println("Profiles loaded:", len(workers))
 
-	err = services.Image.ClientInitWithProxy(proxiesManager.CurrentRU())
-	if err != nil {
-		log.Fatalln("Image client init error:", err)
+	// Клиент Chat API нужен только для решения капчи через ChatGPT, клиент Imgur - только как запасной вариант
+	if config.CaptchaSolver == cfg.CaptchaSolverGPT {
+		err = services.Chat.ClientInitWithProxy(proxiesManager.ProxyForeign)
+		if err != nil {
+			log.Fatalln("Chat client init error:", err)
+		}
+		log.Println("Chat API client initialized")
+
+		if config.ImgurFallback {
+			err = services.Image.ClientInitWithProxy(proxiesManager.CurrentRU())
+			if err != nil {
+				log.Fatalln("Image client init error:", err)
+			}
+			log.Println("Image API client initialized")
+		}
+	} else {
+		log.Println("Captcha solver:", config.CaptchaSolver)
 	}
-	log.Println("Image API client initialized")
 
-	err = workers.ConnectGeneratedProxy(services.Selenium, proxiesManager.CurrentRU())
+	if services.Telegram != nil {
+		err = services.Telegram.ClientInitWithProxy(proxiesManager.ProxyForeign)
+		if err != nil {
+			log.Fatalln("Telegram client init error:", err)
+		}
+		log.Println("Telegram client initialized")
+	}
+
+	err = workers[0].ConnectGeneratedProxy(services.Selenium, proxiesManager.CurrentRU())
 	if err != nil {
 		log.Fatalln("Web driver connection error:", err)
 	}
 	log.Println("Web driver connected with proxy:", proxiesManager.CurrentRU().Host)
 	defer services.Quit()
-	defer workers.SaveCookies()
+
+	historyStore, err := history.Open(path.Join(logFolder, historyFilename))
+	if err != nil {
+		log.Println("Failed to open run history, history will not be saved:", err)
+	} else {
+		defer historyStore.Close()
+	}
+
+	notifyPolicy, err := notify.NewPolicy(services.Notifier, path.Join(logFolder, notifyStateFile), config.DigestHour)
+	if err != nil {
+		log.Fatalln("Notify policy init error:", err)
+	}
+
+	// Файл конфигурации необязателен: если его нет, конфигурация задана только переменными окружения
+	if _, err := os.Stat(configFilePath); err != nil {
+		configFilePath = ""
+	}
+	reloader, err := app.NewReloader(app.ReloadDeps{
+		ConfigFile:   configFilePath,
+		ProxiesFile:  proxiesFilePath,
+		ProfilesFile: profilesFilePath,
+	})
+	if err != nil {
+		log.Println("Failed to watch config files, hot reload disabled:", err)
+	}
+
+	controller := app.NewController(proxiesManager)
+
+	server := api.NewServer(config.HttpAddr, config.HttpToken, controller)
+	if err := server.Listen(); err != nil {
+		log.Fatalln("HTTP API listen error:", err)
+	}
+	log.Println("HTTP API listening on", server.Addr())
+	go func() {
+		if err := server.Run(ctx); err != nil {
+			log.Println("HTTP API error:", err)
+		}
+	}()
+
+	sched, err := config.Schedule()
+	if err != nil {
+		log.Fatalln("Schedule error:", err)
+	}
+	log.Println("Schedule:", sched)
+	log.Println("Next planned run after the first one:", sched.Next(time.Now()).Format(time.DateTime))
 
 	app.RunMainLoop(ctx, app.MainLoopDeps{
 		Workers:        workers,
 		Services:       services,
 		Config:         config,
 		ProxiesManager: proxiesManager,
-	}, config.MainLoopIntervalM)
+		History:        historyStore,
+		Controller:     controller,
+		NotifyPolicy:   notifyPolicy,
+		Reloader:       reloader,
+	})
 
 	<-ctx.Done()
 	log.Println("App stopped gracefully")
 }
 
+// configPath возвращает путь к файлу конфигурации в директории CONFIG_DIR (по умолчанию текущая директория).
+// В Docker директория подключается как том, чтобы изменения файлов применялись без пересборки образа
+func configPath(filename string) string {
+	configDir := os.Getenv("CONFIG_DIR")
+	if configDir == "" {
+		configDir = "."
+	}
+	return path.Join(configDir, filename)
+}
+
+// configFile возвращает путь к файлу конфигурации: CONFIG_FILE или .env в директории CONFIG_DIR
+func configFile() string {
+	if filePath := os.Getenv("CONFIG_FILE"); filePath != "" {
+		return filePath
+	}
+	return configPath(configFilename)
+}
+
+// loadPages создает объекты страниц по набору селекторов из файла filePath или по встроенному набору, если путь пустой
+func loadPages(filePath string) (*pages.Pages, error) {
+	pack, err := loadPack(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return pages.New(pack)
+}
+
+func loadPack(filePath string) (*pages.Pack, error) {
+	if filePath == "" {
+		return pages.DefaultPack(), nil
+	}
+	return pages.LoadPack(filePath)
+}
+
+// runCommand выполняет подкоманду name и завершает процесс с ненулевым кодом в случае ошибки
+func runCommand(name string, args []string) {
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command '%s'\n", name)
+		os.Exit(2)
+	}
+
+	err := cmd(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
 func setupSignalHandler() (context.Context, context.CancelFunc) {
 	ctx, cancel := context.WithCancel(context.Background())
 	signalChan := make(chan os.Signal, 1)
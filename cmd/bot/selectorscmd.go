@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	cfg "visasolution/internal/config"
+	"visasolution/internal/pages"
+)
+
+const fixturesFolder = "fixtures"
+
+// selectorsCmd подкоманды для работы с наборами селекторов.
+// Пример: bot selectors validate -pack config/selectors.yaml -fixtures config/fixtures
+func selectorsCmd(args []string) error {
+	if len(args) == 0 || args[0] != "validate" {
+		return errors.New("usage: selectors validate [-pack path] [-fixtures dir] [-json]")
+	}
+
+	return selectorsValidateCmd(args[1:])
+}
+
+// selectorsValidateCmd проверяет набор селекторов по сохраненным HTML страницам сайта.
+// Завершается с ошибкой, если набор некорректен или элемент не найден ни по одному селектору
+func selectorsValidateCmd(args []string) error {
+	fs := flag.NewFlagSet("selectors validate", flag.ContinueOnError)
+	packPath := fs.String("pack", defaultSelectorsFile(), "path to selector pack (.yaml, .yml, .json), empty for built-in pack")
+	fixturesDir := fs.String("fixtures", configPath(fixturesFolder), "directory with saved HTML pages (<page>.html)")
+	asJSON := fs.Bool("json", false, "print results as JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	pack, err := loadPack(*packPath)
+	if err != nil {
+		return err
+	}
+
+	results, err := pages.ValidateFixtures(pack, *fixturesDir)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(results); err != nil {
+			return err
+		}
+	} else {
+		fmt.Println("Selector pack:", pack)
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "PAGE\tELEMENT\tSTATUS\tSELECTOR\tERROR")
+		for _, r := range results {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", r.Page, r.Element, r.Status, r.Selector, r.Error)
+		}
+		tw.Flush()
+	}
+
+	return pages.CheckFixtures(results)
+}
+
+// defaultSelectorsFile возвращает SELECTORS_FILE из конфигурации (файл и переменные окружения)
+func defaultSelectorsFile() string {
+	values, err := cfg.ReadConfigFile(configFile())
+	if err != nil {
+		return ""
+	}
+
+	config, _, _ := cfg.Resolve(values)
+	return config.SelectorsFile
+}
@@ -0,0 +1,184 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"time"
+	"visasolution/internal/app"
+	"visasolution/internal/metrics"
+)
+
+const shutdownTimeout = 5 * time.Second
+
+// Server HTTP API для получения состояния бота и управления основным циклом.
+//
+// Эндпоинты:
+// - GET /status: состояние основного цикла
+// - POST /run-now: немедленный запуск итерации основного цикла
+// - POST /pause, POST /resume: пауза и снятие с паузы основного цикла
+// - GET /screenshot: последний скриншот страницы в формате PNG
+// - GET /metrics: метрики в текстовом формате Prometheus
+type Server struct {
+	ctl   *app.Controller
+	token string
+	srv   *http.Server
+	// ln слушающий сокет, создается в Listen
+	ln net.Listener
+}
+
+// NewServer создает сервер, слушающий адрес addr.
+// Если token не пустой, каждый запрос должен содержать заголовок "Authorization: Bearer <token>"
+func NewServer(addr, token string, ctl *app.Controller) *Server {
+	s := &Server{ctl: ctl, token: token}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", s.method(http.MethodGet, s.handleStatus))
+	mux.HandleFunc("/run-now", s.method(http.MethodPost, s.handleRunNow))
+	mux.HandleFunc("/pause", s.method(http.MethodPost, s.handlePause))
+	mux.HandleFunc("/resume", s.method(http.MethodPost, s.handleResume))
+	mux.HandleFunc("/screenshot", s.method(http.MethodGet, s.handleScreenshot))
+	mux.Handle("/metrics", metrics.Handler())
+
+	s.srv = &http.Server{
+		Addr:              addr,
+		Handler:           s.auth(mux),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	return s
+}
+
+// Listen занимает адрес сервера. Вызывается перед Run, чтобы ошибка занятого порта была видна при запуске
+func (s *Server) Listen() error {
+	ln, err := net.Listen("tcp", s.srv.Addr)
+	if err != nil {
+		return err
+	}
+
+	s.ln = ln
+	return nil
+}
+
+// Addr возвращает адрес, который слушает сервер после Listen (с выбранным портом, если в адресе был порт 0)
+func (s *Server) Addr() string {
+	if s.ln == nil {
+		return s.srv.Addr
+	}
+	return s.ln.Addr().String()
+}
+
+// Run запускает сервер и блокируется до отмены контекста. Если Listen не был вызван, адрес занимается здесь
+func (s *Server) Run(ctx context.Context) error {
+	if s.ln == nil {
+		if err := s.Listen(); err != nil {
+			return err
+		}
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- s.srv.Serve(s.ln)
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	err := s.srv.Shutdown(shutdownCtx)
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, s.ctl.Status())
+}
+
+func (s *Server) handleRunNow(w http.ResponseWriter, _ *http.Request) {
+	s.ctl.RunNow()
+	log.Println("API: run now requested")
+	writeJSON(w, http.StatusAccepted, s.ctl.Status())
+}
+
+func (s *Server) handlePause(w http.ResponseWriter, _ *http.Request) {
+	s.ctl.Pause()
+	log.Println("API: pause requested")
+	writeJSON(w, http.StatusOK, s.ctl.Status())
+}
+
+func (s *Server) handleResume(w http.ResponseWriter, _ *http.Request) {
+	s.ctl.Resume()
+	log.Println("API: resume requested")
+	writeJSON(w, http.StatusOK, s.ctl.Status())
+}
+
+func (s *Server) handleScreenshot(w http.ResponseWriter, _ *http.Request) {
+	last := s.ctl.LastWorker()
+	if last == nil {
+		writeError(w, http.StatusNotFound, "no runs yet")
+		return
+	}
+
+	img, err := last.LastScreenshot()
+	if err != nil {
+		writeError(w, http.StatusNotFound, "screenshot not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.WriteHeader(http.StatusOK)
+	w.Write(img)
+}
+
+// method ограничивает обработчик одним HTTP методом
+func (s *Server) method(method string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			w.Header().Set("Allow", method)
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// auth проверяет bearer токен, если он задан
+func (s *Server) auth(next http.Handler) http.Handler {
+	if s.token == "" {
+		return next
+	}
+
+	expected := []byte("Bearer " + s.token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got := []byte(r.Header.Get("Authorization"))
+		if subtle.ConstantTimeCompare(got, expected) != 1 {
+			writeError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Println("API: write response error:", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
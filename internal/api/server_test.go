@@ -0,0 +1,149 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"visasolution/internal/app"
+	"visasolution/internal/worker"
+)
+
+const testToken = "secret-token"
+
+func serve(s *Server, method, path, authorization string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	rec := httptest.NewRecorder()
+	s.srv.Handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestServerAuth(t *testing.T) {
+	tests := []struct {
+		name          string
+		token         string
+		authorization string
+		want          int
+	}{
+		{name: "missing token", token: testToken, want: http.StatusUnauthorized},
+		{name: "wrong token", token: testToken, authorization: "Bearer wrong", want: http.StatusUnauthorized},
+		{name: "token without scheme", token: testToken, authorization: testToken, want: http.StatusUnauthorized},
+		{name: "valid token", token: testToken, authorization: "Bearer " + testToken, want: http.StatusOK},
+		{name: "auth disabled", want: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewServer("127.0.0.1:0", tt.token, app.NewController(nil))
+
+			for _, path := range []string{"/status", "/metrics"} {
+				rec := serve(s, http.MethodGet, path, tt.authorization)
+				if rec.Code != tt.want {
+					t.Errorf("GET %s status = %d, want %d", path, rec.Code, tt.want)
+				}
+			}
+		})
+	}
+
+	// Без токена команды управления не выполняются
+	ctl := app.NewController(nil)
+	s := NewServer("127.0.0.1:0", testToken, ctl)
+	if rec := serve(s, http.MethodPost, "/pause", "Bearer wrong"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("POST /pause with wrong token status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+	if ctl.Paused() {
+		t.Error("main loop paused by unauthorized request")
+	}
+}
+
+func TestServerMethods(t *testing.T) {
+	tests := []struct {
+		method string
+		path   string
+		want   int
+		// wantAllow заголовок Allow ответа 405
+		wantAllow string
+	}{
+		{method: http.MethodGet, path: "/pause", want: http.StatusMethodNotAllowed, wantAllow: http.MethodPost},
+		{method: http.MethodGet, path: "/resume", want: http.StatusMethodNotAllowed, wantAllow: http.MethodPost},
+		{method: http.MethodGet, path: "/run-now", want: http.StatusMethodNotAllowed, wantAllow: http.MethodPost},
+		{method: http.MethodPost, path: "/status", want: http.StatusMethodNotAllowed, wantAllow: http.MethodGet},
+		{method: http.MethodPost, path: "/pause", want: http.StatusOK},
+		{method: http.MethodPost, path: "/resume", want: http.StatusOK},
+		{method: http.MethodPost, path: "/run-now", want: http.StatusAccepted},
+		{method: http.MethodGet, path: "/screenshot", want: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			ctl := app.NewController(nil)
+			s := NewServer("127.0.0.1:0", testToken, ctl)
+
+			rec := serve(s, tt.method, tt.path, "Bearer "+testToken)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if got := rec.Header().Get("Allow"); got != tt.wantAllow {
+				t.Errorf("Allow = %q, want %q", got, tt.wantAllow)
+			}
+			// Отклоненный запрос не меняет состояние основного цикла
+			if tt.path == "/pause" && ctl.Paused() != (tt.want == http.StatusOK) {
+				t.Errorf("paused = %v after %s %s", ctl.Paused(), tt.method, tt.path)
+			}
+		})
+	}
+}
+
+func TestServerStatus(t *testing.T) {
+	ctl := app.NewController(nil)
+	s := NewServer("127.0.0.1:0", "", ctl)
+
+	status := func(method, path string, want int) map[string]any {
+		t.Helper()
+
+		rec := serve(s, method, path, "")
+		if rec.Code != want {
+			t.Fatalf("%s %s status = %d, want %d", method, path, rec.Code, want)
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("%s %s Content-Type = %q, want application/json", method, path, ct)
+		}
+
+		var body map[string]any
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("%s %s invalid json %q: %v", method, path, rec.Body.String(), err)
+		}
+		return body
+	}
+
+	body := status(http.MethodGet, "/status", http.StatusOK)
+	want := map[string]any{"paused": false, "running": false, "phase": worker.PhaseIdle}
+	for k, v := range want {
+		if body[k] != v {
+			t.Errorf("status %s = %v, want %v", k, body[k], v)
+		}
+	}
+	// Пустые необязательные поля не выводятся
+	for _, k := range []string{"profile", "proxy", "paused_reason", "last_result", "next_run_at"} {
+		if _, ok := body[k]; ok {
+			t.Errorf("status contains empty field %s", k)
+		}
+	}
+
+	if body := status(http.MethodPost, "/pause", http.StatusOK); body["paused"] != true {
+		t.Errorf("pause response paused = %v, want true", body["paused"])
+	}
+	if body := status(http.MethodGet, "/status", http.StatusOK); body["paused"] != true {
+		t.Errorf("status after pause paused = %v, want true", body["paused"])
+	}
+	if body := status(http.MethodPost, "/resume", http.StatusOK); body["paused"] != false {
+		t.Errorf("resume response paused = %v, want false", body["paused"])
+	}
+
+	if body := status(http.MethodGet, "/screenshot", http.StatusNotFound); body["error"] != "no runs yet" {
+		t.Errorf("screenshot error = %v, want %q", body["error"], "no runs yet")
+	}
+}
@@ -2,66 +2,241 @@ package app
 
 import (
 	"context"
-	"errors"
+	"fmt"
 	"log"
 	"time"
+	"visasolution/internal/apperr"
 	"visasolution/internal/config"
+	"visasolution/internal/history"
+	"visasolution/internal/metrics"
+	"visasolution/internal/notify"
 	"visasolution/internal/service"
 	"visasolution/internal/worker"
 )
 
 // MainLoopDeps структура для зависимостей основного цикла
 type MainLoopDeps struct {
-	Workers        *worker.Worker
+	// Workers воркеры для каждого профиля заявителя. Выполняются последовательно, т.к. используют общий веб-драйвер
+	Workers        []*worker.Worker
 	Services       *service.Service
 	Config         *config.Config
 	ProxiesManager *config.ProxiesManager
+	// History хранилище истории выполнений. Может быть nil, тогда история не сохраняется
+	History *history.Store
+	// Controller управление основным циклом. Если nil, создается автоматически
+	Controller *Controller
+	// NotifyPolicy решает, когда отправлять уведомления. Может быть nil, тогда уведомления не отправляются
+	NotifyPolicy *notify.Policy
+	// Reloader применяет изменения файлов конфигурации между итерациями. Может быть nil, тогда конфигурация не перечитывается
+	Reloader *Reloader
+	// RetryPolicy задержки после ошибок и автоматический выключатель. Если nil, создается с DefaultBackoffs
+	RetryPolicy *RetryPolicy
 }
 
-// RunMainLoop основной цикл приложения
-func RunMainLoop(ctx context.Context, deps MainLoopDeps, interval int) {
+// RunMainLoop основной цикл приложения.
+// Время следующей итерации определяется расписанием из конфигурации, при ее перезагрузке расписание обновляется
+func RunMainLoop(ctx context.Context, deps MainLoopDeps) {
+	if deps.Controller == nil {
+		deps.Controller = NewController(deps.ProxiesManager)
+	}
+	ctl := deps.Controller
+	if deps.RetryPolicy == nil {
+		deps.RetryPolicy = NewRetryPolicy(DefaultBackoffs, deps.Config.BreakerThreshold)
+	}
+
+	sched, err := deps.Config.Schedule()
+	if err != nil {
+		log.Println("Invalid schedule, stopping main loop:", err)
+		return
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
 			log.Println("Context canceled, stopping main loop...")
 			return
 		default:
-			runErr := deps.Workers.Run()
+			if deps.Reloader != nil {
+				deps.Reloader.Reload(&deps)
+				if s, err := deps.Config.Schedule(); err == nil {
+					sched = s
+				}
+			}
 
-			shouldRestart := handleRunError(runErr, deps)
-			if shouldRestart {
-				log.Println("Restarting main loop...")
-				continue
+			if !runWorkers(ctx, deps) {
+				log.Println("Context canceled, stopping main loop...")
+				return
 			}
 
-			log.Println("Waiting for", interval, "minutes...")
+			next := sched.Next(time.Now())
+			log.Println("Next run at", next.Format(time.DateTime), "in", time.Until(next).Round(time.Second))
+			ctl.scheduled(next)
 
 			select {
 			case <-ctx.Done():
 				log.Println("Context canceled, stopping main loop...")
 				return
-			case <-time.After(time.Duration(interval) * time.Minute):
+			case <-ctl.runNowCh:
+				log.Println("Run now requested")
+				continue
+			case <-time.After(time.Until(next)):
+			}
+
+			if !waitResume(ctx, ctl) {
+				log.Println("Context canceled, stopping main loop...")
+				return
+			}
+		}
+	}
+}
+
+// waitResume ожидает снятия основного цикла с паузы или запроса на немедленный запуск.
+// Возвращает false, если контекст был отменен
+func waitResume(ctx context.Context, ctl *Controller) bool {
+	if !ctl.Paused() {
+		return true
+	}
+
+	log.Println("Main loop paused")
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ctl.resumeCh:
+			// Сигнал мог остаться от Resume, после которого цикл снова поставили на паузу
+			if ctl.Paused() {
+				continue
 			}
+			log.Println("Main loop resumed")
+		case <-ctl.runNowCh:
+			log.Println("Run now requested")
+		}
+
+		return true
+	}
+}
+
+// runWorkers последовательно запускает воркеры всех профилей.
+// После ошибки выполняется восстановление по ее классу (см. handleRunError), затем выполнение откладывается
+// на задержку из RetryPolicy. Если требуется перезапуск, воркер текущего профиля запускается повторно, иначе выполняется следующий.
+// После ошибки, которую нельзя исправить повтором, или слишком большого количества ошибок подряд у одного профиля
+// (в том числе ошибок одного класса, см. apperr.Policy.PauseAfter)
+// основной цикл ставится на паузу и отправляется уведомление.
+// Возвращает false, если контекст был отменен
+func runWorkers(ctx context.Context, deps MainLoopDeps) bool {
+	for i := 0; i < len(deps.Workers); {
+		if ctx.Err() != nil {
+			return false
+		}
+
+		w := deps.Workers[i]
+		deps.Controller.runStarted(w)
+		runErr := w.Run()
+		deps.Controller.runFinished(w, runErr)
+
+		recordRun(deps, w, runErr)
+		notifyRun(deps, w, runErr)
+		if runErr == nil {
+			deps.ProxiesManager.ReportSuccess(deps.ProxiesManager.CurrentRU(), w.LastReport().PageLoad)
+			deps.RetryPolicy.Success(w.Profile().Name)
+		}
+		metrics.Runs.WithLabelValues(w.Profile().Name, runOutcome(w, runErr)).Inc()
+
+		if runErr == nil {
+			i++
+			continue
+		}
+
+		class, policy := apperr.Classify(runErr)
+		if !policy.Retryable {
+			log.Printf("Main loop error (%s): %v\n", class, runErr)
+			deps.RetryPolicy.Reset()
+			tripBreaker(deps, w, fmt.Sprintf("non-retryable error (%s): %v", class, runErr))
+			return true
+		}
+
+		shouldRestart := handleRunError(runErr, w, deps)
+
+		failures := deps.RetryPolicy.Failures(w.Profile().Name) + 1
+		delay, tripped := deps.RetryPolicy.Failure(w.Profile().Name, class)
+		if tripped {
+			tripBreaker(deps, w, fmt.Sprintf("%d consecutive run errors, last (%s): %v", failures, class, runErr))
+			return true
+		}
+
+		metrics.RetryDelay.WithLabelValues(class).Observe(delay.Seconds())
+		log.Printf("Run error (%s), retrying in %s\n", class, delay)
+		if !sleep(ctx, delay) {
+			return false
+		}
+
+		if shouldRestart {
+			log.Println("Restarting run for profile:", w.Profile().Name)
+			continue
 		}
+
+		i++
+	}
+
+	return true
+}
+
+// tripBreaker ставит основной цикл на паузу по причине reason и уведомляет об этом.
+// Цикл продолжит работу после Resume или RunNow
+func tripBreaker(deps MainLoopDeps, w *worker.Worker, reason string) {
+	log.Println("Circuit breaker tripped, pausing main loop:", reason)
+	metrics.BreakerTrips.Inc()
+	deps.Controller.pauseWithReason(reason)
+
+	if deps.Services.Notifier == nil {
+		return
+	}
+	err := deps.Services.Notifier.Notify(service.Notification{
+		Kind:    service.NotificationAlert,
+		Profile: w.Profile().Name,
+		Email:   w.Profile().NotifiedEmail,
+		Text:    fmt.Sprintf("VisaSolution | %s: бот приостановлен, требуется вмешательство. Причина: %s", w.Profile().Name, reason),
+	})
+	if err != nil {
+		log.Println("Alert notification error:", err)
+	}
+}
+
+// sleep ожидает d или отмены контекста. Возвращает false, если контекст был отменен
+func sleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
 	}
 }
 
 // TODO: возврат еще и ошибки (обработать случай, когда не удалось переподключиться к Selenium)
-// handleRunError обработка ошибок в основном цикле
-// Возвращает true, если нужно перезапустить цикл, при этом перезапускает веб-драйвер с новым прокси
+// handleRunError выполняет восстановление после ошибки по ее классу (apperr.Policy.Recovery).
+// Возвращает true, если нужно перезапустить выполнение профиля
 // Возможна ситуация, когда не удалось переподключиться к Selenium
 //
-// Обрабатываются следующие ошибки:
-// - WDConnectError: подключение к Selenium WebDriver
-// - TooManyRequestsErr: переподключение с новым прокси
-func handleRunError(err error, deps MainLoopDeps) bool {
+// Действия:
+// - RecoveryReconnect: переподключение к Selenium WebDriver с тем же прокси
+// - RecoveryRotateProxy: прокси помечается заблокированным (или неисправным), переподключение с новым прокси
+// - RecoveryRelogin: сохраненная сессия удаляется, профиль проходит авторизацию заново
+// - RecoveryRetry: восстановление не требуется, выполняется следующий профиль
+func handleRunError(err error, w *worker.Worker, deps MainLoopDeps) bool {
 	if err == nil {
 		return false
 	}
 
-	var connectErr worker.WDConnectError
-	if errors.As(err, &connectErr) {
-		err = deps.Workers.ConnectSameProxy(deps.Services.Selenium)
+	class, policy := apperr.Classify(err)
+	log.Printf("Main loop error (%s): %v\n", class, err)
+
+	switch policy.Recovery {
+	case apperr.RecoveryReconnect:
+		metrics.WebDriverReconnects.WithLabelValues(class).Inc()
+		err = w.ConnectSameProxy(deps.Services.Selenium)
 		if err != nil {
 			log.Println("Web driver reconnect error:", err)
 			return false
@@ -69,11 +244,16 @@ func handleRunError(err error, deps MainLoopDeps) bool {
 
 		log.Println("Web driver reconnected with the same proxy:", deps.ProxiesManager.CurrentRU().Host)
 		return true
-	}
 
-	var banErr worker.TooManyRequestsErr
-	if errors.As(err, &banErr) {
-		log.Println(banErr)
+	case apperr.RecoveryRotateProxy:
+		current := deps.ProxiesManager.CurrentRU()
+		if class == apperr.ClassTooManyRequests {
+			metrics.TooManyRequests.WithLabelValues(current.Host).Inc()
+			deps.ProxiesManager.ReportBan(current)
+		} else {
+			deps.ProxiesManager.ReportFailure(current)
+		}
+		metrics.WebDriverReconnects.WithLabelValues(class).Inc()
 		log.Println("Trying to reconnect with another proxy...")
 
 		err := deps.Services.Selenium.Quit()
@@ -82,16 +262,102 @@ func handleRunError(err error, deps MainLoopDeps) bool {
 		}
 
 		newProxie := deps.ProxiesManager.NextRU()
-		err = deps.Workers.ConnectGeneratedProxy(deps.Services.Selenium, newProxie)
+		err = w.ConnectGeneratedProxy(deps.Services.Selenium, newProxie)
 		if err != nil {
+			deps.ProxiesManager.ReportFailure(newProxie)
 			log.Println("Web driver reconnect error:", err)
 			return false
 		}
 
 		log.Println("Web driver reconnected with new proxy:", newProxie.Host)
 		return true
+
+	case apperr.RecoveryRelogin:
+		err = w.ResetSession()
+		if err != nil {
+			log.Println("Reset session error:", err)
+			return false
+		}
+
+		log.Println("Session reset, profile will be authorized again:", w.Profile().Name)
+		return true
 	}
 
-	log.Println("Main loop error:", err)
 	return false
 }
+
+// recordRun сохраняет результат выполнения воркера в историю
+func recordRun(deps MainLoopDeps, w *worker.Worker, runErr error) {
+	if deps.History == nil {
+		return
+	}
+
+	report := w.LastReport()
+	run := history.Run{
+		Profile:             w.Profile().Name,
+		StartedAt:           report.StartedAt,
+		FinishedAt:          report.FinishedAt,
+		ProxyHost:           deps.ProxiesManager.CurrentRU().Host,
+		AuthorizationNeeded: report.AuthorizationNeeded,
+		CaptchaAttempts:     report.CaptchaAttempts,
+		CaptchaSolved:       report.CaptchaSolved,
+		CaptchaInvalid:      report.CaptchaInvalid,
+		AvailabilityChecked: report.AvailabilityChecked,
+		Available:           report.Available,
+		VisaCategory:        report.VisaCategory,
+		Days:                report.Days,
+		ErrorClass:          apperr.ClassOf(runErr),
+	}
+	if runErr != nil {
+		run.Error = runErr.Error()
+	}
+
+	if _, err := deps.History.Save(run); err != nil {
+		log.Println("Save run history error:", err)
+	}
+}
+
+// notifyRun передает результат выполнения воркера в политику уведомлений
+func notifyRun(deps MainLoopDeps, w *worker.Worker, runErr error) {
+	if deps.NotifyPolicy == nil {
+		return
+	}
+
+	report := w.LastReport()
+	event := notify.Event{
+		Profile:        w.Profile().Name,
+		Email:          w.Profile().NotifiedEmail,
+		At:             report.FinishedAt,
+		Checked:        report.AvailabilityChecked,
+		Available:      report.Available,
+		Err:            runErr,
+		ScreenshotPath: w.ScreenshotPath(),
+		VisaCategory:   report.VisaCategory,
+		Days:           report.Days,
+	}
+	if b := report.Booking; b != nil {
+		event.Booking = &notify.Booking{
+			Date:           b.Date,
+			Slot:           b.Slot,
+			DryRun:         b.DryRun,
+			Reference:      b.Reference,
+			ScreenshotPath: b.ScreenshotPath,
+		}
+	}
+
+	err := deps.NotifyPolicy.Observe(event)
+	if err != nil {
+		log.Println("Notification error:", err)
+	}
+}
+
+// runOutcome возвращает результат выполнения воркера для метрик: available, not_available или класс ошибки
+func runOutcome(w *worker.Worker, runErr error) string {
+	if runErr != nil {
+		return apperr.ClassOf(runErr)
+	}
+	if w.LastReport().Available {
+		return "available"
+	}
+	return "not_available"
+}
@@ -0,0 +1,244 @@
+package app
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/tebeka/selenium"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"visasolution/internal/apperr"
+	"visasolution/internal/config"
+	"visasolution/internal/service"
+	"visasolution/internal/service/servicetest"
+	"visasolution/internal/session"
+	"visasolution/internal/worker"
+)
+
+const testProxies = `{"russian_proxies": ["http://10.0.0.1:8080", "http://10.0.0.2:8080"]}`
+
+// newTestDeps создает зависимости основного цикла с фейковыми сервисами и один воркер.
+// Возвращает также папку воркера, в которой хранятся его сессии
+func newTestDeps(t *testing.T) (MainLoopDeps, *servicetest.Fakes, string) {
+	t.Helper()
+
+	proxies, err := config.ParseProxiesFile([]byte(testProxies))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpFolder := t.TempDir()
+	services, fakes := servicetest.NewService()
+	w := worker.NewWorker(services, worker.Deps{
+		TmpFolder:       tmpFolder,
+		ExtensionFolder: t.TempDir(),
+		Profile:         config.Profile{Name: "test"},
+		CurrentProxy:    proxies.CurrentRU,
+		SessionKey:      bytes.Repeat([]byte{1}, session.KeySize),
+	})
+	if err := w.MakePreparation(); err != nil {
+		t.Fatal(err)
+	}
+
+	return MainLoopDeps{
+		Workers:        []*worker.Worker{w},
+		Services:       services,
+		Config:         &config.Config{BreakerThreshold: 5},
+		ProxiesManager: proxies,
+		Controller:     NewController(proxies),
+	}, fakes, tmpFolder
+}
+
+func TestHandleRunError(t *testing.T) {
+	connectErr := errors.New("connection refused")
+
+	tests := []struct {
+		name  string
+		err   error
+		setup func(f *servicetest.Fakes)
+		// wantRestart результат handleRunError
+		wantRestart bool
+		// wantCalls вызовы фейка Selenium
+		wantCalls []string
+		// wantRotated прокси заменен на следующий
+		wantRotated bool
+		// wantBanned, wantFailures состояние прокси, с которым произошла ошибка
+		wantBanned   bool
+		wantFailures int
+		// wantNextFailures количество ошибок нового прокси
+		wantNextFailures int
+	}{
+		{
+			name: "no error",
+		},
+		{
+			name:        "session lost, reconnect with same proxy",
+			err:         fmt.Errorf("go to visa type verification page error:%w", service.InvalidSessionError),
+			wantRestart: true,
+			wantCalls:   []string{"ConnectWithProxy"},
+		},
+		{
+			name:        "webdriver connect error, reconnect with same proxy",
+			err:         apperr.Wrap(apperr.ClassWebDriverConnect, "selenium connect with proxy error", connectErr),
+			wantRestart: true,
+			wantCalls:   []string{"ConnectWithProxy"},
+		},
+		{
+			name: "reconnect failed",
+			err:  service.InvalidSessionError,
+			setup: func(f *servicetest.Fakes) {
+				f.Selenium.QueueErr("ConnectWithProxy", connectErr)
+			},
+			wantCalls: []string{"ConnectWithProxy"},
+		},
+		{
+			name:         "too many requests, proxy banned and rotated",
+			err:          fmt.Errorf("page parse error:%w", service.TooManyRequestsError),
+			wantRestart:  true,
+			wantCalls:    []string{"Quit", "ConnectWithProxy"},
+			wantRotated:  true,
+			wantBanned:   true,
+			wantFailures: 1,
+		},
+		{
+			name:         "proxy auth failed, proxy failure reported and rotated",
+			err:          apperr.Wrap(apperr.ClassProxyAuthFailed, "proxy error", errors.New("net::ERR_PROXY_AUTH")),
+			wantRestart:  true,
+			wantCalls:    []string{"Quit", "ConnectWithProxy"},
+			wantRotated:  true,
+			wantFailures: 1,
+		},
+		{
+			name:         "proxy unreachable, proxy failure reported and rotated without ban",
+			err:          apperr.Wrap(apperr.ClassProxyUnreachable, "page load error", errors.New("net::ERR_TIMED_OUT")),
+			wantRestart:  true,
+			wantCalls:    []string{"Quit", "ConnectWithProxy"},
+			wantRotated:  true,
+			wantFailures: 1,
+		},
+		{
+			name: "quit error does not stop rotation",
+			err:  service.TooManyRequestsError,
+			setup: func(f *servicetest.Fakes) {
+				f.Selenium.QueueErr("Quit", service.InvalidSessionError)
+			},
+			wantRestart:  true,
+			wantCalls:    []string{"Quit", "ConnectWithProxy"},
+			wantRotated:  true,
+			wantBanned:   true,
+			wantFailures: 1,
+		},
+		{
+			name: "connect with new proxy failed",
+			err:  service.TooManyRequestsError,
+			setup: func(f *servicetest.Fakes) {
+				f.Selenium.QueueErr("ConnectWithProxy", connectErr)
+			},
+			wantCalls:        []string{"Quit", "ConnectWithProxy"},
+			wantRotated:      true,
+			wantBanned:       true,
+			wantFailures:     1,
+			wantNextFailures: 1,
+		},
+		{
+			name:        "logged out, session reset",
+			err:         fmt.Errorf("authorization error:%w", service.LoggedOutError),
+			wantRestart: true,
+			wantCalls:   []string{"DeleteAllCookies"},
+		},
+		{
+			name: "session reset failed",
+			err:  service.LoggedOutError,
+			setup: func(f *servicetest.Fakes) {
+				f.Selenium.QueueErr("DeleteAllCookies", service.InvalidSessionError)
+			},
+			wantCalls: []string{"DeleteAllCookies"},
+		},
+		{
+			name: "site maintenance, retry without recovery",
+			err:  service.SiteMaintenanceError,
+		},
+		{
+			name: "captcha unsolvable, retry without recovery",
+			err:  apperr.New(apperr.ClassCaptchaUnsolvable, "couldnt solve captcha after 3 tries"),
+		},
+		{
+			name: "notifier failed, retry without recovery",
+			err:  apperr.Wrap(apperr.ClassNotifierFailed, "notify error", errors.New("smtp timeout")),
+		},
+		{
+			name: "unknown error, retry without recovery",
+			err:  errors.New("unexpected"),
+		},
+		{
+			name: "form layout changed, retry without recovery",
+			err:  apperr.New(apperr.ClassLayoutChanged, "no form controls found"),
+		},
+		{
+			name: "quota exceeded, no recovery",
+			err:  apperr.New(apperr.ClassQuotaExceeded, "insufficient quota"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deps, fakes, _ := newTestDeps(t)
+			if tt.setup != nil {
+				tt.setup(fakes)
+			}
+			w := deps.Workers[0]
+			proxies := deps.ProxiesManager
+			current := proxies.CurrentRU()
+
+			restart := handleRunError(tt.err, w, deps)
+			if restart != tt.wantRestart {
+				t.Errorf("handleRunError() = %v, want %v", restart, tt.wantRestart)
+			}
+
+			if got := fakes.Selenium.Methods(); len(got)+len(tt.wantCalls) > 0 && !reflect.DeepEqual(got, tt.wantCalls) {
+				t.Errorf("calls = %v, want %v", got, tt.wantCalls)
+			}
+
+			next := proxies.CurrentRU()
+			if rotated := next != current; rotated != tt.wantRotated {
+				t.Errorf("proxy rotated = %v, want %v", rotated, tt.wantRotated)
+			}
+
+			health := proxies.Health(current)
+			if banned := !health.BannedAt.IsZero(); banned != tt.wantBanned {
+				t.Errorf("proxy banned = %v, want %v", banned, tt.wantBanned)
+			}
+			if health.ConsecutiveFailures != tt.wantFailures {
+				t.Errorf("proxy failures = %d, want %d", health.ConsecutiveFailures, tt.wantFailures)
+			}
+			if tt.wantRotated {
+				if failures := proxies.Health(next).ConsecutiveFailures; failures != tt.wantNextFailures {
+					t.Errorf("new proxy failures = %d, want %d", failures, tt.wantNextFailures)
+				}
+			}
+		})
+	}
+}
+
+func TestHandleRunErrorResetsSavedSession(t *testing.T) {
+	deps, fakes, tmpFolder := newTestDeps(t)
+	w := deps.Workers[0]
+
+	// SaveSession сохраняет куки, которые возвращает фейк, для каждого прокси отдельно
+	fakes.Selenium.SetDefault("Cookies", servicetest.Return([]selenium.Cookie{{Name: session.AuthCookie, Value: "auth"}}))
+	w.SaveSession()
+	deps.ProxiesManager.NextRU()
+	w.SaveSession()
+	if sessions, _ := filepath.Glob(filepath.Join(tmpFolder, "session*.json")); len(sessions) != 2 {
+		t.Fatalf("sessions saved: %v, want 2", sessions)
+	}
+
+	if !handleRunError(service.LoggedOutError, w, deps) {
+		t.Fatal("handleRunError() = false, want true")
+	}
+
+	if sessions, _ := filepath.Glob(filepath.Join(tmpFolder, "session*.json")); len(sessions) != 0 {
+		t.Errorf("sessions left after session reset: %v", sessions)
+	}
+}
@@ -0,0 +1,189 @@
+package app
+
+import (
+	"sync"
+	"time"
+	"visasolution/internal/config"
+	"visasolution/internal/worker"
+)
+
+// RunResult результат последнего выполнения воркера
+type RunResult struct {
+	Profile    string    `json:"profile"`
+	FinishedAt time.Time `json:"finished_at"`
+	// Available nil, если проверка доступности записи не выполнялась
+	Available *bool  `json:"available"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Status состояние основного цикла
+type Status struct {
+	Paused  bool   `json:"paused"`
+	Running bool   `json:"running"`
+	Profile string `json:"profile,omitempty"`
+	Phase   string `json:"phase"`
+	Proxy   string `json:"proxy,omitempty"`
+	// PausedReason причина автоматической паузы, пустая строка - пауза по запросу или ее нет
+	PausedReason string `json:"paused_reason,omitempty"`
+
+	LastResult *RunResult `json:"last_result,omitempty"`
+	// NextRunAt nil, если цикл сейчас выполняется или еще не запущен
+	NextRunAt *time.Time `json:"next_run_at,omitempty"`
+}
+
+// Controller позволяет управлять основным циклом извне (например, через HTTP API) и получать его состояние.
+// Все методы безопасны для вызова из разных горутин
+type Controller struct {
+	mu sync.RWMutex
+
+	paused       bool
+	pausedReason string
+	current      *worker.Worker
+	last         *worker.Worker
+	lastResult   *RunResult
+	nextRunAt    *time.Time
+
+	proxiesManager *config.ProxiesManager
+
+	runNowCh chan struct{}
+	resumeCh chan struct{}
+}
+
+func NewController(proxiesManager *config.ProxiesManager) *Controller {
+	return &Controller{
+		proxiesManager: proxiesManager,
+		runNowCh:       make(chan struct{}, 1),
+		resumeCh:       make(chan struct{}, 1),
+	}
+}
+
+// RunNow запускает следующую итерацию основного цикла немедленно, в том числе на паузе
+func (c *Controller) RunNow() {
+	trySend(c.runNowCh)
+}
+
+// Pause приостанавливает основной цикл. Текущая итерация выполняется до конца
+func (c *Controller) Pause() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.pause("")
+}
+
+// pauseWithReason приостанавливает основной цикл с указанием причины, которая отображается в состоянии
+func (c *Controller) pauseWithReason(reason string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.pause(reason)
+}
+
+// pause ставит паузу и удаляет сигнал предыдущего Resume, который основной цикл мог еще не получить,
+// иначе новая пауза была бы сразу снята. Вызывается под c.mu
+func (c *Controller) pause(reason string) {
+	c.paused = true
+	c.pausedReason = reason
+	select {
+	case <-c.resumeCh:
+	default:
+	}
+}
+
+// Resume снимает основной цикл с паузы
+func (c *Controller) Resume() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.paused {
+		c.paused = false
+		c.pausedReason = ""
+		trySend(c.resumeCh)
+	}
+}
+
+func (c *Controller) Paused() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.paused
+}
+
+// Status возвращает текущее состояние основного цикла
+func (c *Controller) Status() Status {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	status := Status{
+		Paused:       c.paused,
+		PausedReason: c.pausedReason,
+		Running:      c.current != nil,
+		Phase:        worker.PhaseIdle,
+		LastResult:   c.lastResult,
+		NextRunAt:    c.nextRunAt,
+	}
+	if c.current != nil {
+		status.Profile = c.current.Profile().Name
+		status.Phase = c.current.Phase()
+	}
+	if c.proxiesManager != nil && len(c.proxiesManager.ProxiesRU()) > 0 {
+		status.Proxy = c.proxiesManager.CurrentRU().Host
+	}
+
+	return status
+}
+
+// LastWorker возвращает воркер последнего выполнения или nil, если выполнений еще не было
+func (c *Controller) LastWorker() *worker.Worker {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.last
+}
+
+// runStarted отмечает начало выполнения воркера
+func (c *Controller) runStarted(w *worker.Worker) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.current = w
+	c.nextRunAt = nil
+}
+
+// runFinished сохраняет результат выполнения воркера
+func (c *Controller) runFinished(w *worker.Worker, runErr error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	report := w.LastReport()
+	result := &RunResult{
+		Profile:    w.Profile().Name,
+		FinishedAt: report.FinishedAt,
+	}
+	if report.AvailabilityChecked {
+		available := report.Available
+		result.Available = &available
+	}
+	if runErr != nil {
+		result.Error = runErr.Error()
+	}
+
+	c.current = nil
+	c.last = w
+	c.lastResult = result
+}
+
+// scheduled сохраняет время следующего запуска
+func (c *Controller) scheduled(at time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.nextRunAt = &at
+}
+
+// trySend неблокирующе отправляет сигнал в канал с буфером 1
+func trySend(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,96 @@
+package app
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// waitResumeResult запускает waitResume и возвращает канал с его результатом
+func waitResumeResult(ctx context.Context, ctl *Controller) <-chan bool {
+	done := make(chan bool, 1)
+	go func() { done <- waitResume(ctx, ctl) }()
+	return done
+}
+
+func TestControllerPauseAfterResume(t *testing.T) {
+	tests := []struct {
+		name  string
+		pause func(ctl *Controller)
+	}{
+		{name: "manual pause", pause: func(ctl *Controller) { ctl.Pause() }},
+		{name: "circuit breaker", pause: func(ctl *Controller) { ctl.pauseWithReason("5 consecutive run errors") }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctl := NewController(nil)
+
+			// Resume во время ожидания следующей итерации, основной цикл сигнал еще не получил
+			ctl.Pause()
+			ctl.Resume()
+			tt.pause(ctl)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			done := waitResumeResult(ctx, ctl)
+
+			select {
+			case <-done:
+				t.Fatal("waitResume() returned while paused")
+			case <-time.After(50 * time.Millisecond):
+			}
+
+			ctl.Resume()
+			select {
+			case ok := <-done:
+				if !ok {
+					t.Error("waitResume() = false after Resume, want true")
+				}
+			case <-time.After(time.Second):
+				t.Fatal("waitResume() did not return after Resume")
+			}
+			if ctl.Paused() {
+				t.Error("Paused() = true after Resume")
+			}
+		})
+	}
+}
+
+func TestWaitResume(t *testing.T) {
+	t.Run("not paused", func(t *testing.T) {
+		if !waitResume(context.Background(), NewController(nil)) {
+			t.Error("waitResume() = false, want true")
+		}
+	})
+
+	t.Run("run now while paused", func(t *testing.T) {
+		ctl := NewController(nil)
+		ctl.Pause()
+		ctl.RunNow()
+
+		if !waitResume(context.Background(), ctl) {
+			t.Error("waitResume() = false, want true")
+		}
+		if !ctl.Paused() {
+			t.Error("RunNow resumed the main loop, want single run on pause")
+		}
+	})
+
+	t.Run("context canceled", func(t *testing.T) {
+		ctl := NewController(nil)
+		ctl.Pause()
+		ctx, cancel := context.WithCancel(context.Background())
+		done := waitResumeResult(ctx, ctl)
+		cancel()
+
+		select {
+		case ok := <-done:
+			if ok {
+				t.Error("waitResume() = true after cancel, want false")
+			}
+		case <-time.After(time.Second):
+			t.Fatal("waitResume() did not return after cancel")
+		}
+	})
+}
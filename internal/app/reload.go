@@ -0,0 +1,321 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"visasolution/internal/config"
+)
+
+// ReloadDeps файлы, которые перечитываются между итерациями основного цикла.
+// Пустой путь - файл не отслеживается
+type ReloadDeps struct {
+	ConfigFile   string
+	ProxiesFile  string
+	ProfilesFile string
+}
+
+// Reloader отслеживает изменения файлов конфигурации и применяет их между итерациями основного цикла.
+// Некорректные изменения отклоняются, при этом продолжает использоваться прежняя конфигурация
+type Reloader struct {
+	d ReloadDeps
+
+	// seen последнее прочитанное содержимое файлов, в том числе отклоненное: повторно оно не проверяется
+	seen map[string][]byte
+	// accepted последнее примененное содержимое файлов, относительно него выводится разница
+	accepted map[string][]byte
+}
+
+// NewReloader создает Reloader и запоминает текущее содержимое файлов
+func NewReloader(d ReloadDeps) (*Reloader, error) {
+	r := &Reloader{
+		d:        d,
+		seen:     make(map[string][]byte),
+		accepted: make(map[string][]byte),
+	}
+
+	for _, filePath := range []string{d.ConfigFile, d.ProxiesFile, d.ProfilesFile} {
+		if filePath == "" {
+			continue
+		}
+
+		data, err := os.ReadFile(filePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read watched file:%w", err)
+		}
+		r.seen[filePath] = data
+		r.accepted[filePath] = data
+	}
+
+	return r, nil
+}
+
+// Reload перечитывает изменившиеся файлы и применяет их к deps
+func (r *Reloader) Reload(deps *MainLoopDeps) {
+	if r.d.ConfigFile != "" {
+		r.reloadConfig(deps)
+	}
+	if r.d.ProxiesFile != "" {
+		r.reloadProxies(deps)
+	}
+	if r.d.ProfilesFile != "" {
+		r.reloadProfiles(deps)
+	}
+}
+
+// reloadConfig применяет изменения интервала основного цикла, получателей уведомлений и параметров визы.
+// Остальные изменения требуют перезапуска и игнорируются
+func (r *Reloader) reloadConfig(deps *MainLoopDeps) {
+	data, ok := r.changed(r.d.ConfigFile)
+	if !ok {
+		return
+	}
+
+	oldValues, _ := config.ParseConfigFile(r.d.ConfigFile, r.accepted[r.d.ConfigFile])
+	values, err := config.ParseConfigFile(r.d.ConfigFile, data)
+	if err != nil {
+		log.Println("Config reload rejected:", err)
+		return
+	}
+	diff := config.DiffValues(oldValues, values)
+
+	next, err := config.ParseConfigValues(values, r.d.ProfilesFile == "")
+	if err != nil {
+		log.Println("Config reload rejected:", err)
+		logDiff(diff)
+		return
+	}
+
+	merged, restartRequired := deps.Config.Reload(next)
+	deps.Config = merged
+	r.accepted[r.d.ConfigFile] = data
+
+	log.Println("Config reloaded")
+	logDiff(diff)
+	if len(restartRequired) > 0 {
+		log.Println("Restart required to apply config fields:", strings.Join(restartRequired, ", "))
+	}
+
+	if deps.Services.Telegram != nil {
+		deps.Services.Telegram.SetChatIDs(merged.TelegramChatIDs)
+	}
+	// Без файла с профилями единственный профиль формируется из конфигурации
+	if r.d.ProfilesFile == "" {
+		for _, w := range deps.Workers {
+			w.SetProfile(merged.DefaultProfile())
+		}
+	}
+}
+
+// reloadProxies заменяет списки прокси. Веб-драйвер переподключается, только если текущий прокси удален или изменен
+func (r *Reloader) reloadProxies(deps *MainLoopDeps) {
+	data, ok := r.changed(r.d.ProxiesFile)
+	if !ok {
+		return
+	}
+
+	diff := diffLines(proxyLines(r.accepted[r.d.ProxiesFile]), proxyLines(data))
+
+	err := config.CheckProxiesFile(data)
+	if err != nil {
+		log.Println("Proxies reload rejected:", err)
+		logDiff(diff)
+		return
+	}
+
+	next, err := config.ParseProxiesFile(data)
+	if err != nil {
+		log.Println("Proxies reload rejected:", err)
+		logDiff(diff)
+		return
+	}
+
+	pm := deps.ProxiesManager
+	oldForeign := pm.ProxyForeign
+	currentChanged := pm.Replace(next)
+	r.accepted[r.d.ProxiesFile] = data
+
+	log.Println("Proxies reloaded")
+	logDiff(diff)
+
+	if currentChanged {
+		// Сессия веб-драйвера со старым прокси закрывается, иначе она занимает слот Selenium
+		if err := deps.Services.Selenium.Quit(); err != nil {
+			log.Println("Web driver quit error:", err)
+		}
+
+		proxy := pm.CurrentRU()
+		err = deps.Workers[0].ConnectGeneratedProxy(deps.Services.Selenium, proxy)
+		if err != nil {
+			pm.ReportFailure(proxy)
+			log.Println("Web driver reconnect error:", err)
+		} else {
+			log.Println("Web driver reconnected with new proxy:", proxy.Host)
+		}
+
+		if deps.Config.CaptchaSolver == config.CaptchaSolverGPT && deps.Config.ImgurFallback {
+			if err := deps.Services.Image.ClientInitWithProxy(proxy); err != nil {
+				log.Println("Image client init error:", err)
+			}
+		}
+	}
+
+	if pm.ProxyForeign != oldForeign {
+		if deps.Config.CaptchaSolver == config.CaptchaSolverGPT {
+			if err := deps.Services.Chat.ClientInitWithProxy(pm.ProxyForeign); err != nil {
+				log.Println("Chat client init error:", err)
+			}
+		}
+		if deps.Services.Telegram != nil {
+			if err := deps.Services.Telegram.ClientInitWithProxy(pm.ProxyForeign); err != nil {
+				log.Println("Telegram client init error:", err)
+			}
+		}
+	}
+}
+
+// reloadProfiles заменяет профили заявителей. Добавление и удаление профилей требует перезапуска
+func (r *Reloader) reloadProfiles(deps *MainLoopDeps) {
+	data, ok := r.changed(r.d.ProfilesFile)
+	if !ok {
+		return
+	}
+
+	diff := diffLines(profileLines(r.accepted[r.d.ProfilesFile]), profileLines(data))
+
+	profiles, err := config.ParseProfilesFile(data)
+	if err != nil {
+		log.Println("Profiles reload rejected:", err)
+		logDiff(diff)
+		return
+	}
+
+	byName := make(map[string]config.Profile, len(profiles))
+	for _, p := range profiles {
+		byName[p.Name] = p
+	}
+	if len(profiles) != len(deps.Workers) {
+		log.Println("Profiles reload rejected: profiles were added or removed, restart required")
+		logDiff(diff)
+		return
+	}
+	for _, w := range deps.Workers {
+		if _, ok := byName[w.Profile().Name]; !ok {
+			log.Println("Profiles reload rejected: profile", w.Profile().Name, "was removed or renamed, restart required")
+			logDiff(diff)
+			return
+		}
+	}
+
+	for _, w := range deps.Workers {
+		w.SetProfile(byName[w.Profile().Name])
+	}
+	r.accepted[r.d.ProfilesFile] = data
+
+	log.Println("Profiles reloaded")
+	logDiff(diff)
+}
+
+// changed читает файл и возвращает его содержимое, если оно изменилось с последней проверки
+func (r *Reloader) changed(filePath string) ([]byte, bool) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		if r.seen[filePath] != nil {
+			log.Println("Failed to read watched file:", err)
+			r.seen[filePath] = nil
+		}
+		return nil, false
+	}
+
+	if bytes.Equal(data, r.seen[filePath]) {
+		return nil, false
+	}
+	r.seen[filePath] = data
+
+	return data, !bytes.Equal(data, r.accepted[filePath])
+}
+
+// proxyLines возвращает строки файла с прокси без паролей
+func proxyLines(data []byte) []string {
+	var file struct {
+		RussianProxies []string `json:"russian_proxies"`
+		ForeignProxy   string   `json:"foreign_proxy"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil
+	}
+
+	lines := make([]string, 0, len(file.RussianProxies)+1)
+	for i, row := range file.RussianProxies {
+		lines = append(lines, "russian "+redactProxyRow(i+1, row))
+	}
+	if file.ForeignProxy != "" {
+		lines = append(lines, "foreign "+redactProxyRow(1, file.ForeignProxy))
+	}
+
+	return lines
+}
+
+func redactProxyRow(n int, row string) string {
+	proxy, err := config.ParseProxy(row)
+	if err != nil {
+		return fmt.Sprintf("<invalid proxy №%d>", n)
+	}
+	return proxy.RedactedURL()
+}
+
+// profileLines возвращает профили из файла в виде JSON строк без паролей
+func profileLines(data []byte) []string {
+	var file struct {
+		Profiles []config.Profile `json:"profiles"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil
+	}
+
+	lines := make([]string, 0, len(file.Profiles))
+	for _, p := range file.Profiles {
+		if p.BlsPassword != "" {
+			p.BlsPassword = "***"
+		}
+		line, _ := json.Marshal(p)
+		lines = append(lines, string(line))
+	}
+
+	return lines
+}
+
+// diffLines возвращает строки, удаленные из old ("- ") и добавленные в next ("+ ")
+func diffLines(old, next []string) []string {
+	oldSet := make(map[string]struct{}, len(old))
+	for _, l := range old {
+		oldSet[l] = struct{}{}
+	}
+	nextSet := make(map[string]struct{}, len(next))
+	for _, l := range next {
+		nextSet[l] = struct{}{}
+	}
+
+	var diff []string
+	for _, l := range old {
+		if _, ok := nextSet[l]; !ok {
+			diff = append(diff, "- "+l)
+		}
+	}
+	for _, l := range next {
+		if _, ok := oldSet[l]; !ok {
+			diff = append(diff, "+ "+l)
+		}
+	}
+
+	return diff
+}
+
+func logDiff(diff []string) {
+	for _, l := range diff {
+		log.Println("   ", l)
+	}
+}
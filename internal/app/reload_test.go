@@ -0,0 +1,34 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReloadProxiesQuitsWebDriver(t *testing.T) {
+	deps, fakes, _ := newTestDeps(t)
+
+	proxiesFile := filepath.Join(t.TempDir(), "proxies.json")
+	if err := os.WriteFile(proxiesFile, []byte(testProxies), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewReloader(ReloadDeps{ProxiesFile: proxiesFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Текущий прокси удален из списка
+	if err := os.WriteFile(proxiesFile, []byte(`{"russian_proxies": ["http://10.0.0.3:8080"]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	r.Reload(&deps)
+
+	if host := deps.ProxiesManager.CurrentRU().Host; host != "10.0.0.3" {
+		t.Fatalf("current proxy = %s, want 10.0.0.3", host)
+	}
+	if got, want := fakes.Selenium.Methods(), []string{"Quit", "ConnectWithProxy"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("calls = %v, want %v", got, want)
+	}
+}
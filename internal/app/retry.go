@@ -0,0 +1,114 @@
+package app
+
+import (
+	"math"
+	"time"
+	"visasolution/internal/apperr"
+)
+
+// Backoff экспоненциальная задержка перед повтором после ошибки
+type Backoff struct {
+	Initial    time.Duration
+	Max        time.Duration
+	Multiplier float64
+}
+
+// Delay возвращает задержку перед повтором после attempt-й ошибки подряд (начиная с 1)
+func (b Backoff) Delay(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+
+	delay := float64(b.Initial) * math.Pow(b.Multiplier, float64(attempt-1))
+	if delay > float64(b.Max) {
+		return b.Max
+	}
+	return time.Duration(delay)
+}
+
+// DefaultBackoffs задержки по классам ошибок: дольше всего ждем окончания технического обслуживания
+// и после блокировки прокси, переподключение к веб-драйверу повторяем быстрее.
+// Для остальных классов используется задержка apperr.ClassUnknown
+var DefaultBackoffs = map[string]Backoff{
+	apperr.ClassWebDriverConnect:  {Initial: 10 * time.Second, Max: 5 * time.Minute, Multiplier: 2},
+	apperr.ClassSessionLost:       {Initial: 10 * time.Second, Max: 5 * time.Minute, Multiplier: 2},
+	apperr.ClassTooManyRequests:   {Initial: time.Minute, Max: 30 * time.Minute, Multiplier: 2},
+	apperr.ClassProxyAuthFailed:   {Initial: 10 * time.Second, Max: 5 * time.Minute, Multiplier: 2},
+	apperr.ClassProxyUnreachable:  {Initial: 30 * time.Second, Max: 10 * time.Minute, Multiplier: 2},
+	apperr.ClassSiteMaintenance:   {Initial: 5 * time.Minute, Max: time.Hour, Multiplier: 2},
+	apperr.ClassLoggedOut:         {Initial: 30 * time.Second, Max: 10 * time.Minute, Multiplier: 2},
+	apperr.ClassCaptchaInvalid:    {Initial: 30 * time.Second, Max: 10 * time.Minute, Multiplier: 2},
+	apperr.ClassCaptchaUnsolvable: {Initial: 30 * time.Second, Max: 10 * time.Minute, Multiplier: 2},
+	apperr.ClassLayoutChanged:     {Initial: 30 * time.Second, Max: 5 * time.Minute, Multiplier: 2},
+	apperr.ClassUnknown:           {Initial: 30 * time.Second, Max: 15 * time.Minute, Multiplier: 2},
+}
+
+// RetryPolicy решает, сколько ждать после ошибки выполнения, и работает как автоматический выключатель:
+// после threshold ошибок подряд (любых классов) одного профиля или apperr.Policy.PauseAfter ошибок одного класса
+// подряд сообщает, что основной цикл нужно остановить.
+// Ошибки считаются отдельно для каждого профиля, поэтому успешные выполнения других профилей
+// не скрывают профиль, который постоянно завершается ошибкой.
+// Используется только из горутины основного цикла
+type RetryPolicy struct {
+	backoffs  map[string]Backoff
+	threshold int
+
+	// failures количество ошибок подряд по профилю
+	failures map[string]int
+	// classFailures количество ошибок подряд по профилю и классу, от него зависит задержка.
+	// У профиля хранится только счетчик класса последней ошибки: ошибка другого класса прерывает серию
+	classFailures map[string]map[string]int
+}
+
+// NewRetryPolicy создает политику повторов.
+// Параметры:
+// - backoffs задержки по классам ошибок. Для класса без задержки используется задержка класса unknown
+// - threshold количество ошибок подряд одного профиля до срабатывания выключателя, 0 - выключатель не используется
+func NewRetryPolicy(backoffs map[string]Backoff, threshold int) *RetryPolicy {
+	p := &RetryPolicy{
+		backoffs:  backoffs,
+		threshold: threshold,
+	}
+	p.Reset()
+	return p
+}
+
+// Failure отмечает ошибку класса class при выполнении профиля profile и возвращает задержку перед следующим выполнением.
+// tripped - выключатель сработал: ошибок подряд у профиля стало threshold или ошибок класса подряд - PauseAfter
+// из политики класса. Счетчики всех профилей при этом сбрасываются
+func (p *RetryPolicy) Failure(profile, class string) (delay time.Duration, tripped bool) {
+	p.failures[profile]++
+	if _, ok := p.classFailures[profile][class]; !ok {
+		p.classFailures[profile] = make(map[string]int)
+	}
+	p.classFailures[profile][class]++
+
+	pauseAfter := apperr.PolicyOf(class).PauseAfter
+	if (p.threshold > 0 && p.failures[profile] >= p.threshold) || (pauseAfter > 0 && p.classFailures[profile][class] >= pauseAfter) {
+		p.Reset()
+		return 0, true
+	}
+
+	backoff, ok := p.backoffs[class]
+	if !ok {
+		backoff = p.backoffs[apperr.ClassUnknown]
+	}
+	return backoff.Delay(p.classFailures[profile][class]), false
+}
+
+// Failures возвращает количество ошибок подряд профиля profile
+func (p *RetryPolicy) Failures(profile string) int {
+	return p.failures[profile]
+}
+
+// Success сбрасывает счетчики ошибок профиля profile. Вызывается после его успешного выполнения
+func (p *RetryPolicy) Success(profile string) {
+	delete(p.failures, profile)
+	delete(p.classFailures, profile)
+}
+
+// Reset сбрасывает счетчики ошибок всех профилей
+func (p *RetryPolicy) Reset() {
+	p.failures = make(map[string]int)
+	p.classFailures = make(map[string]map[string]int)
+}
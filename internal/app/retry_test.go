@@ -0,0 +1,120 @@
+package app
+
+import (
+	"testing"
+	"time"
+	"visasolution/internal/apperr"
+)
+
+var testBackoffs = map[string]Backoff{
+	apperr.ClassTooManyRequests: {Initial: time.Minute, Max: 4 * time.Minute, Multiplier: 2},
+	apperr.ClassUnknown:         {Initial: time.Second, Max: time.Minute, Multiplier: 2},
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	p := NewRetryPolicy(testBackoffs, 0)
+
+	steps := []struct {
+		profile string
+		class   string
+		want    time.Duration
+	}{
+		{profile: "a", class: apperr.ClassTooManyRequests, want: time.Minute},
+		{profile: "a", class: apperr.ClassTooManyRequests, want: 2 * time.Minute},
+		// Задержка растет отдельно для каждого профиля
+		{profile: "b", class: apperr.ClassTooManyRequests, want: time.Minute},
+		{profile: "a", class: apperr.ClassTooManyRequests, want: 4 * time.Minute},
+		{profile: "a", class: apperr.ClassTooManyRequests, want: 4 * time.Minute},
+		// Задержка класса без собственной задержки берется из класса unknown.
+		// Ошибка другого класса прерывает серию, задержка снова начинается с начальной
+		{profile: "a", class: apperr.ClassLoggedOut, want: time.Second},
+		{profile: "a", class: apperr.ClassTooManyRequests, want: time.Minute},
+	}
+	for i, step := range steps {
+		delay, tripped := p.Failure(step.profile, step.class)
+		if tripped {
+			t.Fatalf("step %d: breaker tripped without threshold", i)
+		}
+		if delay != step.want {
+			t.Errorf("step %d: Failure(%s, %s) delay = %s, want %s", i, step.profile, step.class, delay, step.want)
+		}
+	}
+
+	p.Success("a")
+	if delay, _ := p.Failure("a", apperr.ClassTooManyRequests); delay != time.Minute {
+		t.Errorf("delay after success = %s, want %s", delay, time.Minute)
+	}
+	if delay, _ := p.Failure("b", apperr.ClassTooManyRequests); delay != 2*time.Minute {
+		t.Errorf("delay of other profile after success = %s, want %s", delay, 2*time.Minute)
+	}
+}
+
+func TestRetryPolicyBreaker(t *testing.T) {
+	const threshold = 3
+	p := NewRetryPolicy(testBackoffs, threshold)
+
+	// Профиль "ok" выполняется успешно между ошибками профиля "failing" и не должен скрывать его ошибки
+	for i := 1; i < threshold; i++ {
+		if _, tripped := p.Failure("failing", apperr.ClassUnknown); tripped {
+			t.Fatalf("breaker tripped after %d errors, want %d", i, threshold)
+		}
+		p.Success("ok")
+		if got := p.Failures("failing"); got != i {
+			t.Fatalf("Failures(failing) = %d, want %d", got, i)
+		}
+	}
+
+	// Ошибки разных классов считаются вместе
+	if _, tripped := p.Failure("failing", apperr.ClassTooManyRequests); !tripped {
+		t.Fatalf("breaker not tripped after %d errors", threshold)
+	}
+	if got := p.Failures("failing"); got != 0 {
+		t.Errorf("Failures(failing) after trip = %d, want 0", got)
+	}
+
+	// Ошибки чередующихся профилей не накапливаются, если профиль между ними выполняется успешно
+	for i := 0; i < 2*threshold; i++ {
+		profile := []string{"a", "b"}[i%2]
+		if _, tripped := p.Failure(profile, apperr.ClassUnknown); tripped {
+			t.Fatalf("breaker tripped on error %d of profile %s", i, profile)
+		}
+		p.Success(profile)
+	}
+}
+
+func TestRetryPolicyPauseAfter(t *testing.T) {
+	pauseAfter := apperr.PolicyOf(apperr.ClassLayoutChanged).PauseAfter
+	if pauseAfter < 2 {
+		t.Fatalf("PauseAfter of %s = %d, want at least 2", apperr.ClassLayoutChanged, pauseAfter)
+	}
+	p := NewRetryPolicy(testBackoffs, 0)
+
+	for i := 1; i < pauseAfter; i++ {
+		if _, tripped := p.Failure("a", apperr.ClassLayoutChanged); tripped {
+			t.Fatalf("breaker tripped after %d layout errors, want %d", i, pauseAfter)
+		}
+	}
+
+	// Успешное выполнение сбрасывает серию
+	p.Success("a")
+	for i := 1; i < pauseAfter; i++ {
+		if _, tripped := p.Failure("a", apperr.ClassLayoutChanged); tripped {
+			t.Fatalf("breaker tripped after success and %d layout errors", i)
+		}
+	}
+
+	if _, tripped := p.Failure("a", apperr.ClassLayoutChanged); !tripped {
+		t.Errorf("breaker not tripped after %d layout errors", pauseAfter)
+	}
+
+	// Ошибки класса вперемешку с ошибками другого класса идут не подряд и не ставят на паузу
+	for i := 0; i < 2*pauseAfter; i++ {
+		class := apperr.ClassLayoutChanged
+		if i%2 == 1 {
+			class = apperr.ClassCaptchaUnsolvable
+		}
+		if _, tripped := p.Failure("b", class); tripped {
+			t.Fatalf("breaker tripped on interleaved error %d (%s)", i, class)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package apperr
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Классы ошибок. Значения сохраняются в историю выполнений и используются как метки метрик
+const (
+	// ClassWebDriverConnect не удалось подключиться к Selenium WebDriver
+	ClassWebDriverConnect = "webdriver_connect"
+	// ClassSessionLost сессия веб-драйвера потеряна (invalid session id)
+	ClassSessionLost = "session_lost"
+	// ClassTooManyRequests сайт ограничил запросы с текущего прокси
+	ClassTooManyRequests = "too_many_requests"
+	// ClassProxyAuthFailed прокси отклонил авторизацию или соединение через него
+	ClassProxyAuthFailed = "proxy_auth_failed"
+	// ClassProxyUnreachable сайт не загрузился через прокси: соединение не установлено вовремя или оборвано
+	ClassProxyUnreachable = "proxy_unreachable"
+	// ClassSiteMaintenance сайт BLS на техническом обслуживании
+	ClassSiteMaintenance = "site_maintenance"
+	// ClassLoggedOut сайт не принял авторизацию или сбросил ее
+	ClassLoggedOut = "logged_out"
+	// ClassCaptchaInvalid капча решена неверно (Invalid selection)
+	ClassCaptchaInvalid = "captcha_invalid_selection"
+	// ClassCaptchaUnsolvable капчу не удалось решить за отведенное количество попыток
+	ClassCaptchaUnsolvable = "captcha_unsolvable"
+	// ClassLayoutChanged на странице не найдены ожидаемые элементы: страница не успела загрузиться
+	// или изменилась верстка сайта, если ошибка повторяется
+	ClassLayoutChanged = "form_layout_changed"
+	// ClassNotifierFailed не удалось отправить уведомление
+	ClassNotifierFailed = "notifier_failed"
+	// ClassQuotaExceeded исчерпана квота внешнего API (ChatGPT)
+	ClassQuotaExceeded = "quota_exceeded"
+	// ClassUnknown ошибка без класса
+	ClassUnknown = "unknown"
+)
+
+// Действия основного цикла для восстановления после ошибки
+const (
+	// RecoveryRetry повторить выполнение после задержки
+	RecoveryRetry = "retry"
+	// RecoveryReconnect переподключиться к веб-драйверу с тем же прокси и повторить выполнение профиля
+	RecoveryReconnect = "reconnect"
+	// RecoveryRotateProxy переподключиться к веб-драйверу со следующим прокси и повторить выполнение профиля
+	RecoveryRotateProxy = "rotate_proxy"
+	// RecoveryRelogin удалить сохраненную сессию и повторить выполнение профиля с авторизацией
+	RecoveryRelogin = "relogin"
+	// RecoveryPause поставить основной цикл на паузу и уведомить: без вмешательства ошибка повторится
+	RecoveryPause = "pause"
+)
+
+// Policy как основной цикл обрабатывает ошибку класса
+type Policy struct {
+	// Retryable ошибка временная, выполнение может быть успешным при повторе
+	Retryable bool
+	// Recovery действие для восстановления, одна из констант Recovery*
+	Recovery string
+	// PauseAfter количество ошибок класса подряд у одного профиля, после которого основной цикл ставится на паузу.
+	// 0 - пауза только по общему порогу ошибок подряд
+	PauseAfter int
+}
+
+// policies политики обработки по классам ошибок
+var policies = map[string]Policy{
+	ClassWebDriverConnect:  {Retryable: true, Recovery: RecoveryReconnect},
+	ClassSessionLost:       {Retryable: true, Recovery: RecoveryReconnect},
+	ClassTooManyRequests:   {Retryable: true, Recovery: RecoveryRotateProxy},
+	ClassProxyAuthFailed:   {Retryable: true, Recovery: RecoveryRotateProxy},
+	ClassProxyUnreachable:  {Retryable: true, Recovery: RecoveryRotateProxy},
+	ClassSiteMaintenance:   {Retryable: true, Recovery: RecoveryRetry},
+	ClassLoggedOut:         {Retryable: true, Recovery: RecoveryRelogin},
+	ClassCaptchaInvalid:    {Retryable: true, Recovery: RecoveryRetry},
+	ClassCaptchaUnsolvable: {Retryable: true, Recovery: RecoveryRetry},
+	ClassLayoutChanged:     {Retryable: true, Recovery: RecoveryRetry, PauseAfter: 3},
+	ClassNotifierFailed:    {Retryable: true, Recovery: RecoveryRetry},
+	ClassQuotaExceeded:     {Retryable: false, Recovery: RecoveryPause},
+	ClassUnknown:           {Retryable: true, Recovery: RecoveryRetry},
+}
+
+// Error ошибка с классом
+type Error struct {
+	Class string
+	Msg   string
+	// Err исходная ошибка. Может быть nil
+	Err error
+}
+
+// New создает ошибку класса class. Подходит для объявления sentinel ошибок, которые сравниваются через errors.Is
+func New(class, msg string) *Error {
+	return &Error{Class: class, Msg: msg}
+}
+
+// Wrap оборачивает err в ошибку класса class
+func Wrap(class, msg string, err error) *Error {
+	return &Error{Class: class, Msg: msg, Err: err}
+}
+
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return e.Msg
+	}
+	return fmt.Sprintf("%s: %v", e.Msg, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// ErrorClass возвращает класс ошибки
+func (e *Error) ErrorClass() string {
+	return e.Class
+}
+
+// classifier ошибка, которая знает свой класс
+type classifier interface {
+	ErrorClass() string
+}
+
+// ClassOf возвращает класс первой ошибки в цепочке err, которая знает свой класс.
+// Для nil возвращает пустую строку, для ошибки без класса - ClassUnknown
+func ClassOf(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	var c classifier
+	if errors.As(err, &c) {
+		return c.ErrorClass()
+	}
+
+	return ClassUnknown
+}
+
+// PolicyOf возвращает политику обработки ошибки класса class. Для неизвестного класса - политику ClassUnknown
+func PolicyOf(class string) Policy {
+	if p, ok := policies[class]; ok {
+		return p
+	}
+	return policies[ClassUnknown]
+}
+
+// Classify возвращает класс ошибки err и политику ее обработки
+func Classify(err error) (string, Policy) {
+	class := ClassOf(err)
+	return class, PolicyOf(class)
+}
@@ -1,61 +1,351 @@
 package config
 
 import (
+	"encoding/base64"
 	"fmt"
-	"github.com/joho/godotenv"
+	"net"
 	"os"
+	"reflect"
 	"strconv"
+	"strings"
+	"time"
+	"visasolution/internal/schedule"
+	"visasolution/internal/secrets"
+	"visasolution/internal/session"
 )
 
+// Config конфигурация бота. Теги полей описывают схему:
+// env - имя переменной, default - значение по умолчанию, secret - значение скрывается при выводе
 type Config struct {
-	NotifiedEmail     string
-	MainLoopIntervalM int
+	NotifiedEmail     string `env:"NOTIFIED_EMAIL"`
+	MainLoopIntervalM int    `env:"MAIN_LOOP_INTERVAL_M" default:"30"`
 
-	BlsEmail    string
-	BlsPassword string
-	SeleniumUrl string
+	// HttpAddr адрес HTTP API, HttpToken - bearer токен для доступа к нему.
+	// Токен обязателен, если API слушает не только loopback интерфейс
+	HttpAddr  string `env:"HTTP_ADDR" default:"127.0.0.1:2525"`
+	HttpToken string `env:"HTTP_TOKEN" secret:"true"`
 
-	ChatApiKey string
+	BlsEmail    string `env:"BLS_EMAIL"`
+	BlsPassword string `env:"BLS_PASSWORD" secret:"true"`
+	SeleniumUrl string `env:"SELENIUM_URL"`
 
-	ImgurClientId     string
-	ImgurClientSecret string
+	Visa VisaPreferences
 
-	SmtpHost     string
-	SmtpPort     int
-	SmtpUsername string
-	Password     string
+	ChatApiKey string `env:"CHAT_API_KEY" secret:"true"`
+
+	// CaptchaSolver тип решателя капчи: "gpt" (по умолчанию) или "ocr"
+	CaptchaSolver string `env:"CAPTCHA_SOLVER" default:"gpt"`
+
+	ImgurClientId     string `env:"IMGUR_CLIENT_ID"`
+	ImgurClientSecret string `env:"IMGUR_CLIENT_SECRET" secret:"true"`
+	// ImgurFallback загружать капчу на Imgur, если не удалось отправить ее в chat api напрямую
+	ImgurFallback bool `env:"IMGUR_FALLBACK"`
+
+	SmtpHost     string `env:"SMTP_HOST"`
+	SmtpPort     int    `env:"SMTP_PORT"`
+	SmtpUsername string `env:"SMTP_USERNAME"`
+	SmtpPassword string `env:"SMTP_PASSWORD" secret:"true"`
+
+	// TelegramBotToken уведомления в Telegram отключены, если токен не задан
+	TelegramBotToken string  `env:"TELEGRAM_BOT_TOKEN" secret:"true"`
+	TelegramChatIDs  []int64 `env:"TELEGRAM_CHAT_IDS"`
+	// TelegramBaseURL адрес Telegram Bot API, пустая строка - адрес по умолчанию
+	TelegramBaseURL string `env:"TELEGRAM_API_URL"`
+
+	// DigestHour час (по локальному времени), начиная с которого отправляется ежедневная сводка
+	DigestHour int `env:"DIGEST_HOUR" default:"9"`
+
+	// ScheduleSpec окна и cron выражения расписания основного цикла, вне них используется MainLoopIntervalM
+	ScheduleSpec string `env:"SCHEDULE"`
+	// ScheduleTimezone часовой пояс расписания, пустая строка - локальный
+	ScheduleTimezone string `env:"SCHEDULE_TZ"`
+	// ScheduleJitter максимальная случайная задержка запуска
+	ScheduleJitter time.Duration `env:"SCHEDULE_JITTER"`
+
+	// SelectorsFile файл набора селекторов страниц сайта (.yaml, .yml, .json), пустая строка - встроенный набор
+	SelectorsFile string `env:"SELECTORS_FILE"`
+	// RecordDir папка для записи страниц сайта при каждом выполнении (см. replay), пустая строка - запись отключена
+	RecordDir string `env:"RECORD_DIR"`
+
+	// BreakerThreshold количество ошибок выполнения подряд одного профиля, после которого основной цикл ставится на паузу
+	BreakerThreshold int `env:"BREAKER_THRESHOLD" default:"5"`
+
+	// SessionTTL время жизни сохраненной сессии сайта с момента последнего использования
+	SessionTTL time.Duration `env:"SESSION_TTL" default:"20m"`
+	// SessionRefreshBefore за сколько до истечения сессии бот авторизуется заново, не дожидаясь выхода из аккаунта
+	SessionRefreshBefore time.Duration `env:"SESSION_REFRESH_BEFORE" default:"5m"`
+	// SessionKey ключ шифрования сохраненных сессий сайта: 32 байта в base64
+	SessionKey string `env:"SESSION_KEY" secret:"true"`
+}
+
+// Типы решателей капчи (CAPTCHA_SOLVER). Объявлены здесь, а не в service, т.к. service импортирует config
+const (
+	// CaptchaSolverGPT решение капчи через ChatGPT Vision
+	CaptchaSolverGPT = "gpt"
+	// CaptchaSolverOCR локальное решение капчи через tesseract, работает без доступа в интернет
+	CaptchaSolverOCR = "ocr"
+)
+
+// Источники значений параметров конфигурации, в порядке возрастания приоритета
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceEnv     = "env"
+)
+
+// Setting итоговое значение параметра конфигурации и его источник
+type Setting struct {
+	Key    string
+	Value  string
+	Source string
+	Secret bool
 }
 
-const defaultMainLoopIntervalM = 30
+// ValidationError содержит все найденные ошибки конфигурации, чтобы исправить их за один раз
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid config:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
 
-func LoadConfig() (*Config, error) {
-	err := godotenv.Load()
+// LoadConfig загружает конфигурацию из значений по умолчанию, необязательного файла filePath
+// (.env, .yaml/.yml или .toml) и переменных окружения; переменные окружения имеют наибольший приоритет.
+// needDefaultProfile - профиль заявителя формируется из конфигурации (файл с профилями не задан),
+// тогда обязательны BLS_EMAIL, BLS_PASSWORD и NOTIFIED_EMAIL
+func LoadConfig(filePath string, needDefaultProfile bool) (*Config, error) {
+	values, err := ReadConfigFile(filePath)
 	if err != nil {
 		return nil, err
 	}
 
-	mainLoopIntervalM, err := strconv.Atoi(os.Getenv("MAIN_LOOP_INTERVAL_M"))
-	if err != nil || mainLoopIntervalM <= 0 {
-		mainLoopIntervalM = defaultMainLoopIntervalM
+	return ParseConfigValues(values, needDefaultProfile)
+}
+
+// ParseConfigValues собирает и проверяет конфигурацию из переменных файла values.
+// Возвращает *ValidationError со всеми ошибками разбора и проверки
+func ParseConfigValues(values map[string]string, needDefaultProfile bool) (*Config, error) {
+	c, _, problems := Resolve(values)
+	problems = append(problems, c.validate(needDefaultProfile)...)
+	if len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
 	}
 
-	smtpPort, err := strconv.Atoi(os.Getenv("SMTP_PORT"))
+	return c, nil
+}
+
+// secretFileSuffix суффикс переменной с путем к файлу, из которого читается значение параметра (Docker/Kubernetes secrets)
+const secretFileSuffix = "_FILE"
+
+// Resolve собирает конфигурацию по схеме без проверки обязательных полей.
+// Значение параметра KEY может быть задано файлом в KEY_FILE или ссылкой на внешнее хранилище секретов
+// (например, "vault:secret/data/visasolution#smtp_password").
+// Возвращает итоговые значения параметров с источниками и ошибки разбора значений и получения секретов
+func Resolve(values map[string]string) (*Config, []Setting, []string) {
+	c := &Config{}
+	var settings []Setting
+	var problems []string
+
+	resolver, err := secrets.FromEnv()
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse smtp port: %w", err)
-	}
-
-	return &Config{
-		NotifiedEmail:     os.Getenv("NOTIFIED_EMAIL"),
-		MainLoopIntervalM: mainLoopIntervalM,
-		SeleniumUrl:       os.Getenv("SELENIUM_URL"),
-		BlsEmail:          os.Getenv("BLS_EMAIL"),
-		BlsPassword:       os.Getenv("BLS_PASSWORD"),
-		ChatApiKey:        os.Getenv("CHAT_API_KEY"),
-		ImgurClientId:     os.Getenv("IMGUR_CLIENT_ID"),
-		ImgurClientSecret: os.Getenv("IMGUR_CLIENT_SECRET"),
-		SmtpHost:          os.Getenv("SMTP_HOST"),
-		SmtpPort:          smtpPort,
-		SmtpUsername:      os.Getenv("SMTP_USERNAME"),
-		Password:          os.Getenv("SMTP_PASSWORD"),
-	}, nil
+		problems = append(problems, err.Error())
+		resolver = secrets.NewResolver()
+	}
+
+	fileValue := func(key string) string { return values[key] }
+
+	for _, f := range schemaFields(reflect.ValueOf(c).Elem()) {
+		raw, secretFile, source := f.def, "", SourceDefault
+		if v, file := lookupLayer(f.key, fileValue); v != "" || file != "" {
+			raw, secretFile, source = v, file, SourceFile
+		}
+		if v, file := lookupLayer(f.key, os.Getenv); v != "" || file != "" {
+			raw, secretFile, source = v, file, SourceEnv
+		}
+
+		if secretFile != "" {
+			source += " (" + f.key + secretFileSuffix + ")"
+			raw, err = secrets.ReadFile(secretFile)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%s%s: %v", f.key, secretFileSuffix, err))
+			}
+		}
+
+		if raw != "" {
+			secret, scheme, err := resolver.Resolve(raw)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %v", f.key, err))
+			}
+			if scheme != "" {
+				raw = secret
+				source += " (" + scheme + ")"
+			}
+		}
+
+		if raw != "" {
+			if err := setField(f.value, raw); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: invalid value '%s': %v", f.key, redactValue(f.key, raw), err))
+			}
+		}
+		settings = append(settings, Setting{Key: f.key, Value: raw, Source: source, Secret: f.secret})
+	}
+
+	return c, settings, problems
+}
+
+// lookupLayer возвращает значение параметра key из одного источника
+// или путь к файлу со значением из key+"_FILE", если сам параметр не задан
+func lookupLayer(key string, get func(string) string) (string, string) {
+	if v := get(key); v != "" {
+		return v, ""
+	}
+	return "", get(key + secretFileSuffix)
+}
+
+// validate проверяет обязательные параметры с учетом включенных возможностей
+func (c *Config) validate(needDefaultProfile bool) []string {
+	var problems []string
+	required := func(key, value string, reason string) {
+		if value == "" {
+			problems = append(problems, fmt.Sprintf("%s is required%s", key, reason))
+		}
+	}
+
+	required("SELENIUM_URL", c.SeleniumUrl, "")
+	// Без токена управлять ботом (/pause, /run-now) может любой, кому доступен адрес
+	if !isLoopbackAddr(c.HttpAddr) {
+		required("HTTP_TOKEN", c.HttpToken, " when HTTP_ADDR is not a loopback address")
+	}
+	if c.MainLoopIntervalM <= 0 {
+		problems = append(problems, "MAIN_LOOP_INTERVAL_M must be positive")
+	}
+	if _, err := c.Schedule(); err != nil {
+		problems = append(problems, fmt.Sprintf("invalid schedule: %v", err))
+	}
+	if c.DigestHour < 0 || c.DigestHour > 23 {
+		problems = append(problems, "DIGEST_HOUR must be between 0 and 23")
+	}
+	if c.BreakerThreshold <= 0 {
+		problems = append(problems, "BREAKER_THRESHOLD must be positive")
+	}
+	if c.SessionTTL <= 0 {
+		problems = append(problems, "SESSION_TTL must be positive")
+	}
+	if c.SessionRefreshBefore < 0 || c.SessionRefreshBefore >= c.SessionTTL {
+		problems = append(problems, "SESSION_REFRESH_BEFORE must be non-negative and less than SESSION_TTL")
+	}
+	required("SESSION_KEY", c.SessionKey, " (generate with: openssl rand -base64 32)")
+	if c.SessionKey != "" {
+		if _, err := c.SessionKeyBytes(); err != nil {
+			problems = append(problems, fmt.Sprintf("invalid SESSION_KEY: %v", err))
+		}
+	}
+
+	if needDefaultProfile {
+		const reason = " when profiles file is not used"
+		required("BLS_EMAIL", c.BlsEmail, reason)
+		required("BLS_PASSWORD", c.BlsPassword, reason)
+		required("NOTIFIED_EMAIL", c.NotifiedEmail, reason)
+	}
+
+	switch c.CaptchaSolver {
+	case CaptchaSolverGPT:
+		required("CHAT_API_KEY", c.ChatApiKey, " for CAPTCHA_SOLVER=gpt")
+		if c.ImgurFallback {
+			required("IMGUR_CLIENT_ID", c.ImgurClientId, " when IMGUR_FALLBACK is enabled")
+			required("IMGUR_CLIENT_SECRET", c.ImgurClientSecret, " when IMGUR_FALLBACK is enabled")
+		}
+	case CaptchaSolverOCR:
+	default:
+		problems = append(problems, fmt.Sprintf("unknown CAPTCHA_SOLVER '%s', expected '%s' or '%s'", c.CaptchaSolver, CaptchaSolverGPT, CaptchaSolverOCR))
+	}
+
+	// Уведомления на почту отправляются всегда
+	required("SMTP_HOST", c.SmtpHost, "")
+	required("SMTP_USERNAME", c.SmtpUsername, "")
+	required("SMTP_PASSWORD", c.SmtpPassword, "")
+	if c.SmtpPort <= 0 || c.SmtpPort > 65535 {
+		problems = append(problems, "SMTP_PORT must be a valid port")
+	}
+
+	if c.TelegramBotToken != "" && len(c.TelegramChatIDs) == 0 {
+		problems = append(problems, "TELEGRAM_CHAT_IDS is required when TELEGRAM_BOT_TOKEN is set")
+	}
+
+	return problems
+}
+
+// parseInt64List разбирает список чисел, разделенных запятыми. Пустая строка - пустой список
+func parseInt64List(s string) ([]int64, error) {
+	var nums []int64
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		num, err := strconv.ParseInt(part, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		nums = append(nums, num)
+	}
+	return nums, nil
+}
+
+// isLoopbackAddr проверяет, что адрес host:port доступен только с этой машины.
+// Пустой host (":2525") означает все интерфейсы
+func isLoopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// SessionKeyBytes возвращает ключ шифрования сессий
+func (c *Config) SessionKeyBytes() ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(c.SessionKey))
+	if err != nil {
+		return nil, fmt.Errorf("expected base64: %w", err)
+	}
+	if len(key) != session.KeySize {
+		return nil, fmt.Errorf("expected %d bytes, got %d", session.KeySize, len(key))
+	}
+	return key, nil
+}
+
+// Schedule возвращает расписание основного цикла
+func (c *Config) Schedule() (*schedule.Schedule, error) {
+	loc := time.Local
+	if c.ScheduleTimezone != "" {
+		var err error
+		loc, err = time.LoadLocation(c.ScheduleTimezone)
+		if err != nil {
+			return nil, fmt.Errorf("unknown timezone '%s': %w", c.ScheduleTimezone, err)
+		}
+	}
+
+	return schedule.New(c.ScheduleSpec, time.Duration(c.MainLoopIntervalM)*time.Minute, loc, c.ScheduleJitter)
+}
+
+// defaultProfileName имя профиля, который формируется из переменных окружения
+const defaultProfileName = "default"
+
+// DefaultProfile возвращает профиль, сформированный из переменных окружения BLS_EMAIL, BLS_PASSWORD, NOTIFIED_EMAIL и VISA_*.
+// Используется, если файл с профилями не задан.
+func (c *Config) DefaultProfile() Profile {
+	return Profile{
+		Name:          defaultProfileName,
+		BlsEmail:      c.BlsEmail,
+		BlsPassword:   c.BlsPassword,
+		NotifiedEmail: c.NotifiedEmail,
+		Visa:          c.Visa,
+	}
 }
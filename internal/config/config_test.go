@@ -0,0 +1,119 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolveSecretFile(t *testing.T) {
+	dir := t.TempDir()
+	writeSecret := func(name, data string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	passwordFile := writeSecret("smtp_password", "file-password\n")
+	crlfFile := writeSecret("smtp_password_crlf", "crlf-password\r\n")
+	missingFile := filepath.Join(dir, "missing")
+
+	tests := []struct {
+		name string
+		// file и env переменные файла конфигурации и окружения
+		file       map[string]string
+		env        map[string]string
+		want       string
+		wantSource string
+		wantErr    string
+	}{
+		{
+			name:       "file in config file",
+			file:       map[string]string{"SMTP_PASSWORD_FILE": passwordFile},
+			want:       "file-password",
+			wantSource: SourceFile + " (SMTP_PASSWORD_FILE)",
+		},
+		{
+			name:       "file in env",
+			env:        map[string]string{"SMTP_PASSWORD_FILE": passwordFile},
+			want:       "file-password",
+			wantSource: SourceEnv + " (SMTP_PASSWORD_FILE)",
+		},
+		{
+			name:       "trailing crlf is trimmed",
+			env:        map[string]string{"SMTP_PASSWORD_FILE": crlfFile},
+			want:       "crlf-password",
+			wantSource: SourceEnv + " (SMTP_PASSWORD_FILE)",
+		},
+		{
+			name:       "plain value wins within layer",
+			env:        map[string]string{"SMTP_PASSWORD": "env-password", "SMTP_PASSWORD_FILE": passwordFile},
+			want:       "env-password",
+			wantSource: SourceEnv,
+		},
+		{
+			name:       "env file overrides config file value",
+			file:       map[string]string{"SMTP_PASSWORD": "config-password"},
+			env:        map[string]string{"SMTP_PASSWORD_FILE": passwordFile},
+			want:       "file-password",
+			wantSource: SourceEnv + " (SMTP_PASSWORD_FILE)",
+		},
+		{
+			name:       "env value overrides config file secret file",
+			file:       map[string]string{"SMTP_PASSWORD_FILE": passwordFile},
+			env:        map[string]string{"SMTP_PASSWORD": "env-password"},
+			want:       "env-password",
+			wantSource: SourceEnv,
+		},
+		{
+			name:       "missing file",
+			env:        map[string]string{"SMTP_PASSWORD_FILE": missingFile},
+			wantSource: SourceEnv + " (SMTP_PASSWORD_FILE)",
+			wantErr:    "SMTP_PASSWORD_FILE: failed to read secret file",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearConfigEnv(t)
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			c, settings, problems := Resolve(tt.file)
+
+			if tt.wantErr != "" {
+				if len(problems) != 1 || !strings.Contains(problems[0], tt.wantErr) {
+					t.Errorf("Resolve() problems = %q, want %q", problems, tt.wantErr)
+				}
+			} else if len(problems) > 0 {
+				t.Errorf("Resolve() problems: %q", problems)
+			}
+
+			if c.SmtpPassword != tt.want {
+				t.Errorf("SmtpPassword = %q, want %q", c.SmtpPassword, tt.want)
+			}
+			for _, s := range settings {
+				if s.Key == "SMTP_PASSWORD" && s.Source != tt.wantSource {
+					t.Errorf("SMTP_PASSWORD source = %q, want %q", s.Source, tt.wantSource)
+				}
+			}
+		})
+	}
+}
+
+// Ошибка чтения файла секрета попадает в ошибку проверки конфигурации
+func TestParseConfigValuesSecretFile(t *testing.T) {
+	clearConfigEnv(t)
+
+	values := validValues()
+	delete(values, "SMTP_PASSWORD")
+	values["SMTP_PASSWORD_FILE"] = filepath.Join(t.TempDir(), "missing")
+
+	_, err := ParseConfigValues(values, false)
+	if err == nil || !strings.Contains(err.Error(), "SMTP_PASSWORD_FILE: failed to read secret file") {
+		t.Errorf("ParseConfigValues() error = %v, want secret file error", err)
+	}
+}
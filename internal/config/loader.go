@@ -0,0 +1,159 @@
+package config
+
+import (
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// schemaField параметр конфигурации, описанный тегами поля Config
+type schemaField struct {
+	key    string
+	def    string
+	secret bool
+	value  reflect.Value
+}
+
+// schemaFields возвращает параметры конфигурации в порядке объявления полей.
+// Вложенные структуры без тега env (например, VisaPreferences) разворачиваются
+func schemaFields(v reflect.Value) []schemaField {
+	var fields []schemaField
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		key := sf.Tag.Get("env")
+		if key == "" {
+			if sf.Type.Kind() == reflect.Struct {
+				fields = append(fields, schemaFields(v.Field(i))...)
+			}
+			continue
+		}
+
+		fields = append(fields, schemaField{
+			key:    key,
+			def:    sf.Tag.Get("default"),
+			secret: sf.Tag.Get("secret") == "true",
+			value:  v.Field(i),
+		})
+	}
+
+	return fields
+}
+
+// setField записывает в поле значение raw, преобразованное к типу поля
+func setField(v reflect.Value, raw string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Int64:
+		if v.Type() != reflect.TypeOf(time.Duration(0)) {
+			return fmt.Errorf("unsupported type %s", v.Type())
+		}
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("expected duration, e.g. 30s")
+		}
+		v.SetInt(int64(d))
+	case reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("expected integer")
+		}
+		v.SetInt(int64(n))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("expected boolean")
+		}
+		v.SetBool(b)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.Int64 {
+			return fmt.Errorf("unsupported type %s", v.Type())
+		}
+		nums, err := parseInt64List(raw)
+		if err != nil {
+			return fmt.Errorf("expected comma separated integers")
+		}
+		v.Set(reflect.ValueOf(nums))
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+
+	return nil
+}
+
+// ReadConfigFile читает переменные из файла конфигурации без изменения окружения процесса.
+// Файл необязателен: если его нет, возвращается пустой набор переменных
+func ReadConfigFile(filePath string) (map[string]string, error) {
+	data, err := os.ReadFile(filePath)
+	if os.IsNotExist(err) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	return ParseConfigFile(filePath, data)
+}
+
+// ParseConfigFile разбирает содержимое файла конфигурации. Формат определяется по расширению:
+// .yaml/.yml и .toml, остальные файлы читаются в формате .env.
+// Вложенные ключи YAML и TOML объединяются через "_" и приводятся к верхнему регистру:
+// smtp.host -> SMTP_HOST. Списки объединяются через запятую
+func ParseConfigFile(filePath string, data []byte) (map[string]string, error) {
+	var tree map[string]any
+
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, &tree); err != nil {
+			return nil, fmt.Errorf("failed to parse yaml config: %w", err)
+		}
+	case ".toml":
+		if err := toml.Unmarshal(data, &tree); err != nil {
+			return nil, fmt.Errorf("failed to parse toml config: %w", err)
+		}
+	default:
+		values, err := godotenv.Unmarshal(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse env config: %w", err)
+		}
+		return values, nil
+	}
+
+	values := make(map[string]string)
+	flattenConfig("", tree, values)
+
+	return values, nil
+}
+
+func flattenConfig(prefix string, tree map[string]any, values map[string]string) {
+	for k, v := range tree {
+		key := strings.ToUpper(k)
+		if prefix != "" {
+			key = prefix + "_" + key
+		}
+
+		switch v := v.(type) {
+		case map[string]any:
+			flattenConfig(key, v, values)
+		case []any:
+			parts := make([]string, 0, len(v))
+			for _, item := range v {
+				parts = append(parts, fmt.Sprint(item))
+			}
+			values[key] = strings.Join(parts, ",")
+		case nil:
+			values[key] = ""
+		default:
+			values[key] = fmt.Sprint(v)
+		}
+	}
+}
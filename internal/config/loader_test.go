@@ -0,0 +1,508 @@
+package config
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"visasolution/internal/session"
+)
+
+var testSessionKey = base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, session.KeySize))
+
+// validValues минимальный набор переменных, с которым конфигурация проходит проверку
+func validValues() map[string]string {
+	return map[string]string{
+		"SELENIUM_URL":  "http://selenium:4444/wd/hub",
+		"SESSION_KEY":   testSessionKey,
+		"CHAT_API_KEY":  "sk-test",
+		"SMTP_HOST":     "smtp.example.com",
+		"SMTP_PORT":     "587",
+		"SMTP_USERNAME": "bot@example.com",
+		"SMTP_PASSWORD": "smtp-password",
+	}
+}
+
+// clearConfigEnv убирает параметры конфигурации из окружения теста: пустое значение считается незаданным
+func clearConfigEnv(t *testing.T) {
+	t.Helper()
+
+	t.Setenv("VAULT_ADDR", "")
+	for _, f := range schemaFields(reflect.ValueOf(&Config{}).Elem()) {
+		t.Setenv(f.key, "")
+		t.Setenv(f.key+secretFileSuffix, "")
+	}
+}
+
+func TestResolveLayers(t *testing.T) {
+	clearConfigEnv(t)
+
+	values, err := ParseConfigFile("config.yaml", []byte(`
+main_loop_interval_m: 15
+http:
+  addr: 127.0.0.1:8080
+smtp:
+  host: smtp.file.example.com
+  port: 25
+telegram:
+  chat_ids: [1, 2]
+`))
+	if err != nil {
+		t.Fatalf("ParseConfigFile() error: %v", err)
+	}
+	t.Setenv("SMTP_HOST", "smtp.env.example.com")
+	t.Setenv("BREAKER_THRESHOLD", "7")
+
+	c, settings, problems := Resolve(values)
+	if len(problems) > 0 {
+		t.Fatalf("Resolve() problems: %v", problems)
+	}
+
+	want := map[string]Setting{
+		// Значение по умолчанию
+		"DIGEST_HOUR": {Value: "9", Source: SourceDefault},
+		"SESSION_TTL": {Value: "20m", Source: SourceDefault},
+		// Файл переопределяет значение по умолчанию
+		"MAIN_LOOP_INTERVAL_M": {Value: "15", Source: SourceFile},
+		"HTTP_ADDR":            {Value: "127.0.0.1:8080", Source: SourceFile},
+		"SMTP_PORT":            {Value: "25", Source: SourceFile},
+		"TELEGRAM_CHAT_IDS":    {Value: "1,2", Source: SourceFile},
+		// Окружение переопределяет файл и значение по умолчанию
+		"SMTP_HOST":         {Value: "smtp.env.example.com", Source: SourceEnv},
+		"BREAKER_THRESHOLD": {Value: "7", Source: SourceEnv},
+		// Незаданный параметр без значения по умолчанию
+		"SMTP_USERNAME": {Value: "", Source: SourceDefault},
+	}
+	for _, s := range settings {
+		w, ok := want[s.Key]
+		if !ok {
+			continue
+		}
+		delete(want, s.Key)
+		if s.Value != w.Value || s.Source != w.Source {
+			t.Errorf("%s = %q from %s, want %q from %s", s.Key, s.Value, s.Source, w.Value, w.Source)
+		}
+	}
+	for key := range want {
+		t.Errorf("setting %s not resolved", key)
+	}
+
+	if c.MainLoopIntervalM != 15 || c.HttpAddr != "127.0.0.1:8080" || c.SmtpHost != "smtp.env.example.com" ||
+		c.SmtpPort != 25 || c.BreakerThreshold != 7 || c.DigestHour != 9 || c.SessionTTL != 20*time.Minute ||
+		!reflect.DeepEqual(c.TelegramChatIDs, []int64{1, 2}) {
+		t.Errorf("Resolve() config = %+v", c)
+	}
+}
+
+func TestParseConfigFile(t *testing.T) {
+	want := map[string]string{
+		"SMTP_HOST":         "smtp.example.com",
+		"SMTP_PORT":         "587",
+		"TELEGRAM_CHAT_IDS": "1,2",
+	}
+
+	tests := []struct {
+		name string
+		file string
+		data string
+	}{
+		{
+			name: "env",
+			file: ".env",
+			data: "SMTP_HOST=smtp.example.com\nSMTP_PORT=587\nTELEGRAM_CHAT_IDS=1,2\n",
+		},
+		{
+			name: "yaml",
+			file: "config.yml",
+			data: "smtp:\n  host: smtp.example.com\n  port: 587\ntelegram_chat_ids: [1, 2]\n",
+		},
+		{
+			name: "toml",
+			file: "config.toml",
+			data: "telegram_chat_ids = [1, 2]\n\n[smtp]\nhost = \"smtp.example.com\"\nport = 587\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, err := ParseConfigFile(tt.file, []byte(tt.data))
+			if err != nil {
+				t.Fatalf("ParseConfigFile() error: %v", err)
+			}
+			if !reflect.DeepEqual(values, want) {
+				t.Errorf("ParseConfigFile() = %v, want %v", values, want)
+			}
+		})
+	}
+}
+
+func TestReadConfigFile(t *testing.T) {
+	dir := t.TempDir()
+
+	// Файл конфигурации необязателен
+	values, err := ReadConfigFile(filepath.Join(dir, ".env"))
+	if err != nil || len(values) != 0 {
+		t.Errorf("ReadConfigFile(missing) = %v, %v, want empty", values, err)
+	}
+
+	path := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(path, []byte("smtp: [unclosed"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadConfigFile(path); err == nil || !strings.Contains(err.Error(), "failed to parse yaml config") {
+		t.Errorf("ReadConfigFile(invalid) error = %v, want parse error", err)
+	}
+}
+
+func TestParseConfigValues(t *testing.T) {
+	tests := []struct {
+		name string
+		// set переопределяет переменные validValues, пустое значение удаляет переменную
+		set                map[string]string
+		needDefaultProfile bool
+		// wantProblems части ожидаемых ошибок, пустой список - конфигурация корректна
+		wantProblems []string
+	}{
+		{
+			name: "valid",
+		},
+		{
+			name:         "selenium url",
+			set:          map[string]string{"SELENIUM_URL": ""},
+			wantProblems: []string{"SELENIUM_URL is required"},
+		},
+		{
+			name:         "http token on all interfaces",
+			set:          map[string]string{"HTTP_ADDR": ":2525"},
+			wantProblems: []string{"HTTP_TOKEN is required when HTTP_ADDR is not a loopback address"},
+		},
+		{
+			name:         "http token on public address",
+			set:          map[string]string{"HTTP_ADDR": "0.0.0.0:2525"},
+			wantProblems: []string{"HTTP_TOKEN is required"},
+		},
+		{
+			name: "http token on public address is set",
+			set:  map[string]string{"HTTP_ADDR": "0.0.0.0:2525", "HTTP_TOKEN": "token"},
+		},
+		{
+			name: "http token on localhost",
+			set:  map[string]string{"HTTP_ADDR": "localhost:2525"},
+		},
+		{
+			name: "http token on ipv6 loopback",
+			set:  map[string]string{"HTTP_ADDR": "[::1]:2525"},
+		},
+		{
+			name:         "main loop interval",
+			set:          map[string]string{"MAIN_LOOP_INTERVAL_M": "0"},
+			wantProblems: []string{"MAIN_LOOP_INTERVAL_M must be positive", "invalid schedule: interval must be positive"},
+		},
+		{
+			name:         "schedule timezone",
+			set:          map[string]string{"SCHEDULE_TZ": "Mars/Olympus"},
+			wantProblems: []string{"invalid schedule: unknown timezone 'Mars/Olympus'"},
+		},
+		{
+			name:         "digest hour",
+			set:          map[string]string{"DIGEST_HOUR": "24"},
+			wantProblems: []string{"DIGEST_HOUR must be between 0 and 23"},
+		},
+		{
+			name:         "breaker threshold",
+			set:          map[string]string{"BREAKER_THRESHOLD": "0"},
+			wantProblems: []string{"BREAKER_THRESHOLD must be positive"},
+		},
+		{
+			name:         "session ttl",
+			set:          map[string]string{"SESSION_TTL": "0s"},
+			wantProblems: []string{"SESSION_TTL must be positive", "SESSION_REFRESH_BEFORE must be non-negative and less than SESSION_TTL"},
+		},
+		{
+			name:         "session refresh before ttl",
+			set:          map[string]string{"SESSION_REFRESH_BEFORE": "20m"},
+			wantProblems: []string{"SESSION_REFRESH_BEFORE must be non-negative and less than SESSION_TTL"},
+		},
+		{
+			name:         "session key",
+			set:          map[string]string{"SESSION_KEY": ""},
+			wantProblems: []string{"SESSION_KEY is required (generate with: openssl rand -base64 32)"},
+		},
+		{
+			name:         "session key not base64",
+			set:          map[string]string{"SESSION_KEY": "not base64!"},
+			wantProblems: []string{"invalid SESSION_KEY: expected base64"},
+		},
+		{
+			name:         "session key size",
+			set:          map[string]string{"SESSION_KEY": base64.StdEncoding.EncodeToString([]byte("short"))},
+			wantProblems: []string{"invalid SESSION_KEY: expected 32 bytes, got 5"},
+		},
+		{
+			name:               "default profile",
+			needDefaultProfile: true,
+			wantProblems: []string{
+				"BLS_EMAIL is required when profiles file is not used",
+				"BLS_PASSWORD is required when profiles file is not used",
+				"NOTIFIED_EMAIL is required when profiles file is not used",
+			},
+		},
+		{
+			name:               "default profile is set",
+			set:                map[string]string{"BLS_EMAIL": "user@example.com", "BLS_PASSWORD": "password", "NOTIFIED_EMAIL": "user@example.com"},
+			needDefaultProfile: true,
+		},
+		{
+			name:         "chat api key for gpt solver",
+			set:          map[string]string{"CHAT_API_KEY": ""},
+			wantProblems: []string{"CHAT_API_KEY is required for CAPTCHA_SOLVER=gpt"},
+		},
+		{
+			name: "ocr solver without chat api key",
+			set:  map[string]string{"CAPTCHA_SOLVER": CaptchaSolverOCR, "CHAT_API_KEY": ""},
+		},
+		{
+			name:         "unknown solver",
+			set:          map[string]string{"CAPTCHA_SOLVER": "human"},
+			wantProblems: []string{"unknown CAPTCHA_SOLVER 'human'"},
+		},
+		{
+			name: "imgur fallback",
+			set:  map[string]string{"IMGUR_FALLBACK": "true"},
+			wantProblems: []string{
+				"IMGUR_CLIENT_ID is required when IMGUR_FALLBACK is enabled",
+				"IMGUR_CLIENT_SECRET is required when IMGUR_FALLBACK is enabled",
+			},
+		},
+		{
+			name: "smtp",
+			set:  map[string]string{"SMTP_HOST": "", "SMTP_USERNAME": "", "SMTP_PASSWORD": ""},
+			wantProblems: []string{
+				"SMTP_HOST is required",
+				"SMTP_USERNAME is required",
+				"SMTP_PASSWORD is required",
+			},
+		},
+		{
+			name:         "smtp port missing",
+			set:          map[string]string{"SMTP_PORT": ""},
+			wantProblems: []string{"SMTP_PORT must be a valid port"},
+		},
+		{
+			name:         "smtp port out of range",
+			set:          map[string]string{"SMTP_PORT": "65536"},
+			wantProblems: []string{"SMTP_PORT must be a valid port"},
+		},
+		{
+			name:         "telegram chat ids",
+			set:          map[string]string{"TELEGRAM_BOT_TOKEN": "123:abc"},
+			wantProblems: []string{"TELEGRAM_CHAT_IDS is required when TELEGRAM_BOT_TOKEN is set"},
+		},
+		{
+			name:         "invalid integer",
+			set:          map[string]string{"DIGEST_HOUR": "nine"},
+			wantProblems: []string{"DIGEST_HOUR: invalid value 'nine': expected integer"},
+		},
+		{
+			name:         "invalid duration",
+			set:          map[string]string{"SESSION_REFRESH_BEFORE": "5"},
+			wantProblems: []string{"SESSION_REFRESH_BEFORE: invalid value '5': expected duration"},
+		},
+		{
+			name:         "invalid boolean",
+			set:          map[string]string{"IMGUR_FALLBACK": "maybe"},
+			wantProblems: []string{"IMGUR_FALLBACK: invalid value 'maybe': expected boolean"},
+		},
+		{
+			name: "invalid list",
+			set:  map[string]string{"TELEGRAM_BOT_TOKEN": "123:abc", "TELEGRAM_CHAT_IDS": "1,two"},
+			wantProblems: []string{
+				"TELEGRAM_CHAT_IDS: invalid value '1,two': expected comma separated integers",
+				"TELEGRAM_CHAT_IDS is required when TELEGRAM_BOT_TOKEN is set",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearConfigEnv(t)
+
+			values := validValues()
+			for k, v := range tt.set {
+				if v == "" {
+					delete(values, k)
+					continue
+				}
+				values[k] = v
+			}
+
+			c, err := ParseConfigValues(values, tt.needDefaultProfile)
+			if len(tt.wantProblems) == 0 {
+				if err != nil {
+					t.Fatalf("ParseConfigValues() error: %v", err)
+				}
+				if c == nil {
+					t.Fatal("ParseConfigValues() returned nil config")
+				}
+				return
+			}
+
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("ParseConfigValues() error = %v, want *ValidationError", err)
+			}
+			// Лишние ошибки означают, что случай проверяет не одно правило
+			if len(validationErr.Problems) != len(tt.wantProblems) {
+				t.Errorf("ParseConfigValues() problems = %q, want %d", validationErr.Problems, len(tt.wantProblems))
+			}
+			for _, want := range tt.wantProblems {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("ParseConfigValues() error = %v, want %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+// Все ошибки конфигурации возвращаются сразу
+func TestParseConfigValuesAllProblems(t *testing.T) {
+	clearConfigEnv(t)
+
+	_, err := ParseConfigValues(map[string]string{}, true)
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("ParseConfigValues() error = %v, want *ValidationError", err)
+	}
+
+	for _, key := range []string{"SELENIUM_URL", "SESSION_KEY", "BLS_EMAIL", "CHAT_API_KEY", "SMTP_HOST", "SMTP_PORT"} {
+		if !strings.Contains(err.Error(), key) {
+			t.Errorf("ParseConfigValues() error does not mention %s:\n%v", key, err)
+		}
+	}
+}
+
+func TestResolveSecrets(t *testing.T) {
+	clearConfigEnv(t)
+
+	values := validValues()
+	values["HTTP_TOKEN"] = "http-token"
+	values["TELEGRAM_BOT_TOKEN"] = "123:abc"
+
+	_, settings, _ := Resolve(values)
+
+	// Значения с флагом Secret скрываются в выводе config print --redacted
+	want := map[string]bool{
+		"HTTP_TOKEN":          true,
+		"BLS_PASSWORD":        true,
+		"CHAT_API_KEY":        true,
+		"IMGUR_CLIENT_SECRET": true,
+		"SMTP_PASSWORD":       true,
+		"TELEGRAM_BOT_TOKEN":  true,
+		"SESSION_KEY":         true,
+		"HTTP_ADDR":           false,
+		"SMTP_HOST":           false,
+		"SMTP_USERNAME":       false,
+		"TELEGRAM_CHAT_IDS":   false,
+	}
+	for _, s := range settings {
+		w, ok := want[s.Key]
+		if !ok {
+			continue
+		}
+		delete(want, s.Key)
+		if s.Secret != w {
+			t.Errorf("%s: Secret = %v, want %v", s.Key, s.Secret, w)
+		}
+	}
+	for key := range want {
+		t.Errorf("setting %s not resolved", key)
+	}
+}
+
+func TestDiffValues(t *testing.T) {
+	old := map[string]string{
+		"SMTP_HOST":     "smtp.example.com",
+		"SMTP_PASSWORD": "old-password",
+		"SCHEDULE":      "09:00-18:00",
+		"SOME_API_KEY":  "old-key",
+	}
+	next := map[string]string{
+		"SMTP_HOST":     "smtp2.example.com",
+		"SMTP_PASSWORD": "new-password",
+		"DIGEST_HOUR":   "10",
+		"SOME_API_KEY":  "old-key",
+		"HTTP_TOKEN":    "token",
+	}
+
+	want := []string{
+		"+ DIGEST_HOUR=10",
+		"+ HTTP_TOKEN=***",
+		"- SCHEDULE=09:00-18:00",
+		"~ SMTP_HOST: smtp.example.com -> smtp2.example.com",
+		"~ SMTP_PASSWORD: *** -> ***",
+	}
+	if got := DiffValues(old, next); !reflect.DeepEqual(got, want) {
+		t.Errorf("DiffValues() = %q, want %q", got, want)
+	}
+}
+
+func TestIsSecretKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{key: "SESSION_KEY", want: true},
+		{key: "smtp_password", want: true},
+		{key: "SMTP_USERNAME", want: false},
+		// Параметры вне схемы определяются по имени
+		{key: "VAULT_TOKEN", want: true},
+		{key: "OTHER_API_KEY", want: true},
+		{key: "OTHER_SETTING", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := IsSecretKey(tt.key); got != tt.want {
+			t.Errorf("IsSecretKey(%s) = %v, want %v", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestConfigReload(t *testing.T) {
+	current := &Config{
+		NotifiedEmail:     "old@example.com",
+		MainLoopIntervalM: 30,
+		SmtpHost:          "smtp.example.com",
+		TelegramChatIDs:   []int64{1},
+		ScheduleSpec:      "09:00-18:00",
+		SessionTTL:        20 * time.Minute,
+	}
+	next := *current
+	next.NotifiedEmail = "new@example.com"
+	next.MainLoopIntervalM = 10
+	next.TelegramChatIDs = []int64{1, 2}
+	next.Visa.VisaType = "Schengen Visa"
+	next.SmtpHost = "smtp2.example.com"
+	next.SessionTTL = time.Hour
+
+	merged, restartRequired := current.Reload(&next)
+
+	want := *current
+	want.NotifiedEmail = next.NotifiedEmail
+	want.MainLoopIntervalM = next.MainLoopIntervalM
+	want.TelegramChatIDs = next.TelegramChatIDs
+	want.Visa = next.Visa
+	if !reflect.DeepEqual(*merged, want) {
+		t.Errorf("Reload() config = %+v, want %+v", *merged, want)
+	}
+	if wantRestart := []string{"SmtpHost", "SessionTTL"}; !reflect.DeepEqual(restartRequired, wantRestart) {
+		t.Errorf("Reload() restart required = %v, want %v", restartRequired, wantRestart)
+	}
+	if current.NotifiedEmail != "old@example.com" {
+		t.Errorf("Reload() modified current config")
+	}
+}
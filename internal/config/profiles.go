@@ -0,0 +1,225 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// profileNameRegexp допустимые символы имени профиля.
+// Имя используется в качестве названия папки, поэтому разрешены только безопасные символы
+var profileNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// Profile - данные одного заявителя: авторизационные данные BLS, адрес для уведомлений и предпочтения по визе
+type Profile struct {
+	Name          string          `json:"name"`
+	BlsEmail      string          `json:"bls_email"`
+	BlsPassword   string          `json:"bls_password"`
+	NotifiedEmail string          `json:"notified_email"`
+	Visa          VisaPreferences `json:"visa"`
+	// Booking - параметры автоматического бронирования. По умолчанию бронирование отключено
+	Booking BookingPreferences `json:"booking"`
+}
+
+// VisaPreferences - параметры визы, которые выбираются в форме "Book New Appointment"
+type VisaPreferences struct {
+	Jurisdiction        string `json:"jurisdiction" env:"VISA_JURISDICTION"`
+	Location            string `json:"location" env:"VISA_LOCATION"`
+	VisaType            string `json:"visa_type" env:"VISA_TYPE"`
+	VisaSubType         string `json:"visa_sub_type" env:"VISA_SUB_TYPE"`
+	AppointmentCategory string `json:"appointment_category" env:"VISA_APPOINTMENT_CATEGORY"`
+}
+
+// String возвращает категорию визы в виде "Jurisdiction / Location / VisaType / VisaSubType / AppointmentCategory",
+// незаполненные поля пропускаются
+func (v VisaPreferences) String() string {
+	var parts []string
+	for _, part := range []string{v.Jurisdiction, v.Location, v.VisaType, v.VisaSubType, v.AppointmentCategory} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, " / ")
+}
+
+type profilesConfig struct {
+	Profiles []Profile `json:"profiles"`
+}
+
+// ParseProfilesFile принимает содержимое файла с профилями и возвращает слайс из Profile.
+// Параметры:
+// - profilesFile содержимое файла с профилями в формате JSON. Пример содержимого:
+//
+//	{
+//	  "profiles": [
+//	    {
+//	      "name": "ivanov",
+//	      "bls_email": "ivanov@example.com",
+//	      "bls_password": "pswrd",
+//	      "notified_email": "ivanov@example.com",
+//	      "visa": {
+//	        "jurisdiction": "Moscow",
+//	        "location": "Moscow",
+//	        "visa_type": "Schengen Visa",
+//	        "visa_sub_type": "Tourism",
+//	        "appointment_category": "Normal"
+//	      }
+//	    }
+//	  ]
+//	}
+func ParseProfilesFile(profilesFile []byte) ([]Profile, error) {
+	var cfg profilesConfig
+
+	err := json.Unmarshal(profilesFile, &cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse profiles file: %w", err)
+	}
+
+	if len(cfg.Profiles) == 0 {
+		return nil, fmt.Errorf("no profiles found")
+	}
+
+	names := make(map[string]struct{}, len(cfg.Profiles))
+	for i, p := range cfg.Profiles {
+		if err := p.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid profile №%d: %w", i+1, err)
+		}
+		if _, ok := names[p.Name]; ok {
+			return nil, fmt.Errorf("duplicate profile name '%s'", p.Name)
+		}
+		names[p.Name] = struct{}{}
+	}
+
+	return cfg.Profiles, nil
+}
+
+// Validate проверяет заполненность обязательных полей профиля
+func (p *Profile) Validate() error {
+	if !profileNameRegexp.MatchString(p.Name) {
+		return fmt.Errorf("invalid profile name '%s', allowed characters: a-z, A-Z, 0-9, '_', '-'", p.Name)
+	}
+	if p.BlsEmail == "" || p.BlsPassword == "" {
+		return fmt.Errorf("profile '%s' has empty bls credentials", p.Name)
+	}
+	if err := p.Booking.Validate(); err != nil {
+		return fmt.Errorf("profile '%s': %w", p.Name, err)
+	}
+	return nil
+}
+
+// Форматы дат и времени в предпочтениях бронирования
+const (
+	bookingDateLayout = "2006-01-02"
+	bookingTimeLayout = "15:04"
+)
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+var slotTimeRegexp = regexp.MustCompile(`\d{1,2}:\d{2}`)
+
+// BookingPreferences - параметры автоматического бронирования записи.
+// Бронирование выполняется только если Enabled, при DryRun все шаги выполняются, кроме финального подтверждения
+type BookingPreferences struct {
+	Enabled bool `json:"enabled"`
+	DryRun  bool `json:"dry_run"`
+
+	// EarliestDate, LatestDate - допустимый диапазон дат в формате "2006-01-02". Пустая строка - без ограничения
+	EarliestDate string `json:"earliest_date"`
+	LatestDate   string `json:"latest_date"`
+	// Weekdays - допустимые дни недели: "mon", "tue", ... Пустой список - любой день
+	Weekdays []string `json:"weekdays"`
+	// TimeFrom, TimeTo - допустимый диапазон времени начала слота в формате "15:04". Пустая строка - без ограничения
+	TimeFrom string `json:"time_from"`
+	TimeTo   string `json:"time_to"`
+
+	// ApplicantName - имя заявителя, которое выбирается на странице данных заявителя.
+	// Пустая строка - выбирается первый заявитель
+	ApplicantName string `json:"applicant_name"`
+}
+
+// Validate проверяет форматы дат, времени и дней недели
+func (b *BookingPreferences) Validate() error {
+	for _, d := range []string{b.EarliestDate, b.LatestDate} {
+		if d == "" {
+			continue
+		}
+		if _, err := time.Parse(bookingDateLayout, d); err != nil {
+			return fmt.Errorf("invalid booking date '%s', expected format %s", d, bookingDateLayout)
+		}
+	}
+	for _, t := range []string{b.TimeFrom, b.TimeTo} {
+		if t == "" {
+			continue
+		}
+		if _, err := time.Parse(bookingTimeLayout, t); err != nil {
+			return fmt.Errorf("invalid booking time '%s', expected format %s", t, bookingTimeLayout)
+		}
+	}
+	for _, wd := range b.Weekdays {
+		if _, ok := weekdays[strings.ToLower(wd)]; !ok {
+			return fmt.Errorf("invalid booking weekday '%s'", wd)
+		}
+	}
+	return nil
+}
+
+// MatchDate проверяет, подходит ли дата под ограничения бронирования. Время даты не учитывается
+func (b *BookingPreferences) MatchDate(date time.Time) bool {
+	day := date.Format(bookingDateLayout)
+	// Даты в одном формате сравниваются лексикографически
+	if b.EarliestDate != "" && day < b.EarliestDate {
+		return false
+	}
+	if b.LatestDate != "" && day > b.LatestDate {
+		return false
+	}
+
+	if len(b.Weekdays) == 0 {
+		return true
+	}
+	for _, wd := range b.Weekdays {
+		if weekdays[strings.ToLower(wd)] == date.Weekday() {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchSlot проверяет, подходит ли слот под ограничения по времени.
+// Время начала слота - первое время вида "09:00" в тексте слота, слоты без времени не подходят
+func (b *BookingPreferences) MatchSlot(slot string) bool {
+	start := SlotStartTime(slot)
+	if start == "" {
+		return false
+	}
+	if b.TimeFrom != "" && start < b.TimeFrom {
+		return false
+	}
+	if b.TimeTo != "" && start > b.TimeTo {
+		return false
+	}
+	return true
+}
+
+// SlotStartTime возвращает время начала слота в формате "15:04" или пустую строку, если время не найдено
+func SlotStartTime(slot string) string {
+	start := slotTimeRegexp.FindString(slot)
+	if start == "" {
+		return ""
+	}
+	t, err := time.Parse(bookingTimeLayout, start)
+	if err != nil {
+		return ""
+	}
+	return t.Format(bookingTimeLayout)
+}
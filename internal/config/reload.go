@@ -0,0 +1,106 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// reloadableFields поля Config, которые применяются без перезапуска бота
+var reloadableFields = map[string]bool{
+	"NotifiedEmail":     true,
+	"MainLoopIntervalM": true,
+	"Visa":              true,
+	"TelegramChatIDs":   true,
+	"ScheduleSpec":      true,
+	"ScheduleTimezone":  true,
+	"ScheduleJitter":    true,
+}
+
+// secretKeyParts части имен переменных, значения которых не выводятся в лог
+var secretKeyParts = []string{"PASSWORD", "TOKEN", "SECRET", "API_KEY"}
+
+const redacted = "***"
+
+// Reload возвращает копию конфигурации c, в которой поля, применяемые без перезапуска
+// (интервал и расписание основного цикла, получатели уведомлений, параметры визы), взяты из next.
+// Также возвращает имена остальных изменившихся полей: они требуют перезапуска и остаются прежними
+func (c *Config) Reload(next *Config) (*Config, []string) {
+	merged := *c
+	var restartRequired []string
+
+	mergedValue := reflect.ValueOf(&merged).Elem()
+	nextValue := reflect.ValueOf(next).Elem()
+	for i := 0; i < mergedValue.NumField(); i++ {
+		name := mergedValue.Type().Field(i).Name
+		if reflect.DeepEqual(mergedValue.Field(i).Interface(), nextValue.Field(i).Interface()) {
+			continue
+		}
+		if !reloadableFields[name] {
+			restartRequired = append(restartRequired, name)
+			continue
+		}
+		mergedValue.Field(i).Set(nextValue.Field(i))
+	}
+
+	return &merged, restartRequired
+}
+
+// DiffValues возвращает построчную разницу между переменными конфигурации в виде
+// "+ KEY=value", "- KEY=value" и "~ KEY: old -> new". Значения секретов скрываются
+func DiffValues(old, next map[string]string) []string {
+	keys := make(map[string]struct{}, len(old)+len(next))
+	for k := range old {
+		keys[k] = struct{}{}
+	}
+	for k := range next {
+		keys[k] = struct{}{}
+	}
+
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	var diff []string
+	for _, k := range sorted {
+		oldV, inOld := old[k]
+		nextV, inNext := next[k]
+		switch {
+		case !inOld:
+			diff = append(diff, fmt.Sprintf("+ %s=%s", k, redactValue(k, nextV)))
+		case !inNext:
+			diff = append(diff, fmt.Sprintf("- %s=%s", k, redactValue(k, oldV)))
+		case oldV != nextV:
+			diff = append(diff, fmt.Sprintf("~ %s: %s -> %s", k, redactValue(k, oldV), redactValue(k, nextV)))
+		}
+	}
+
+	return diff
+}
+
+// IsSecretKey возвращает true, если значение переменной key не должно попадать в лог.
+// Для параметров схемы используется тег secret, для остальных - имя переменной
+func IsSecretKey(key string) bool {
+	key = strings.ToUpper(key)
+	for _, f := range schemaFields(reflect.ValueOf(&Config{}).Elem()) {
+		if f.key == key {
+			return f.secret
+		}
+	}
+	for _, part := range secretKeyParts {
+		if strings.Contains(key, part) {
+			return true
+		}
+	}
+	return false
+}
+
+func redactValue(key, value string) string {
+	if IsSecretKey(key) && value != "" {
+		return redacted
+	}
+	return value
+}
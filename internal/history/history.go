@@ -0,0 +1,230 @@
+package history
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	_ "modernc.org/sqlite"
+	"strings"
+	"time"
+	"visasolution/internal/service"
+)
+
+// migrations схема базы данных. Каждый элемент применяется один раз,
+// номер последней примененной миграции хранится в PRAGMA user_version
+var migrations = []string{
+	`CREATE TABLE IF NOT EXISTS runs (
+		id                   INTEGER PRIMARY KEY AUTOINCREMENT,
+		profile              TEXT    NOT NULL,
+		started_at           INTEGER NOT NULL,
+		finished_at          INTEGER NOT NULL,
+		proxy_host           TEXT    NOT NULL,
+		authorization_needed INTEGER NOT NULL,
+		captcha_attempts     INTEGER NOT NULL,
+		captcha_solved       INTEGER NOT NULL,
+		captcha_invalid      INTEGER NOT NULL,
+		availability_checked INTEGER NOT NULL,
+		available            INTEGER NOT NULL,
+		error_class          TEXT    NOT NULL,
+		error                TEXT    NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS runs_started_at_idx ON runs (started_at);`,
+	`ALTER TABLE runs ADD COLUMN visa_category TEXT NOT NULL DEFAULT '';
+	ALTER TABLE runs ADD COLUMN slots_count INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE runs ADD COLUMN slots TEXT NOT NULL DEFAULT '[]';`,
+}
+
+// Run запись об одном выполнении Worker.Run
+type Run struct {
+	ID        int64
+	Profile   string
+	StartedAt time.Time
+	// FinishedAt время окончания выполнения, в том числе с ошибкой
+	FinishedAt time.Time
+	ProxyHost  string
+
+	AuthorizationNeeded bool
+
+	// CaptchaAttempts количество попыток решения капчи, CaptchaSolved - количество успешно решенных капч,
+	// CaptchaInvalid - количество неверных решений (InvalidSelectionError)
+	CaptchaAttempts int
+	CaptchaSolved   int
+	CaptchaInvalid  int
+
+	// AvailabilityChecked true, если выполнение дошло до проверки доступности записи
+	AvailabilityChecked bool
+	Available           bool
+
+	// VisaCategory категория визы, для которой проверялась доступность записи
+	VisaCategory string
+	// Days доступные даты и слоты
+	Days []service.AppointmentDay
+
+	// ErrorClass класс ошибки, которой завершилось выполнение. Пустая строка, если ошибки не было
+	ErrorClass string
+	Error      string
+}
+
+// Filter параметры выборки записей. Нулевые значения полей не учитываются
+type Filter struct {
+	Profile       string
+	ProxyHost     string
+	Since         time.Time
+	OnlyAvailable bool
+	OnlyErrors    bool
+	Limit         int
+}
+
+// Store хранилище истории выполнений в SQLite
+type Store struct {
+	db *sql.DB
+}
+
+// Open открывает (создает при необходимости) базу данных по пути path и применяет миграции
+func Open(path string) (*Store, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("open history db error: %w", err)
+	}
+	// SQLite не поддерживает конкурентную запись
+	db.SetMaxOpenConns(1)
+
+	s := &Store{db: db}
+	if err := s.migrate(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrate history db error: %w", err)
+	}
+
+	return s, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Save сохраняет запись о выполнении и возвращает ее id
+func (s *Store) Save(r Run) (int64, error) {
+	days := r.Days
+	if days == nil {
+		days = []service.AppointmentDay{}
+	}
+	slots, err := json.Marshal(days)
+	if err != nil {
+		return 0, fmt.Errorf("marshal slots error: %w", err)
+	}
+
+	res, err := s.db.Exec(`INSERT INTO runs (
+		profile, started_at, finished_at, proxy_host, authorization_needed,
+		captcha_attempts, captcha_solved, captcha_invalid,
+		availability_checked, available, visa_category, slots_count, slots,
+		error_class, error
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		r.Profile, r.StartedAt.Unix(), r.FinishedAt.Unix(), r.ProxyHost, r.AuthorizationNeeded,
+		r.CaptchaAttempts, r.CaptchaSolved, r.CaptchaInvalid,
+		r.AvailabilityChecked, r.Available, r.VisaCategory, service.CountSlots(r.Days), string(slots),
+		r.ErrorClass, r.Error,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("insert run error: %w", err)
+	}
+
+	return res.LastInsertId()
+}
+
+// List возвращает записи, удовлетворяющие фильтру, от новых к старым
+func (s *Store) List(f Filter) ([]Run, error) {
+	var where []string
+	var args []any
+
+	if f.Profile != "" {
+		where = append(where, "profile = ?")
+		args = append(args, f.Profile)
+	}
+	if f.ProxyHost != "" {
+		where = append(where, "proxy_host = ?")
+		args = append(args, f.ProxyHost)
+	}
+	if !f.Since.IsZero() {
+		where = append(where, "started_at >= ?")
+		args = append(args, f.Since.Unix())
+	}
+	if f.OnlyAvailable {
+		where = append(where, "available = 1")
+	}
+	if f.OnlyErrors {
+		where = append(where, "error_class != ''")
+	}
+
+	query := `SELECT
+		id, profile, started_at, finished_at, proxy_host, authorization_needed,
+		captcha_attempts, captcha_solved, captcha_invalid,
+		availability_checked, available, visa_category, slots,
+		error_class, error
+	FROM runs`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY started_at DESC, id DESC"
+	if f.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, f.Limit)
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("select runs error: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []Run
+	for rows.Next() {
+		var r Run
+		var startedAt, finishedAt int64
+		var slots string
+		err := rows.Scan(
+			&r.ID, &r.Profile, &startedAt, &finishedAt, &r.ProxyHost, &r.AuthorizationNeeded,
+			&r.CaptchaAttempts, &r.CaptchaSolved, &r.CaptchaInvalid,
+			&r.AvailabilityChecked, &r.Available, &r.VisaCategory, &slots,
+			&r.ErrorClass, &r.Error,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("scan run error: %w", err)
+		}
+		if err := json.Unmarshal([]byte(slots), &r.Days); err != nil {
+			return nil, fmt.Errorf("unmarshal slots error: %w", err)
+		}
+		r.StartedAt = time.Unix(startedAt, 0)
+		r.FinishedAt = time.Unix(finishedAt, 0)
+		runs = append(runs, r)
+	}
+
+	return runs, rows.Err()
+}
+
+// migrate применяет еще не примененные миграции
+func (s *Store) migrate() error {
+	var version int
+	if err := s.db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return err
+	}
+
+	for i := version; i < len(migrations); i++ {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(migrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration №%d error: %w", i+1, err)
+		}
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", i+1)); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
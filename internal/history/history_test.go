@@ -0,0 +1,213 @@
+package history
+
+import (
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+	"visasolution/internal/service"
+)
+
+func openTestStore(t *testing.T, path string) *Store {
+	t.Helper()
+
+	s, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func userVersion(t *testing.T, s *Store) int {
+	t.Helper()
+
+	var version int
+	if err := s.db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		t.Fatal(err)
+	}
+	return version
+}
+
+func TestOpenMigrations(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.db")
+
+	s := openTestStore(t, path)
+	if got := userVersion(t, s); got != len(migrations) {
+		t.Errorf("user_version = %d, want %d", got, len(migrations))
+	}
+	if _, err := s.Save(Run{Profile: "a", StartedAt: time.Unix(100, 0)}); err != nil {
+		t.Fatalf("Save() error: %v", err)
+	}
+	s.Close()
+
+	// Повторное открытие не применяет миграции заново и сохраняет записи
+	s = openTestStore(t, path)
+	if got := userVersion(t, s); got != len(migrations) {
+		t.Errorf("user_version after reopen = %d, want %d", got, len(migrations))
+	}
+	runs, err := s.List(Filter{})
+	if err != nil || len(runs) != 1 {
+		t.Errorf("List() after reopen = %d runs, %v, want 1", len(runs), err)
+	}
+}
+
+// База данных, созданная до появления миграций (user_version = 0), обновляется до последней версии
+func TestOpenMigratesV0(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.db")
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(migrations[0]); err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`INSERT INTO runs (
+		profile, started_at, finished_at, proxy_host, authorization_needed,
+		captcha_attempts, captcha_solved, captcha_invalid,
+		availability_checked, available, error_class, error
+	) VALUES ('old', 100, 160, '10.0.0.1', 1, 2, 1, 1, 1, 0, '', '')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	s := openTestStore(t, path)
+	if got := userVersion(t, s); got != len(migrations) {
+		t.Errorf("user_version = %d, want %d", got, len(migrations))
+	}
+
+	runs, err := s.List(Filter{})
+	if err != nil {
+		t.Fatalf("List() error: %v", err)
+	}
+	want := []Run{{
+		ID:                  1,
+		Profile:             "old",
+		StartedAt:           time.Unix(100, 0),
+		FinishedAt:          time.Unix(160, 0),
+		ProxyHost:           "10.0.0.1",
+		AuthorizationNeeded: true,
+		CaptchaAttempts:     2,
+		CaptchaSolved:       1,
+		CaptchaInvalid:      1,
+		AvailabilityChecked: true,
+		Days:                []service.AppointmentDay{},
+	}}
+	if !reflect.DeepEqual(runs, want) {
+		t.Errorf("List() = %+v, want %+v", runs, want)
+	}
+
+	// Новые столбцы доступны для записи
+	if _, err := s.Save(Run{Profile: "new", StartedAt: time.Unix(200, 0), VisaCategory: "Schengen Visa"}); err != nil {
+		t.Fatalf("Save() after migration error: %v", err)
+	}
+}
+
+func TestSave(t *testing.T) {
+	s := openTestStore(t, filepath.Join(t.TempDir(), "history.db"))
+
+	run := Run{
+		Profile:             "a",
+		StartedAt:           time.Unix(1000, 0),
+		FinishedAt:          time.Unix(1090, 0),
+		ProxyHost:           "10.0.0.1",
+		AuthorizationNeeded: true,
+		CaptchaAttempts:     3,
+		CaptchaSolved:       2,
+		CaptchaInvalid:      1,
+		AvailabilityChecked: true,
+		Available:           true,
+		VisaCategory:        "Moscow / Moscow / Schengen Visa",
+		Days: []service.AppointmentDay{
+			{Date: time.Date(2026, 11, 2, 0, 0, 0, 0, time.UTC), Slots: []string{"09:00-09:15", "09:15-09:30"}},
+			{Date: time.Date(2026, 11, 3, 0, 0, 0, 0, time.UTC), Slots: []string{"10:00-10:15"}},
+		},
+	}
+
+	id, err := s.Save(run)
+	if err != nil {
+		t.Fatalf("Save() error: %v", err)
+	}
+	if id2, err := s.Save(Run{Profile: "b", StartedAt: time.Unix(900, 0)}); err != nil || id2 != id+1 {
+		t.Fatalf("Save() second = %d, %v, want %d", id2, err, id+1)
+	}
+
+	var slotsCount int
+	if err := s.db.QueryRow("SELECT slots_count FROM runs WHERE id = ?", id).Scan(&slotsCount); err != nil {
+		t.Fatal(err)
+	}
+	if slotsCount != 3 {
+		t.Errorf("slots_count = %d, want 3", slotsCount)
+	}
+
+	runs, err := s.List(Filter{Profile: "a"})
+	if err != nil || len(runs) != 1 {
+		t.Fatalf("List() = %d runs, %v, want 1", len(runs), err)
+	}
+	run.ID = id
+	if !reflect.DeepEqual(runs[0], run) {
+		t.Errorf("saved run = %+v, want %+v", runs[0], run)
+	}
+
+	// Выполнение без доступных дат сохраняется с пустым списком
+	runs, err = s.List(Filter{Profile: "b"})
+	if err != nil || len(runs) != 1 || runs[0].Days == nil || len(runs[0].Days) != 0 {
+		t.Errorf("List(b) = %+v, %v, want run with empty days", runs, err)
+	}
+}
+
+func TestList(t *testing.T) {
+	s := openTestStore(t, filepath.Join(t.TempDir(), "history.db"))
+
+	base := time.Unix(10000, 0)
+	runs := []Run{
+		{Profile: "a", StartedAt: base, ProxyHost: "10.0.0.1"},
+		{Profile: "a", StartedAt: base.Add(time.Hour), ProxyHost: "10.0.0.2", ErrorClass: "too_many_requests", Error: "429"},
+		{Profile: "b", StartedAt: base.Add(2 * time.Hour), ProxyHost: "10.0.0.1", Available: true},
+		{Profile: "a", StartedAt: base.Add(3 * time.Hour), ProxyHost: "10.0.0.1", Available: true},
+		{Profile: "b", StartedAt: base.Add(4 * time.Hour), ProxyHost: "10.0.0.2", ErrorClass: "logged_out", Error: "logged out"},
+	}
+	for _, r := range runs {
+		if _, err := s.Save(r); err != nil {
+			t.Fatalf("Save() error: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		// want id записей в ожидаемом порядке (id совпадает с номером в runs + 1)
+		want []int64
+	}{
+		{name: "all newest first", filter: Filter{}, want: []int64{5, 4, 3, 2, 1}},
+		{name: "profile", filter: Filter{Profile: "a"}, want: []int64{4, 2, 1}},
+		{name: "unknown profile", filter: Filter{Profile: "c"}, want: nil},
+		{name: "proxy", filter: Filter{ProxyHost: "10.0.0.2"}, want: []int64{5, 2}},
+		{name: "since inclusive", filter: Filter{Since: base.Add(2 * time.Hour)}, want: []int64{5, 4, 3}},
+		{name: "only available", filter: Filter{OnlyAvailable: true}, want: []int64{4, 3}},
+		{name: "only errors", filter: Filter{OnlyErrors: true}, want: []int64{5, 2}},
+		{name: "profile and errors", filter: Filter{Profile: "b", OnlyErrors: true}, want: []int64{5}},
+		{name: "profile and since", filter: Filter{Profile: "a", Since: base.Add(time.Minute)}, want: []int64{4, 2}},
+		{name: "limit", filter: Filter{Limit: 2}, want: []int64{5, 4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.List(tt.filter)
+			if err != nil {
+				t.Fatalf("List() error: %v", err)
+			}
+
+			var ids []int64
+			for _, r := range got {
+				ids = append(ids, r.ID)
+			}
+			if !reflect.DeepEqual(ids, tt.want) {
+				t.Errorf("List(%+v) ids = %v, want %v", tt.filter, ids, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+)
+
+const namespace = "visasolution"
+
+// registry реестр метрик бота. Используется вместо глобального реестра prometheus,
+// чтобы в /metrics попадали только метрики бота и рантайма
+var registry = prometheus.NewRegistry()
+
+var (
+	// Runs количество выполнений Worker.Run по профилю и результату.
+	// outcome: available, not_available или класс ошибки
+	Runs = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "runs_total",
+		Help:      "Number of worker runs by profile and outcome.",
+	}, []string{"profile", "outcome"})
+
+	// Availability результат последней проверки доступности записи по профилю: 1 - доступна, 0 - нет
+	Availability = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "appointment_available",
+		Help:      "Result of the last availability check by profile (1 - available).",
+	}, []string{"profile"})
+
+	// OpenSlots количество свободных слотов, найденных при последней проверке, по профилю
+	OpenSlots = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "open_slots",
+		Help:      "Number of open appointment slots found by the last check by profile.",
+	}, []string{"profile"})
+
+	// CaptchaAttempts количество попыток решения капчи по результату: solved, invalid_selection, error
+	CaptchaAttempts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "captcha_attempts_total",
+		Help:      "Number of captcha solving attempts by result.",
+	}, []string{"result"})
+
+	// CaptchaAttemptsPerSolve количество попыток, потраченных на одну капчу. result: solved, failed
+	CaptchaAttemptsPerSolve = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "captcha_attempts_per_solve",
+		Help:      "Number of attempts spent on a single captcha.",
+		Buckets:   prometheus.LinearBuckets(1, 1, 10),
+	}, []string{"result"})
+
+	// TooManyRequests количество блокировок (apperr.ClassTooManyRequests) по хосту прокси
+	TooManyRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "too_many_requests_total",
+		Help:      "Number of too many requests errors by proxy host.",
+	}, []string{"proxy_host"})
+
+	// WebDriverConnects количество подключений к Selenium WebDriver по результату: success, error
+	WebDriverConnects = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webdriver_connects_total",
+		Help:      "Number of Selenium WebDriver connections by result.",
+	}, []string{"result"})
+
+	// WebDriverReconnects количество переподключений веб-драйвера после ошибки по классу ошибки (apperr)
+	WebDriverReconnects = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webdriver_reconnects_total",
+		Help:      "Number of WebDriver reconnects after a run error by reason.",
+	}, []string{"reason"})
+
+	// RetryDelay задержка перед повтором после ошибки выполнения по классу ошибки
+	RetryDelay = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "retry_delay_seconds",
+		Help:      "Backoff delay before retrying after a run error by error class.",
+		Buckets:   []float64{10, 30, 60, 120, 300, 600, 900, 1800},
+	}, []string{"class"})
+
+	// BreakerTrips количество срабатываний автоматического выключателя (пауза после ошибок подряд)
+	BreakerTrips = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "circuit_breaker_trips_total",
+		Help:      "Number of times the main loop was paused after consecutive run errors.",
+	})
+
+	// PhaseDuration длительность этапов Worker.Run
+	PhaseDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "phase_duration_seconds",
+		Help:      "Duration of worker run phases.",
+		Buckets:   []float64{1, 2, 5, 10, 20, 30, 60, 120, 300},
+	}, []string{"phase"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		Runs,
+		Availability,
+		OpenSlots,
+		CaptchaAttempts,
+		CaptchaAttemptsPerSolve,
+		TooManyRequests,
+		WebDriverConnects,
+		WebDriverReconnects,
+		RetryDelay,
+		BreakerTrips,
+		PhaseDuration,
+	)
+}
+
+// Handler возвращает обработчик, отдающий метрики в текстовом формате Prometheus
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,327 @@
+package notify
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"visasolution/internal/service"
+	"visasolution/pkg/util"
+)
+
+const stateFilePerm = 0644
+
+// maxDigestErrors количество последних ошибок, которые попадают в сводку
+const maxDigestErrors = 5
+
+// Event результат одного выполнения воркера
+type Event struct {
+	Profile string
+	Email   string
+	At      time.Time
+	// Checked true, если выполнение дошло до проверки доступности записи
+	Checked   bool
+	Available bool
+	Err       error
+	// ScreenshotPath путь к скриншоту страницы. Может быть пустым
+	ScreenshotPath string
+
+	// VisaCategory категория визы, для которой проверялась доступность записи
+	VisaCategory string
+	// Days доступные даты и слоты
+	Days []service.AppointmentDay
+
+	// Booking результат автоматического бронирования. nil, если бронирование не выполнялось
+	Booking *Booking
+}
+
+// Booking результат автоматического бронирования
+type Booking struct {
+	Date           time.Time
+	Slot           string
+	DryRun         bool
+	Reference      string
+	ScreenshotPath string
+}
+
+// profileState последнее известное состояние профиля и статистика для сводки
+type profileState struct {
+	Email string `json:"email"`
+
+	// Known false, пока доступность записи ни разу не была проверена
+	Known     bool      `json:"known"`
+	Available bool      `json:"available"`
+	ChangedAt time.Time `json:"changed_at"`
+
+	DigestSince   time.Time `json:"digest_since"`
+	Runs          int       `json:"runs"`
+	Errors        int       `json:"errors"`
+	AvailableRuns int       `json:"available_runs"`
+	LastErrors    []string  `json:"last_errors"`
+}
+
+type state struct {
+	Profiles     map[string]*profileState `json:"profiles"`
+	LastDigestAt time.Time                `json:"last_digest_at"`
+}
+
+// Policy решает, когда отправлять уведомления:
+// - сразу, когда запись появилась (доступность изменилась с false на true);
+// - один раз, когда запись пропала (с true на false);
+// - в остальных случаях раз в день отправляется сводка с количеством выполнений и ошибок.
+//
+// Последнее известное состояние сохраняется в файл, чтобы перезапуск не приводил к повторным уведомлениям
+type Policy struct {
+	mu sync.Mutex
+
+	notifier   service.Notifier
+	statePath  string
+	digestHour int
+	st         state
+}
+
+// NewPolicy создает политику уведомлений и загружает сохраненное состояние из statePath, если оно есть.
+// digestHour - час (по локальному времени), начиная с которого отправляется ежедневная сводка
+func NewPolicy(notifier service.Notifier, statePath string, digestHour int) (*Policy, error) {
+	if digestHour < 0 || digestHour > 23 {
+		return nil, fmt.Errorf("invalid digest hour %d", digestHour)
+	}
+
+	p := &Policy{
+		notifier:   notifier,
+		statePath:  statePath,
+		digestHour: digestHour,
+		st:         state{Profiles: make(map[string]*profileState)},
+	}
+
+	data, err := os.ReadFile(statePath)
+	if errors.Is(err, os.ErrNotExist) {
+		// Первая сводка отправляется не раньше, чем через сутки после первого запуска
+		p.st.LastDigestAt = time.Now()
+		return p, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read notify state error: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &p.st); err != nil {
+		return nil, fmt.Errorf("unmarshal notify state error: %w", err)
+	}
+	if p.st.Profiles == nil {
+		p.st.Profiles = make(map[string]*profileState)
+	}
+
+	return p, nil
+}
+
+// Observe учитывает результат выполнения и отправляет уведомления, если это требуется политикой
+func (p *Policy) Observe(e Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	ps, ok := p.st.Profiles[e.Profile]
+	if !ok {
+		ps = &profileState{DigestSince: e.At}
+		p.st.Profiles[e.Profile] = ps
+	}
+	ps.Email = e.Email
+
+	ps.Runs++
+	if e.Err != nil {
+		ps.Errors++
+		ps.LastErrors = append(ps.LastErrors, fmt.Sprintf("%s: %v", e.At.Format(time.DateTime), e.Err))
+		if len(ps.LastErrors) > maxDigestErrors {
+			ps.LastErrors = ps.LastErrors[len(ps.LastErrors)-maxDigestErrors:]
+		}
+	}
+
+	var errs []error
+
+	if e.Checked {
+		if e.Available {
+			ps.AvailableRuns++
+		}
+
+		// При первой проверке уведомление отправляется только о доступной записи
+		changed := ps.Known && ps.Available != e.Available || !ps.Known && e.Available
+
+		// Если уведомление не отправлено, состояние не меняется, чтобы при следующем выполнении отправить его снова
+		var err error
+		if changed {
+			err = p.notifyChange(e)
+			errs = append(errs, err)
+		}
+		if err == nil {
+			ps.Known = true
+			if ps.Available != e.Available {
+				ps.ChangedAt = e.At
+			}
+			ps.Available = e.Available
+		}
+	}
+
+	if e.Booking != nil && e.Err == nil {
+		errs = append(errs, p.notifyBooking(e))
+	}
+
+	if p.digestDue(e.At) {
+		errs = append(errs, p.sendDigests(e.At))
+	}
+
+	errs = append(errs, p.save())
+
+	return errors.Join(errs...)
+}
+
+// notifyChange отправляет уведомление об изменении доступности записи
+func (p *Policy) notifyChange(e Event) error {
+	n := service.Notification{
+		Profile:        e.Profile,
+		Email:          e.Email,
+		ScreenshotPath: e.ScreenshotPath,
+	}
+	if e.Available {
+		n.Kind = service.NotificationAvailable
+		n.Text = fmt.Sprintf("VisaSolution | %s: появилась запись на подачу документов!", e.Profile) + slotsText(e)
+	} else {
+		n.Kind = service.NotificationUnavailable
+		n.Text = fmt.Sprintf("VisaSolution | %s: свободных мест для записи больше нет", e.Profile)
+	}
+
+	log.Printf("Availability changed for profile %s: %v, sending notification\n", e.Profile, e.Available)
+
+	return p.notifier.Notify(n)
+}
+
+// notifyBooking отправляет уведомление о результате бронирования. Отправляется всегда, независимо от изменения доступности
+func (p *Policy) notifyBooking(e Event) error {
+	b := e.Booking
+
+	var text string
+	if b.DryRun {
+		text = fmt.Sprintf("VisaSolution | %s: dry run, бронирование остановлено перед подтверждением. Выбран слот %s %s",
+			e.Profile, b.Date.Format(time.DateOnly), b.Slot)
+	} else {
+		text = fmt.Sprintf("VisaSolution | %s: запись забронирована на %s %s. Номер записи: %s",
+			e.Profile, b.Date.Format(time.DateOnly), b.Slot, b.Reference)
+	}
+
+	return p.notifier.Notify(service.Notification{
+		Kind:           service.NotificationBooked,
+		Profile:        e.Profile,
+		Email:          e.Email,
+		Text:           text,
+		ScreenshotPath: b.ScreenshotPath,
+	})
+}
+
+// digestAt возвращает время отправки сводки в день now
+func (p *Policy) digestAt(now time.Time) time.Time {
+	return time.Date(now.Year(), now.Month(), now.Day(), p.digestHour, 0, 0, 0, now.Location())
+}
+
+// digestDue проверяет, пора ли отправлять ежедневную сводку
+func (p *Policy) digestDue(now time.Time) bool {
+	digestAt := p.digestAt(now)
+	return !now.Before(digestAt) && p.st.LastDigestAt.Before(digestAt)
+}
+
+// sendDigests отправляет сводку по каждому профилю и сбрасывает его статистику.
+// Если сводку отправить не удалось, статистика профиля сохраняется, и отправка повторяется при следующем выполнении.
+// Профили, которым сводка за этот день уже отправлена, пропускаются
+func (p *Policy) sendDigests(now time.Time) error {
+	var errs []error
+	digestAt := p.digestAt(now)
+
+	for name, ps := range p.st.Profiles {
+		if ps.Runs == 0 || !ps.DigestSince.Before(digestAt) {
+			continue
+		}
+
+		err := p.notifier.Notify(service.Notification{
+			Kind:    service.NotificationDigest,
+			Profile: name,
+			Email:   ps.Email,
+			Text:    digestText(name, ps, now),
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("send digest for profile %s error: %w", name, err))
+			continue
+		}
+
+		ps.DigestSince = now
+		ps.Runs, ps.Errors, ps.AvailableRuns = 0, 0, 0
+		ps.LastErrors = nil
+	}
+
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	p.st.LastDigestAt = now
+	log.Println("Daily digest sent")
+
+	return nil
+}
+
+// slotsText возвращает описание найденных дат и слотов для уведомления
+func slotsText(e Event) string {
+	var b strings.Builder
+
+	if e.VisaCategory != "" {
+		fmt.Fprintf(&b, "\nКатегория: %s", e.VisaCategory)
+	}
+	if len(e.Days) == 0 {
+		return b.String()
+	}
+
+	fmt.Fprintf(&b, "\nДат: %d, слотов: %d", len(e.Days), service.CountSlots(e.Days))
+	for _, day := range e.Days {
+		fmt.Fprintf(&b, "\n%s: %s", day.Date.Format(time.DateOnly), strings.Join(day.Slots, ", "))
+	}
+
+	return b.String()
+}
+
+func digestText(name string, ps *profileState, now time.Time) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "VisaSolution | %s: сводка с %s по %s\n", name, ps.DigestSince.Format(time.DateTime), now.Format(time.DateTime))
+	fmt.Fprintf(&b, "Выполнений: %d, с ошибкой: %d, запись была доступна: %d\n", ps.Runs, ps.Errors, ps.AvailableRuns)
+
+	switch {
+	case !ps.Known:
+		b.WriteString("Доступность записи еще не проверялась\n")
+	case ps.Available:
+		fmt.Fprintf(&b, "Запись доступна с %s\n", ps.ChangedAt.Format(time.DateTime))
+	default:
+		b.WriteString("Свободных мест для записи нет\n")
+	}
+
+	if len(ps.LastErrors) > 0 {
+		b.WriteString("Последние ошибки:\n")
+		for _, e := range ps.LastErrors {
+			fmt.Fprintf(&b, "- %s\n", e)
+		}
+	}
+
+	return b.String()
+}
+
+// save атомарно сохраняет состояние в файл
+func (p *Policy) save() error {
+	data, err := json.MarshalIndent(p.st, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal notify state error: %w", err)
+	}
+
+	if err := util.WriteFileAtomic(p.statePath, data, stateFilePerm); err != nil {
+		return fmt.Errorf("write notify state error: %w", err)
+	}
+
+	return nil
+}
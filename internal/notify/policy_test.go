@@ -0,0 +1,109 @@
+package notify
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+	"visasolution/internal/service"
+)
+
+// fakeNotifier запоминает уведомления и возвращает err, пока он задан
+type fakeNotifier struct {
+	sent []service.Notification
+	err  error
+}
+
+func (f *fakeNotifier) Notify(n service.Notification) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.sent = append(f.sent, n)
+	return nil
+}
+
+func newTestPolicy(t *testing.T, notifier service.Notifier, statePath string) *Policy {
+	t.Helper()
+
+	p, err := NewPolicy(notifier, statePath, 9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestPolicyRetriesFailedChangeNotification(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "notify.json")
+	notifier := &fakeNotifier{err: errors.New("smtp unavailable")}
+	p := newTestPolicy(t, notifier, statePath)
+
+	at := time.Now()
+	event := Event{Profile: "main", At: at, Checked: true, Available: true}
+	if err := p.Observe(event); err == nil {
+		t.Fatal("Observe() error = nil, want notifier error")
+	}
+
+	// Состояние "запись доступна" не сохранено, поэтому после перезапуска уведомление отправляется снова
+	p = newTestPolicy(t, notifier, statePath)
+	notifier.err = nil
+	event.At = at.Add(time.Minute)
+	if err := p.Observe(event); err != nil {
+		t.Fatalf("Observe() error: %v", err)
+	}
+	if len(notifier.sent) != 1 || notifier.sent[0].Kind != service.NotificationAvailable {
+		t.Fatalf("sent = %+v, want one availability notification", notifier.sent)
+	}
+
+	// Доступность не изменилась, повторного уведомления нет
+	event.At = at.Add(2 * time.Minute)
+	if err := p.Observe(event); err != nil {
+		t.Fatalf("Observe() error: %v", err)
+	}
+	if len(notifier.sent) != 1 {
+		t.Errorf("sent %d notifications, want 1", len(notifier.sent))
+	}
+}
+
+func TestPolicyRetriesFailedDigest(t *testing.T) {
+	notifier := &fakeNotifier{}
+	p := newTestPolicy(t, notifier, filepath.Join(t.TempDir(), "notify.json"))
+
+	day := time.Date(2024, 5, 14, 0, 0, 0, 0, time.Local)
+	p.st.LastDigestAt = day.Add(-24 * time.Hour)
+
+	observe := func(at time.Time) error {
+		return p.Observe(Event{Profile: "main", At: at, Err: errors.New("timeout")})
+	}
+
+	// Вчерашние выполнения попадают в сводку
+	if err := observe(day.Add(-time.Hour)); err != nil {
+		t.Fatalf("Observe() error: %v", err)
+	}
+
+	notifier.err = errors.New("smtp unavailable")
+	if err := observe(day.Add(9 * time.Hour)); err == nil {
+		t.Fatal("Observe() error = nil, want digest error")
+	}
+	if ps := p.st.Profiles["main"]; ps.Runs != 2 || ps.Errors != 2 {
+		t.Errorf("runs = %d, errors = %d after failed digest, want statistics kept", ps.Runs, ps.Errors)
+	}
+
+	notifier.err = nil
+	if err := observe(day.Add(10 * time.Hour)); err != nil {
+		t.Fatalf("Observe() error: %v", err)
+	}
+	if len(notifier.sent) != 1 || notifier.sent[0].Kind != service.NotificationDigest {
+		t.Fatalf("sent = %+v, want one digest", notifier.sent)
+	}
+	if ps := p.st.Profiles["main"]; ps.Runs != 0 {
+		t.Errorf("runs = %d after digest, want 0", ps.Runs)
+	}
+
+	// Сводка за день уже отправлена
+	if err := observe(day.Add(11 * time.Hour)); err != nil {
+		t.Fatalf("Observe() error: %v", err)
+	}
+	if len(notifier.sent) != 1 {
+		t.Errorf("sent %d notifications, want 1", len(notifier.sent))
+	}
+}
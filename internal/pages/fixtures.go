@@ -0,0 +1,163 @@
+package pages
+
+import (
+	"errors"
+	"fmt"
+	"github.com/andybalholm/cascadia"
+	"github.com/antchfx/htmlquery"
+	"github.com/tebeka/selenium"
+	"golang.org/x/net/html"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Результаты проверки элемента по HTML фикстуре
+const (
+	// FixtureOK элемент найден по основному селектору
+	FixtureOK = "ok"
+	// FixtureFallback элемент найден только по запасному селектору
+	FixtureFallback = "fallback"
+	// FixtureMissing элемент не найден ни по одному селектору
+	FixtureMissing = "missing"
+	// FixtureSkipped селекторы элемента - шаблоны, проверить их без id нельзя
+	FixtureSkipped = "skipped"
+	// FixtureNoFile для страницы нет файла фикстуры
+	FixtureNoFile = "no_fixture"
+)
+
+// FixtureResult результат проверки элемента набора селекторов по HTML фикстуре страницы
+type FixtureResult struct {
+	Page    string `json:"page"`
+	Element string `json:"element"`
+	Status  string `json:"status"`
+	// Selector селектор, по которому найден элемент
+	Selector string `json:"selector,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// ValidateFixtures проверяет набор селекторов по сохраненным HTML страницам из папки dir.
+// Фикстура страницы - файл <page>.html (например, login.html, captcha_frame.html).
+// Набор должен быть корректным (см. New)
+func ValidateFixtures(pack *Pack, dir string) ([]FixtureResult, error) {
+	if _, err := New(pack); err != nil {
+		return nil, err
+	}
+
+	pageNames := make([]string, 0, len(pack.Pages))
+	for page := range pack.Pages {
+		pageNames = append(pageNames, page)
+	}
+	sort.Strings(pageNames)
+
+	var results []FixtureResult
+	for _, page := range pageNames {
+		data, err := os.ReadFile(filepath.Join(dir, page+".html"))
+		if errors.Is(err, os.ErrNotExist) {
+			results = append(results, FixtureResult{Page: page, Status: FixtureNoFile})
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read fixture: %w", err)
+		}
+
+		doc, err := html.Parse(strings.NewReader(string(data)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse fixture %s.html: %w", page, err)
+		}
+
+		elementNames := make([]string, 0, len(pack.Pages[page]))
+		for name := range pack.Pages[page] {
+			elementNames = append(elementNames, name)
+		}
+		sort.Strings(elementNames)
+
+		for _, name := range elementNames {
+			e, err := pack.Element(page, name)
+			if err != nil {
+				return nil, err
+			}
+			results = append(results, checkElement(doc, e))
+		}
+	}
+
+	return results, nil
+}
+
+// CheckFixtures возвращает ошибку, если хотя бы один элемент не найден в фикстуре
+func CheckFixtures(results []FixtureResult) error {
+	var missing []string
+	for _, r := range results {
+		if r.Status == FixtureMissing {
+			missing = append(missing, r.Page+"."+r.Element)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("elements not found in fixtures: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// checkElement ищет элемент в HTML документе по селекторам по порядку
+func checkElement(doc *html.Node, e Element) FixtureResult {
+	result := FixtureResult{Page: e.Page, Element: e.Name, Status: FixtureMissing}
+	if e.IsTemplate() {
+		result.Status = FixtureSkipped
+		return result
+	}
+
+	var errs []string
+	for i, s := range e.Selectors {
+		found, err := matchSelector(doc, s)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		if found {
+			result.Status = FixtureOK
+			if i > 0 {
+				result.Status = FixtureFallback
+			}
+			result.Selector = s.String()
+			return result
+		}
+	}
+
+	result.Error = strings.Join(errs, "; ")
+	return result
+}
+
+// matchSelector проверяет, есть ли в документе элемент, подходящий под селектор
+func matchSelector(doc *html.Node, s Selector) (bool, error) {
+	nodes, err := QueryAll(doc, s)
+	return len(nodes) > 0, err
+}
+
+// QueryAll возвращает узлы HTML документа (или поддерева root), подходящие под селектор, так же как их искал бы Selenium
+func QueryAll(root *html.Node, s Selector) ([]*html.Node, error) {
+	switch s.By {
+	case selenium.ByCSSSelector:
+		sel, err := cascadia.Parse(s.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid css selector '%s': %w", s.Value, err)
+		}
+		return cascadia.QueryAll(root, sel), nil
+	case selenium.ByID:
+		return queryXPath(root, fmt.Sprintf(`.//*[@id=%q]`, s.Value))
+	case selenium.ByName:
+		return queryXPath(root, fmt.Sprintf(`.//*[@name=%q]`, s.Value))
+	case selenium.ByTagName:
+		return queryXPath(root, ".//"+s.Value)
+	default:
+		return queryXPath(root, s.Value)
+	}
+}
+
+func queryXPath(root *html.Node, expr string) ([]*html.Node, error) {
+	nodes, err := htmlquery.QueryAll(root, expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid xpath '%s': %w", expr, err)
+	}
+	return nodes, nil
+}
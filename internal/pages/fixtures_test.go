@@ -0,0 +1,259 @@
+package pages
+
+import (
+	"github.com/tebeka/selenium"
+	"golang.org/x/net/html"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// replayPagesDir страницы сайта из записей, по которым проходят тесты воркера
+var replayPagesDir = filepath.Join("..", "worker", "testdata", "replay", "common")
+
+// replayFixtures сопоставляет страницам набора файлы из replayPagesDir
+var replayFixtures = map[string]string{
+	PageLogin:        "login.html",
+	PageCaptchaFrame: "captcha_frame.html",
+	PageVisaType:     "visa_type.html",
+	PageBookNew:      "book_new.html",
+	PageAvailability: "availability_none.html",
+	PageBooking:      "slots_month0.html",
+}
+
+// replayFixturesDir копирует страницы из записей во временную директорию фикстур под именами <page>.html
+func replayFixturesDir(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for page, file := range replayFixtures {
+		data, err := os.ReadFile(filepath.Join(replayPagesDir, file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, page+".html"), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func parseReplayPage(t *testing.T, file string) *html.Node {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join(replayPagesDir, file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := html.Parse(strings.NewReader(string(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func TestValidateFixturesDefaultPack(t *testing.T) {
+	results, err := ValidateFixtures(DefaultPack(), replayFixturesDir(t))
+	if err != nil {
+		t.Fatalf("ValidateFixtures() error: %v", err)
+	}
+
+	// В записях нет страницы данных заявителя и меню со ссылкой "Book new"
+	notRecorded := map[string]bool{
+		"visa_type.book_new_link":    true,
+		"booking.first_applicant":    true,
+		"booking.consent_checkboxes": true,
+	}
+
+	got := make(map[string]string)
+	for _, r := range results {
+		key := r.Page + "." + r.Element
+		got[key] = r.Status
+
+		var want string
+		switch {
+		case r.Element == "":
+			want = FixtureNoFile
+		case notRecorded[key]:
+			want = FixtureMissing
+		case isTemplate(t, r.Page, r.Element):
+			want = FixtureSkipped
+		default:
+			want = FixtureOK
+		}
+		if r.Status != want {
+			t.Errorf("%s: status = %s, want %s (selector %q, error %q)", key, r.Status, want, r.Selector, r.Error)
+		}
+	}
+
+	// Страницы без фикстуры попадают в результат, все элементы страниц с фикстурой проверены
+	for page, elements := range DefaultPack().Pages {
+		if _, ok := replayFixtures[page]; !ok {
+			if got[page+"."] != FixtureNoFile {
+				t.Errorf("page %s without fixture: status = %q, want %s", page, got[page+"."], FixtureNoFile)
+			}
+			continue
+		}
+		for name := range elements {
+			if _, ok := got[page+"."+name]; !ok {
+				t.Errorf("element %s.%s not validated", page, name)
+			}
+		}
+	}
+
+	if err := CheckFixtures(results); err == nil || !strings.Contains(err.Error(), "booking.first_applicant") {
+		t.Errorf("CheckFixtures() error = %v, want missing booking.first_applicant", err)
+	}
+}
+
+func isTemplate(t *testing.T, page, name string) bool {
+	t.Helper()
+
+	e, err := DefaultPack().Element(page, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e.IsTemplate()
+}
+
+// Селекторы-шаблоны ValidateFixtures пропускает, поэтому они проверяются с id из записей
+func TestDefaultPackTemplates(t *testing.T) {
+	p := Default()
+
+	tests := []struct {
+		name    string
+		file    string
+		element Element
+		want    int
+	}{
+		{name: "dropdown opener", file: "book_new.html", element: p.BookNew.DropdownOpener.With("JurisdictionId1"), want: 1},
+		{name: "dropdown options", file: "book_new.html", element: p.BookNew.DropdownOptions.With("JurisdictionId1"), want: 2},
+		{name: "slot dropdown options", file: "slots_month0.html", element: p.BookNew.DropdownOptions.With("AppointmentSlot1"), want: 3},
+		{name: "calendar opener", file: "slots_month0.html", element: p.Booking.CalendarOpener.With("AppointmentDate1"), want: 1},
+		// Отключенный день календаря не выбирается
+		{name: "calendar days", file: "slots_month0.html", element: p.Booking.CalendarDays.With("AppointmentDate1"), want: 1},
+		{name: "calendar next", file: "slots_month0.html", element: p.Booking.CalendarNext.With("AppointmentDate1"), want: 1},
+		{name: "calendar of another input", file: "slots_month0.html", element: p.Booking.CalendarDays.With("AppointmentDate2"), want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.element.IsTemplate() {
+				t.Fatalf("element %s is still a template", tt.element)
+			}
+
+			nodes, err := QueryAll(parseReplayPage(t, tt.file), tt.element.Selectors[0])
+			if err != nil {
+				t.Fatalf("QueryAll() error: %v", err)
+			}
+			if len(nodes) != tt.want {
+				t.Errorf("QueryAll(%s) found %d nodes, want %d", tt.element.Selectors[0], len(nodes), tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryAll(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(`<html><body>
+<form id="login"><input name="Email" class="field"><input name="Password" class="field"><button id="btnSubmit">Login</button></form>
+<div id="other"><input name="Search"></div>
+</body></html>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	form, err := QueryAll(doc, Selector{By: selenium.ByID, Value: "login"})
+	if err != nil || len(form) != 1 {
+		t.Fatalf("QueryAll(id=login) = %d nodes, %v", len(form), err)
+	}
+
+	tests := []struct {
+		name     string
+		root     *html.Node
+		selector string
+		want     int
+		wantErr  string
+	}{
+		{name: "xpath", root: doc, selector: `xpath=//form//input`, want: 2},
+		{name: "css", root: doc, selector: `css=form > input.field`, want: 2},
+		{name: "id", root: doc, selector: `id=btnSubmit`, want: 1},
+		{name: "name", root: doc, selector: `name=Email`, want: 1},
+		{name: "tag", root: doc, selector: `tag=input`, want: 3},
+		{name: "not found", root: doc, selector: `id=btnVerify`, want: 0},
+		{name: "tag in subtree", root: form[0], selector: `tag=input`, want: 2},
+		{name: "name in subtree", root: form[0], selector: `name=Search`, want: 0},
+		{name: "invalid xpath", root: doc, selector: `xpath=//form[`, wantErr: "invalid xpath"},
+		{name: "invalid css", root: doc, selector: `css=form >`, wantErr: "invalid css selector"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := ParseSelector(tt.selector)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			nodes, err := QueryAll(tt.root, s)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("QueryAll() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("QueryAll() error: %v", err)
+			}
+			if len(nodes) != tt.want {
+				t.Errorf("QueryAll() found %d nodes, want %d", len(nodes), tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateFixturesFallbackOrder(t *testing.T) {
+	dir := t.TempDir()
+	page := `<html><body><form id="login"><button id="btnSubmit">Login</button></form></body></html>`
+	if err := os.WriteFile(filepath.Join(dir, PageLogin+".html"), []byte(page), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	pack := &Pack{Name: "test", Pages: map[string]map[string][]string{
+		PageLogin: {
+			// Основной селектор находит элемент, запасной не проверяется
+			"primary": {"id=btnSubmit", "tag=button"},
+			// Основной селектор устарел
+			"fallback": {"id=btnLogin", "css=#login button", "tag=button"},
+			// Некорректный основной селектор не мешает найти элемент по запасному
+			"invalid_primary": {"xpath=//form[", "tag=button"},
+			"missing":         {"id=btnLogin", "xpath=//form[", "name=Login"},
+			"template":        {"xpath=//*[@aria-owns=\"{id}_listbox\"]"},
+		},
+	}}
+
+	results, err := ValidateFixtures(pack.WithDefaults(DefaultPack()), dir)
+	if err != nil {
+		t.Fatalf("ValidateFixtures() error: %v", err)
+	}
+
+	want := map[string]FixtureResult{
+		"primary":         {Status: FixtureOK, Selector: "id=btnSubmit"},
+		"fallback":        {Status: FixtureFallback, Selector: "css=#login button"},
+		"invalid_primary": {Status: FixtureFallback, Selector: "tag=button"},
+		"missing":         {Status: FixtureMissing, Error: "invalid xpath"},
+		"template":        {Status: FixtureSkipped},
+	}
+	for _, r := range results {
+		w, ok := want[r.Element]
+		if r.Page != PageLogin || !ok {
+			continue
+		}
+		delete(want, r.Element)
+
+		if r.Status != w.Status || r.Selector != w.Selector || !strings.Contains(r.Error, w.Error) {
+			t.Errorf("%s: got %+v, want %+v", r.Element, r, w)
+		}
+	}
+	for name := range want {
+		t.Errorf("element %s not validated", name)
+	}
+}
@@ -0,0 +1,113 @@
+package pages
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// defaultPackData набор селекторов по умолчанию, встроенный в бинарник
+//
+//go:embed packs/default.yaml
+var defaultPackData []byte
+
+// Pack именованный набор селекторов: страница -> элемент -> селекторы (основной и запасные)
+type Pack struct {
+	Name    string                         `json:"name" yaml:"name"`
+	Version int                            `json:"version" yaml:"version"`
+	Pages   map[string]map[string][]string `json:"pages" yaml:"pages"`
+}
+
+// DefaultPack возвращает встроенный набор селекторов
+func DefaultPack() *Pack {
+	pack, err := ParsePack("default.yaml", defaultPackData)
+	if err != nil {
+		panic(fmt.Sprintf("invalid default selector pack: %v", err))
+	}
+	return pack
+}
+
+// LoadPack загружает набор селекторов из файла. Элементы, которых нет в файле, берутся из встроенного набора,
+// поэтому в файле достаточно указать только изменившиеся селекторы
+func LoadPack(filePath string) (*Pack, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read selector pack: %w", err)
+	}
+
+	pack, err := ParsePack(filePath, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return pack.WithDefaults(DefaultPack()), nil
+}
+
+// ParsePack разбирает набор селекторов. Формат определяется по расширению: .yaml/.yml или .json
+func ParsePack(filePath string, data []byte) (*Pack, error) {
+	var pack Pack
+
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, &pack); err != nil {
+			return nil, fmt.Errorf("failed to parse yaml selector pack: %w", err)
+		}
+	case ".json":
+		if err := json.Unmarshal(data, &pack); err != nil {
+			return nil, fmt.Errorf("failed to parse json selector pack: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported selector pack format '%s', expected .yaml, .yml or .json", filepath.Ext(filePath))
+	}
+
+	if pack.Name == "" {
+		return nil, fmt.Errorf("selector pack name is required")
+	}
+
+	return &pack, nil
+}
+
+// WithDefaults возвращает копию набора, дополненную элементами base, которых нет в наборе
+func (p *Pack) WithDefaults(base *Pack) *Pack {
+	merged := &Pack{Name: p.Name, Version: p.Version, Pages: make(map[string]map[string][]string)}
+
+	for _, src := range []*Pack{base, p} {
+		for page, elements := range src.Pages {
+			if merged.Pages[page] == nil {
+				merged.Pages[page] = make(map[string][]string)
+			}
+			for name, selectors := range elements {
+				merged.Pages[page][name] = selectors
+			}
+		}
+	}
+
+	return merged
+}
+
+// Element возвращает элемент страницы page с селекторами из набора
+func (p *Pack) Element(page, name string) (Element, error) {
+	raw := p.Pages[page][name]
+	if len(raw) == 0 {
+		return Element{}, fmt.Errorf("selector pack '%s' has no selectors for %s.%s", p.Name, page, name)
+	}
+
+	e := Element{Page: page, Name: name}
+	for _, s := range raw {
+		selector, err := ParseSelector(s)
+		if err != nil {
+			return Element{}, fmt.Errorf("%s.%s: %w", page, name, err)
+		}
+		e.Selectors = append(e.Selectors, selector)
+	}
+
+	return e, nil
+}
+
+func (p *Pack) String() string {
+	return fmt.Sprintf("%s v%d", p.Name, p.Version)
+}
@@ -0,0 +1,190 @@
+package pages
+
+import (
+	"errors"
+)
+
+// Страницы в наборе селекторов. Имя страницы совпадает с именем файла HTML фикстуры (<page>.html)
+const (
+	PageLogin        = "login"
+	PageCaptcha      = "captcha"
+	PageCaptchaFrame = "captcha_frame"
+	PageVisaType     = "visa_type"
+	PageBookNew      = "book_new"
+	PageAvailability = "availability"
+	PageSession      = "session"
+	PageBooking      = "booking"
+)
+
+// LoginPage форма авторизации
+type LoginPage struct {
+	// Inputs input'ы формы, среди которых есть фейковые. Настоящие (email, пароль) отмечены атрибутом required
+	Inputs Element
+	Submit Element
+}
+
+// CaptchaPopup всплывающее окно капчи
+type CaptchaPopup struct {
+	// VerifyButton кнопка, открывающая капчу
+	VerifyButton Element
+	IFrame       Element
+	// Window перетаскиваемое окно, в котором находится iframe
+	Window Element
+
+	// Элементы внутри iframe (страница captcha_frame)
+	Container Element
+	CardImage Element
+	Submit    Element
+}
+
+// VisaTypePage страница Visa Type Verification
+type VisaTypePage struct {
+	// Submit кнопка перехода к форме "Book New Appointment"
+	Submit Element
+	// BookNewLink ссылка "Book new" в меню
+	BookNewLink Element
+}
+
+// BookNewForm форма "Book New Appointment"
+type BookNewForm struct {
+	Form Element
+	// Controls контейнеры полей формы, первые два служебные
+	Controls Element
+	// DropdownOpener элемент, открывающий выпадающий список для input'а. Шаблон: нужен With(inputId)
+	DropdownOpener Element
+	// DropdownOptions опции выпадающего списка для input'а. Шаблон: нужен With(inputId)
+	DropdownOptions Element
+	Submit          Element
+}
+
+// AvailabilityModal модальное окно с результатом проверки доступности записи
+type AvailabilityModal struct {
+	Modal Element
+	// Header заголовок, ищется внутри Modal
+	Header Element
+}
+
+// SessionMarkers признаки состояния авторизации, которые ищутся на любой странице сайта
+type SessionMarkers struct {
+	// LoggedIn элемент, который есть только у авторизованного пользователя (ссылка выхода)
+	LoggedIn Element
+	// LoggedOut элемент, который есть только у неавторизованного пользователя (форма авторизации)
+	LoggedOut Element
+}
+
+// BookingPages страницы бронирования: выбор слота, данные заявителя и подтверждение.
+// Выпадающий список слотов и кнопка капчи общие с формой "Book New Appointment"
+type BookingPages struct {
+	// Inputs input'ы формы на страницах выбора слота и данных заявителя
+	Inputs Element
+	// CalendarOpener кнопка, открывающая календарь (kendo datepicker) для input'а. Шаблон: нужен With(inputId)
+	CalendarOpener Element
+	// CalendarDays доступные для выбора дни текущего месяца календаря с атрибутом data-value. Шаблон: нужен With(inputId)
+	CalendarDays Element
+	// CalendarNext кнопка перехода к следующему месяцу календаря. Шаблон: нужен With(inputId)
+	CalendarNext Element
+	// SlotSubmit кнопка отправки формы выбора слота
+	SlotSubmit Element
+
+	// ApplicantLabel метка заявителя на странице данных заявителя. Шаблон: нужен WithText(имя заявителя)
+	ApplicantLabel Element
+	// FirstApplicant переключатель первого заявителя
+	FirstApplicant Element
+	// ConsentCheckboxes чекбоксы согласий
+	ConsentCheckboxes Element
+	// Confirm кнопка финального подтверждения записи
+	Confirm Element
+}
+
+// Pages объекты страниц сайта BLS, селекторы которых берутся из набора
+type Pages struct {
+	Pack string
+
+	Login        LoginPage
+	Captcha      CaptchaPopup
+	VisaType     VisaTypePage
+	BookNew      BookNewForm
+	Availability AvailabilityModal
+	Session      SessionMarkers
+	Booking      BookingPages
+}
+
+// New создает объекты страниц по набору селекторов.
+// Возвращает все ошибки сразу, если в наборе нет нужных элементов или селекторы некорректны
+func New(pack *Pack) (*Pages, error) {
+	b := builder{pack: pack}
+
+	p := &Pages{
+		Pack: pack.String(),
+		Login: LoginPage{
+			Inputs: b.element(PageLogin, "inputs"),
+			Submit: b.element(PageLogin, "submit"),
+		},
+		Captcha: CaptchaPopup{
+			VerifyButton: b.element(PageCaptcha, "verify_button"),
+			IFrame:       b.element(PageCaptcha, "iframe"),
+			Window:       b.element(PageCaptcha, "window"),
+			Container:    b.element(PageCaptchaFrame, "container"),
+			CardImage:    b.element(PageCaptchaFrame, "card_image"),
+			Submit:       b.element(PageCaptchaFrame, "submit"),
+		},
+		VisaType: VisaTypePage{
+			Submit:      b.element(PageVisaType, "submit"),
+			BookNewLink: b.element(PageVisaType, "book_new_link"),
+		},
+		BookNew: BookNewForm{
+			Form:            b.element(PageBookNew, "form"),
+			Controls:        b.element(PageBookNew, "controls"),
+			DropdownOpener:  b.element(PageBookNew, "dropdown_opener"),
+			DropdownOptions: b.element(PageBookNew, "dropdown_options"),
+			Submit:          b.element(PageBookNew, "submit"),
+		},
+		Availability: AvailabilityModal{
+			Modal:  b.element(PageAvailability, "modal"),
+			Header: b.element(PageAvailability, "header"),
+		},
+		Session: SessionMarkers{
+			LoggedIn:  b.element(PageSession, "logged_in"),
+			LoggedOut: b.element(PageSession, "logged_out"),
+		},
+		Booking: BookingPages{
+			Inputs:            b.element(PageBooking, "inputs"),
+			CalendarOpener:    b.element(PageBooking, "calendar_opener"),
+			CalendarDays:      b.element(PageBooking, "calendar_days"),
+			CalendarNext:      b.element(PageBooking, "calendar_next"),
+			SlotSubmit:        b.element(PageBooking, "slot_submit"),
+			ApplicantLabel:    b.element(PageBooking, "applicant_label"),
+			FirstApplicant:    b.element(PageBooking, "first_applicant"),
+			ConsentCheckboxes: b.element(PageBooking, "consent_checkboxes"),
+			Confirm:           b.element(PageBooking, "confirm"),
+		},
+	}
+
+	if len(b.errs) > 0 {
+		return nil, errors.Join(b.errs...)
+	}
+	return p, nil
+}
+
+// Default возвращает объекты страниц со встроенным набором селекторов
+func Default() *Pages {
+	p, err := New(DefaultPack())
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// builder собирает элементы из набора и накапливает ошибки
+type builder struct {
+	pack *Pack
+	errs []error
+}
+
+func (b *builder) element(page, name string) Element {
+	e, err := b.pack.Element(page, name)
+	if err != nil {
+		b.errs = append(b.errs, err)
+	}
+	return e
+}
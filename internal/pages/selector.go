@@ -0,0 +1,160 @@
+package pages
+
+import (
+	"errors"
+	"fmt"
+	"github.com/tebeka/selenium"
+	"log"
+	"strings"
+)
+
+// Подстановки в селекторах-шаблонах
+const (
+	// templateID id связанного элемента, например input'а выпадающего списка
+	templateID = "{id}"
+	// templateText видимый текст элемента, например имя заявителя
+	templateText = "{text}"
+)
+
+// selectorPrefixes префиксы селекторов в наборе и соответствующие им способы поиска Selenium
+var selectorPrefixes = map[string]string{
+	"xpath": selenium.ByXPATH,
+	"css":   selenium.ByCSSSelector,
+	"id":    selenium.ByID,
+	"name":  selenium.ByName,
+	"tag":   selenium.ByTagName,
+}
+
+// Selector способ поиска элемента на странице
+type Selector struct {
+	By    string
+	Value string
+}
+
+// ParseSelector разбирает селектор вида "xpath=//form", "css=#btnVerify", "id=btnSubmit", "name=Email" или "tag=body"
+func ParseSelector(s string) (Selector, error) {
+	prefix, value, ok := strings.Cut(s, "=")
+	by, known := selectorPrefixes[strings.TrimSpace(prefix)]
+	if !ok || !known {
+		return Selector{}, fmt.Errorf("invalid selector '%s', expected one of xpath=, css=, id=, name=, tag=", s)
+	}
+	if strings.TrimSpace(value) == "" {
+		return Selector{}, fmt.Errorf("empty selector '%s'", s)
+	}
+
+	return Selector{By: by, Value: value}, nil
+}
+
+func (s Selector) String() string {
+	for prefix, by := range selectorPrefixes {
+		if by == s.By {
+			return prefix + "=" + s.Value
+		}
+	}
+	return s.By + "=" + s.Value
+}
+
+// Finder ищет элементы. Реализуется selenium.WebDriver и selenium.WebElement
+type Finder interface {
+	FindElement(by, value string) (selenium.WebElement, error)
+	FindElements(by, value string) ([]selenium.WebElement, error)
+}
+
+// Element элемент страницы. Селекторы проверяются по порядку: первый основной, остальные запасные
+type Element struct {
+	Page      string
+	Name      string
+	Selectors []Selector
+}
+
+// XPath создает элемент с единственным XPath селектором
+func XPath(value string) Element {
+	return Element{Name: value, Selectors: []Selector{{By: selenium.ByXPATH, Value: value}}}
+}
+
+// ID создает элемент с единственным селектором по id
+func ID(value string) Element {
+	return Element{Name: value, Selectors: []Selector{{By: selenium.ByID, Value: value}}}
+}
+
+// Tag создает элемент с единственным селектором по имени тега
+func Tag(value string) Element {
+	return Element{Name: value, Selectors: []Selector{{By: selenium.ByTagName, Value: value}}}
+}
+
+// With возвращает элемент, в селекторах которого "{id}" заменен на id
+func (e Element) With(id string) Element {
+	return e.replace(templateID, id)
+}
+
+// WithText возвращает элемент, в селекторах которого "{text}" заменен на text
+func (e Element) WithText(text string) Element {
+	return e.replace(templateText, text)
+}
+
+func (e Element) replace(template, value string) Element {
+	selectors := make([]Selector, len(e.Selectors))
+	for i, s := range e.Selectors {
+		selectors[i] = Selector{By: s.By, Value: strings.ReplaceAll(s.Value, template, value)}
+	}
+	return Element{Page: e.Page, Name: e.Name, Selectors: selectors}
+}
+
+// IsTemplate проверяет, нужно ли подставить значения в селекторы элемента через With или WithText
+func (e Element) IsTemplate() bool {
+	for _, s := range e.Selectors {
+		if strings.Contains(s.Value, templateID) || strings.Contains(s.Value, templateText) {
+			return true
+		}
+	}
+	return false
+}
+
+// Find возвращает первый элемент, найденный по одному из селекторов.
+// Если элемент найден по запасному селектору, это выводится в лог: основной селектор, вероятно, устарел
+func (e Element) Find(f Finder) (selenium.WebElement, error) {
+	var errs []error
+	for i, s := range e.Selectors {
+		elem, err := f.FindElement(s.By, s.Value)
+		if err == nil {
+			if i > 0 {
+				log.Printf("Element %s found by fallback selector %s\n", e, s)
+			}
+			return elem, nil
+		}
+		errs = append(errs, err)
+	}
+
+	return nil, fmt.Errorf("element %s not found: %w", e, errors.Join(errs...))
+}
+
+// FindAll возвращает элементы, найденные по первому селектору, для которого есть совпадения.
+// Если совпадений нет ни для одного селектора, возвращается пустой срез
+func (e Element) FindAll(f Finder) ([]selenium.WebElement, error) {
+	var errs []error
+	for i, s := range e.Selectors {
+		elems, err := f.FindElements(s.By, s.Value)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if len(elems) > 0 {
+			if i > 0 {
+				log.Printf("Elements %s found by fallback selector %s\n", e, s)
+			}
+			return elems, nil
+		}
+	}
+
+	if len(errs) == len(e.Selectors) && len(errs) > 0 {
+		return nil, fmt.Errorf("find elements %s error: %w", e, errors.Join(errs...))
+	}
+	return nil, nil
+}
+
+func (e Element) String() string {
+	if e.Page == "" {
+		return "'" + e.Name + "'"
+	}
+	return "'" + e.Page + "." + e.Name + "'"
+}
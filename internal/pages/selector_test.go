@@ -0,0 +1,118 @@
+package pages
+
+import (
+	"errors"
+	"github.com/tebeka/selenium"
+	"reflect"
+	"testing"
+)
+
+// fakeFinder находит count элементов по селекторам из found, для селекторов из invalid возвращает ошибку.
+// Запросы записываются в calls
+type fakeFinder struct {
+	found   map[string]int
+	invalid map[string]bool
+	calls   []string
+}
+
+func (f *fakeFinder) FindElement(by, value string) (selenium.WebElement, error) {
+	elems, err := f.FindElements(by, value)
+	if err != nil {
+		return nil, err
+	}
+	if len(elems) == 0 {
+		return nil, errors.New("no such element")
+	}
+	return elems[0], nil
+}
+
+func (f *fakeFinder) FindElements(by, value string) ([]selenium.WebElement, error) {
+	s := Selector{By: by, Value: value}.String()
+	f.calls = append(f.calls, s)
+	if f.invalid[s] {
+		return nil, errors.New("invalid selector")
+	}
+	return make([]selenium.WebElement, f.found[s]), nil
+}
+
+func TestElementFallbackOrder(t *testing.T) {
+	pack := &Pack{Name: "test", Pages: map[string]map[string][]string{
+		PageLogin: {"submit": {"id=btnLogin", "xpath=//form[", "css=#login button", "tag=button"}},
+	}}
+	e, err := pack.Element(PageLogin, "submit")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		finder    *fakeFinder
+		wantCalls []string
+		wantCount int
+		wantErr   bool
+	}{
+		{
+			name:      "primary",
+			finder:    &fakeFinder{found: map[string]int{"id=btnLogin": 1, "tag=button": 2}},
+			wantCalls: []string{"id=btnLogin"},
+			wantCount: 1,
+		},
+		{
+			name:      "first matching fallback",
+			finder:    &fakeFinder{found: map[string]int{"css=#login button": 1, "tag=button": 2}, invalid: map[string]bool{"xpath=//form[": true}},
+			wantCalls: []string{"id=btnLogin", "xpath=//form[", "css=#login button"},
+			wantCount: 1,
+		},
+		{
+			name:      "not found",
+			finder:    &fakeFinder{invalid: map[string]bool{"xpath=//form[": true}},
+			wantCalls: []string{"id=btnLogin", "xpath=//form[", "css=#login button", "tag=button"},
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := e.Find(tt.finder)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Find() error = %v, want error %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(tt.finder.calls, tt.wantCalls) {
+				t.Errorf("Find() calls = %v, want %v", tt.finder.calls, tt.wantCalls)
+			}
+
+			tt.finder.calls = nil
+			elems, err := e.FindAll(tt.finder)
+			if err != nil {
+				t.Fatalf("FindAll() error: %v", err)
+			}
+			if len(elems) != tt.wantCount {
+				t.Errorf("FindAll() = %d elements, want %d", len(elems), tt.wantCount)
+			}
+			if !reflect.DeepEqual(tt.finder.calls, tt.wantCalls) {
+				t.Errorf("FindAll() calls = %v, want %v", tt.finder.calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestElementTemplates(t *testing.T) {
+	p := Default()
+
+	label := p.Booking.ApplicantLabel
+	if !label.IsTemplate() {
+		t.Fatalf("%s is not a template", label)
+	}
+	got := label.WithText("Ivan Petrov")
+	if got.IsTemplate() || got.Selectors[0].Value != `//label[contains(normalize-space(.), "Ivan Petrov")]` {
+		t.Errorf("WithText() = %+v", got)
+	}
+
+	// With подставляет только id, подстановка текста остается шаблоном
+	if !label.With("Ivan").IsTemplate() {
+		t.Errorf("With() replaced {text} in %s", label)
+	}
+	if got := p.Booking.CalendarNext.With("AppointmentDate1"); got.Page != PageBooking || got.Name != "calendar_next" || got.IsTemplate() {
+		t.Errorf("With() = %+v", got)
+	}
+}
@@ -0,0 +1,185 @@
+package proxycheck
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	cfg "visasolution/internal/config"
+	pkgService "visasolution/pkg/service"
+)
+
+// Status результат проверки прокси
+type Status string
+
+const (
+	StatusOK          Status = "ok"
+	StatusUnreachable Status = "unreachable"
+	StatusAuthFailed  Status = "auth_failed"
+	StatusTLSError    Status = "tls_error"
+)
+
+// Группы прокси из proxies.json
+const (
+	KindRU      = "ru"
+	KindForeign = "foreign"
+)
+
+const proxyAuthRequired = "Proxy Authentication Required"
+
+// Result результат проверки одного прокси
+type Result struct {
+	Kind       string        `json:"kind"`
+	Scheme     string        `json:"scheme"`
+	Host       string        `json:"host"`
+	Port       string        `json:"port"`
+	Status     Status        `json:"status"`
+	StatusCode int           `json:"status_code,omitempty"`
+	Latency    time.Duration `json:"latency_ns"`
+	Error      string        `json:"error,omitempty"`
+}
+
+// Usable возвращает true, если через прокси удалось получить ответ от целевого сайта
+func (r Result) Usable() bool {
+	return r.Status == StatusOK
+}
+
+// Target прокси для проверки
+type Target struct {
+	Kind  string
+	Proxy cfg.Proxy
+}
+
+// Checker проверяет доступность целевого сайта через прокси
+type Checker struct {
+	targetURL string
+	timeout   time.Duration
+}
+
+// NewChecker создает Checker.
+// Параметры:
+// - targetURL адрес, который запрашивается через каждый прокси
+// - timeout ограничение времени на проверку одного прокси
+func NewChecker(targetURL string, timeout time.Duration) *Checker {
+	return &Checker{targetURL: targetURL, timeout: timeout}
+}
+
+// CheckAll параллельно проверяет все прокси. Порядок результатов совпадает с порядком targets
+func (c *Checker) CheckAll(ctx context.Context, targets []Target) []Result {
+	results := make([]Result, len(targets))
+
+	var wg sync.WaitGroup
+	for i, t := range targets {
+		wg.Add(1)
+		go func(i int, t Target) {
+			defer wg.Done()
+			results[i] = c.Check(ctx, t)
+		}(i, t)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// Check проверяет один прокси
+func (c *Checker) Check(ctx context.Context, t Target) Result {
+	res := Result{Kind: t.Kind, Scheme: t.Proxy.Scheme, Host: t.Proxy.Host, Port: t.Proxy.Port}
+
+	transport, err := pkgService.ProxyTransport(t.Proxy.URL())
+	if err != nil {
+		res.Status = StatusUnreachable
+		res.Error = err.Error()
+		return res
+	}
+	client := &http.Client{Transport: transport, Timeout: c.timeout}
+	defer client.CloseIdleConnections()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.targetURL, nil)
+	if err != nil {
+		res.Status = StatusUnreachable
+		res.Error = err.Error()
+		return res
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	res.Latency = time.Since(start)
+	if err != nil {
+		res.Status = classifyError(err)
+		res.Error = err.Error()
+		return res
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	res.StatusCode = resp.StatusCode
+	if resp.StatusCode == http.StatusProxyAuthRequired {
+		res.Status = StatusAuthFailed
+		res.Error = resp.Status
+		return res
+	}
+	res.Status = StatusOK
+
+	return res
+}
+
+// classifyError определяет причину ошибки запроса через прокси.
+// Для https адресов отказ в авторизации приходит ошибкой CONNECT, а не ответом 407
+func classifyError(err error) Status {
+	var (
+		certErr      *tls.CertificateVerificationError
+		headerErr    tls.RecordHeaderError
+		unknownCAErr x509.UnknownAuthorityError
+		hostnameErr  x509.HostnameError
+		invalidErr   x509.CertificateInvalidError
+	)
+	switch {
+	case strings.Contains(err.Error(), proxyAuthRequired):
+		return StatusAuthFailed
+	case errors.As(err, &certErr), errors.As(err, &headerErr), errors.As(err, &unknownCAErr),
+		errors.As(err, &hostnameErr), errors.As(err, &invalidErr):
+		return StatusTLSError
+	}
+
+	return StatusUnreachable
+}
+
+// Targets возвращает все прокси из менеджера: российские и иностранный, если он задан
+func Targets(pm *cfg.ProxiesManager) []Target {
+	proxiesRU := pm.ProxiesRU()
+	targets := make([]Target, 0, len(proxiesRU)+1)
+	for _, p := range proxiesRU {
+		targets = append(targets, Target{Kind: KindRU, Proxy: p})
+	}
+	if !pm.ProxyForeign.IsEmpty() {
+		targets = append(targets, Target{Kind: KindForeign, Proxy: pm.ProxyForeign})
+	}
+
+	return targets
+}
+
+// CheckUsable возвращает ошибку, если не осталось ни одного рабочего российского прокси
+// или не работает иностранный
+func CheckUsable(results []Result) error {
+	usableRU := 0
+	for _, r := range results {
+		if r.Kind == KindForeign && !r.Usable() {
+			return fmt.Errorf("foreign proxy %s is not usable: %s", net.JoinHostPort(r.Host, r.Port), r.Status)
+		}
+		if r.Kind == KindRU && r.Usable() {
+			usableRU++
+		}
+	}
+	if usableRU == 0 {
+		return errors.New("no usable russian proxy")
+	}
+
+	return nil
+}
@@ -0,0 +1,229 @@
+package proxycheck
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	cfg "visasolution/internal/config"
+)
+
+const (
+	testProxyUser     = "user"
+	testProxyPassword = "secret"
+)
+
+// newTestProxy поднимает HTTP прокси с авторизацией по логину и паролю: обычные запросы проксируются,
+// для CONNECT открывается туннель. Если delay больше нуля, прокси отвечает с задержкой
+func newTestProxy(t *testing.T, delay time.Duration) cfg.Proxy {
+	t.Helper()
+
+	wantAuth := "Basic " + base64.StdEncoding.EncodeToString([]byte(testProxyUser+":"+testProxyPassword))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if delay > 0 {
+			select {
+			case <-time.After(delay):
+			case <-r.Context().Done():
+				return
+			}
+		}
+
+		if r.Header.Get("Proxy-Authorization") != wantAuth {
+			w.Header().Set("Proxy-Authenticate", `Basic realm="proxy"`)
+			w.WriteHeader(http.StatusProxyAuthRequired)
+			return
+		}
+
+		if r.Method == http.MethodConnect {
+			tunnel(w, r)
+			return
+		}
+
+		r.RequestURI = ""
+		r.Header.Del("Proxy-Authorization")
+		resp, err := http.DefaultTransport.RoundTrip(r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		defer resp.Body.Close()
+		w.WriteHeader(resp.StatusCode)
+		io.Copy(w, resp.Body)
+	}))
+	t.Cleanup(srv.Close)
+
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg.Proxy{Scheme: cfg.ProxySchemeHTTP, Host: u.Hostname(), Port: u.Port(), Username: testProxyUser, Password: testProxyPassword}
+}
+
+// tunnel соединяет клиента CONNECT запроса с целевым адресом
+func tunnel(w http.ResponseWriter, r *http.Request) {
+	target, err := net.Dial("tcp", r.Host)
+	if err != nil {
+		w.WriteHeader(http.StatusBadGateway)
+		return
+	}
+	defer target.Close()
+
+	client, _, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		return
+	}
+	defer client.Close()
+
+	if _, err := io.WriteString(client, "HTTP/1.1 200 Connection established\r\n\r\n"); err != nil {
+		return
+	}
+
+	done := make(chan struct{}, 2)
+	go func() { io.Copy(target, client); done <- struct{}{} }()
+	go func() { io.Copy(client, target); done <- struct{}{} }()
+	<-done
+}
+
+func TestCheck(t *testing.T) {
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer site.Close()
+	// Сертификат тестового сервера не доверенный, поэтому успешный туннель заканчивается ошибкой TLS
+	tlsSite := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer tlsSite.Close()
+
+	proxy := newTestProxy(t, 0)
+	wrongPassword := proxy
+	wrongPassword.Password = "wrong"
+	slowProxy := newTestProxy(t, time.Second)
+
+	// Адрес, на котором гарантированно никто не слушает
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedAddr := ln.Addr().(*net.TCPAddr)
+	ln.Close()
+	closedProxy := cfg.Proxy{Scheme: cfg.ProxySchemeHTTP, Host: "127.0.0.1", Port: strconv.Itoa(closedAddr.Port)}
+
+	tests := []struct {
+		name           string
+		target         string
+		proxy          cfg.Proxy
+		wantStatus     Status
+		wantStatusCode int
+		wantErr        string
+	}{
+		{
+			name:           "http target",
+			target:         site.URL,
+			proxy:          proxy,
+			wantStatus:     StatusOK,
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "http target, wrong password",
+			target:         site.URL,
+			proxy:          wrongPassword,
+			wantStatus:     StatusAuthFailed,
+			wantStatusCode: http.StatusProxyAuthRequired,
+		},
+		{
+			name:       "https target through CONNECT",
+			target:     tlsSite.URL,
+			proxy:      proxy,
+			wantStatus: StatusTLSError,
+		},
+		{
+			name:       "https target, CONNECT wrong password",
+			target:     tlsSite.URL,
+			proxy:      wrongPassword,
+			wantStatus: StatusAuthFailed,
+			wantErr:    proxyAuthRequired,
+		},
+		{
+			name:       "proxy timeout",
+			target:     site.URL,
+			proxy:      slowProxy,
+			wantStatus: StatusUnreachable,
+			wantErr:    "Client.Timeout exceeded",
+		},
+		{
+			name:       "CONNECT timeout",
+			target:     tlsSite.URL,
+			proxy:      slowProxy,
+			wantStatus: StatusUnreachable,
+		},
+		{
+			name:       "proxy not listening",
+			target:     site.URL,
+			proxy:      closedProxy,
+			wantStatus: StatusUnreachable,
+			wantErr:    "connection refused",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := NewChecker(tt.target, 200*time.Millisecond)
+
+			res := checker.Check(context.Background(), Target{Kind: KindRU, Proxy: tt.proxy})
+			if res.Status != tt.wantStatus {
+				t.Errorf("Status = %s, want %s (error: %s)", res.Status, tt.wantStatus, res.Error)
+			}
+			if res.StatusCode != tt.wantStatusCode {
+				t.Errorf("StatusCode = %d, want %d", res.StatusCode, tt.wantStatusCode)
+			}
+			if !strings.Contains(res.Error, tt.wantErr) {
+				t.Errorf("Error = %q, want it to contain %q", res.Error, tt.wantErr)
+			}
+			if strings.Contains(res.Error, testProxyPassword) {
+				t.Errorf("Error %q contains the proxy password", res.Error)
+			}
+			if res.Host != tt.proxy.Host || res.Port != tt.proxy.Port || res.Kind != KindRU {
+				t.Errorf("unexpected result: %+v", res)
+			}
+		})
+	}
+}
+
+func TestCheckUsable(t *testing.T) {
+	tests := []struct {
+		name    string
+		results []Result
+		wantErr bool
+	}{
+		{
+			name:    "usable russian and foreign proxies",
+			results: []Result{{Kind: KindRU, Status: StatusUnreachable}, {Kind: KindRU, Status: StatusOK}, {Kind: KindForeign, Status: StatusOK}},
+		},
+		{
+			name:    "no usable russian proxy",
+			results: []Result{{Kind: KindRU, Status: StatusAuthFailed}, {Kind: KindForeign, Status: StatusOK}},
+			wantErr: true,
+		},
+		{
+			name:    "foreign proxy not usable",
+			results: []Result{{Kind: KindRU, Status: StatusOK}, {Kind: KindForeign, Status: StatusTLSError}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := CheckUsable(tt.results); (err != nil) != tt.wantErr {
+				t.Errorf("CheckUsable() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
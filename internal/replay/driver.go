@@ -0,0 +1,486 @@
+package replay
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/tebeka/selenium"
+	"golang.org/x/net/html"
+	"image"
+	"image/png"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"visasolution/internal/pages"
+)
+
+// booleanAttributes атрибуты, для которых Selenium возвращает "true", если атрибут есть у элемента
+var booleanAttributes = map[string]bool{
+	"required": true,
+	"checked":  true,
+	"selected": true,
+	"disabled": true,
+	"hidden":   true,
+	"readonly": true,
+	"multiple": true,
+}
+
+// defaultCSSSize размер элемента, если он не задан в атрибуте style
+const defaultCSSSize = "100px"
+
+// Driver заглушка Selenium WebDriver, воспроизводящая запись с Server.
+//
+// Переходы, клики и переключения фреймов запрашивают у сервера следующий шаг записи и заменяют HTML документ,
+// адрес, куки и alert состоянием из шага. Поиск элементов выполняется по HTML документу теми же селекторами,
+// что и в Selenium. JavaScript не выполняется: изменения страницы, которые делают скрипты, берутся из записи.
+// Методы, которые бот не использует, вызывают панику
+type Driver struct {
+	selenium.WebDriver
+
+	serverURL string
+	client    *http.Client
+
+	url string
+	// doc текущий документ: страница или документ iframe'а
+	doc *html.Node
+	// top документ страницы, пока драйвер переключен на iframe
+	top     *html.Node
+	inFrame bool
+
+	cookies    []selenium.Cookie
+	alert      string
+	captcha    []byte
+	screenshot []byte
+}
+
+// NewDriver создает заглушку веб-драйвера для сервера воспроизведения по адресу serverURL
+func NewDriver(serverURL string) *Driver {
+	return &Driver{
+		serverURL: strings.TrimSuffix(serverURL, "/"),
+		client:    &http.Client{Timeout: 10 * time.Second},
+		doc:       &html.Node{Type: html.DocumentNode},
+	}
+}
+
+// next запрашивает у сервера следующий шаг записи и применяет его
+func (d *Driver) next(trigger string) error {
+	resp, err := d.client.Get(d.serverURL + nextPath + "?trigger=" + url.QueryEscape(trigger))
+	if err != nil {
+		return fmt.Errorf("replay request error: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("replay %s error: %s", trigger, strings.TrimSpace(string(msg)))
+	}
+
+	var step StepResponse
+	if err := json.NewDecoder(resp.Body).Decode(&step); err != nil {
+		return fmt.Errorf("replay step decode error: %w", err)
+	}
+
+	return d.apply(step)
+}
+
+// apply заменяет состояние драйвера состоянием шага. Пустые поля шага означают, что состояние не изменилось
+func (d *Driver) apply(step StepResponse) error {
+	if step.HTML != "" {
+		doc, err := html.Parse(strings.NewReader(step.HTML))
+		if err != nil {
+			return fmt.Errorf("replay step %d: parse html error: %w", step.Index, err)
+		}
+		d.doc = doc
+	}
+	if step.URL != "" {
+		d.url = step.URL
+	}
+	if step.Cookies != nil {
+		d.cookies = step.Cookies
+	}
+	if step.Captcha != nil {
+		d.captcha = step.Captcha
+	}
+	if step.Screenshot != nil {
+		d.screenshot = step.Screenshot
+	}
+	d.alert = step.Alert
+
+	return nil
+}
+
+func (d *Driver) Get(string) error {
+	d.inFrame = false
+	return d.next(TriggerNavigate)
+}
+
+func (d *Driver) Refresh() error {
+	d.inFrame = false
+	return d.next(TriggerNavigate)
+}
+
+func (d *Driver) CurrentURL() (string, error) {
+	return d.serverURL + d.url, nil
+}
+
+func (d *Driver) Title() (string, error) {
+	doc := d.doc
+	if d.inFrame {
+		doc = d.top
+	}
+
+	nodes, err := pages.QueryAll(doc, pages.Selector{By: selenium.ByTagName, Value: "title"})
+	if err != nil || len(nodes) == 0 {
+		return "", err
+	}
+	return textContent(nodes[0]), nil
+}
+
+func (d *Driver) PageSource() (string, error) {
+	var buf bytes.Buffer
+	if err := html.Render(&buf, d.doc); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func (d *Driver) FindElement(by, value string) (selenium.WebElement, error) {
+	return findElement(d, d.doc, by, value)
+}
+
+func (d *Driver) FindElements(by, value string) ([]selenium.WebElement, error) {
+	return findElements(d, d.doc, by, value)
+}
+
+// SwitchFrame переключается на iframe (элемент) или обратно на страницу (nil)
+func (d *Driver) SwitchFrame(frame interface{}) error {
+	switch frame.(type) {
+	case nil:
+		if d.inFrame {
+			d.doc = d.top
+			d.inFrame = false
+		}
+	case selenium.WebElement:
+		if !d.inFrame {
+			d.top = d.doc
+			d.inFrame = true
+		}
+	default:
+		return fmt.Errorf("invalid type %T", frame)
+	}
+
+	return d.next(TriggerFrame)
+}
+
+func (d *Driver) GetCookies() ([]selenium.Cookie, error) {
+	return append([]selenium.Cookie(nil), d.cookies...), nil
+}
+
+func (d *Driver) GetCookie(name string) (selenium.Cookie, error) {
+	for _, c := range d.cookies {
+		if c.Name == name {
+			return c, nil
+		}
+	}
+	return selenium.Cookie{}, &selenium.Error{Err: "no such cookie", Message: fmt.Sprintf("cookie '%s' not found", name)}
+}
+
+func (d *Driver) AddCookie(cookie *selenium.Cookie) error {
+	d.DeleteCookie(cookie.Name)
+	d.cookies = append(d.cookies, *cookie)
+	return nil
+}
+
+func (d *Driver) DeleteCookie(name string) error {
+	cookies := d.cookies[:0]
+	for _, c := range d.cookies {
+		if c.Name != name {
+			cookies = append(cookies, c)
+		}
+	}
+	d.cookies = cookies
+	return nil
+}
+
+func (d *Driver) DeleteAllCookies() error {
+	d.cookies = nil
+	return nil
+}
+
+func (d *Driver) AlertText() (string, error) {
+	if d.alert == "" {
+		return "", &selenium.Error{Err: "no such alert", Message: "no such alert"}
+	}
+	return d.alert, nil
+}
+
+func (d *Driver) AcceptAlert() error {
+	if _, err := d.AlertText(); err != nil {
+		return err
+	}
+	d.alert = ""
+	return nil
+}
+
+func (d *Driver) DismissAlert() error {
+	return d.AcceptAlert()
+}
+
+// Screenshot возвращает последний записанный скриншот страницы или пустое изображение
+func (d *Driver) Screenshot() ([]byte, error) {
+	if d.screenshot != nil {
+		return d.screenshot, nil
+	}
+	return placeholderPNG()
+}
+
+// ExecuteScript не выполняет скрипт
+func (d *Driver) ExecuteScript(string, []interface{}) (interface{}, error) {
+	return nil, nil
+}
+
+func (d *Driver) MaximizeWindow(string) error {
+	return nil
+}
+
+func (d *Driver) Quit() error {
+	return nil
+}
+
+// Element элемент HTML документа заглушки веб-драйвера
+type Element struct {
+	d    *Driver
+	node *html.Node
+}
+
+// Click запрашивает у сервера следующий шаг записи. Чекбоксы и радиокнопки отмечаются и в текущем документе
+func (e *Element) Click() error {
+	switch attr(e.node, "type") {
+	case "checkbox":
+		if hasAttr(e.node, "checked") {
+			removeAttr(e.node, "checked")
+		} else {
+			setAttr(e.node, "checked", "")
+		}
+	case "radio":
+		setAttr(e.node, "checked", "")
+	}
+
+	return e.d.next(TriggerClick)
+}
+
+// SendKeys дописывает текст в атрибут value
+func (e *Element) SendKeys(keys string) error {
+	setAttr(e.node, "value", attr(e.node, "value")+keys)
+	return nil
+}
+
+func (e *Element) Submit() error {
+	return e.d.next(TriggerClick)
+}
+
+func (e *Element) Clear() error {
+	setAttr(e.node, "value", "")
+	return nil
+}
+
+func (e *Element) MoveTo(int, int) error {
+	return nil
+}
+
+func (e *Element) FindElement(by, value string) (selenium.WebElement, error) {
+	return findElement(e.d, e.node, by, value)
+}
+
+func (e *Element) FindElements(by, value string) ([]selenium.WebElement, error) {
+	return findElements(e.d, e.node, by, value)
+}
+
+func (e *Element) TagName() (string, error) {
+	return e.node.Data, nil
+}
+
+// Text возвращает текст элемента без учета видимости: выпадающие списки в записи могут быть скрыты
+func (e *Element) Text() (string, error) {
+	return strings.Join(strings.Fields(textContent(e.node)), " "), nil
+}
+
+func (e *Element) IsSelected() (bool, error) {
+	return hasAttr(e.node, "checked") || hasAttr(e.node, "selected"), nil
+}
+
+func (e *Element) IsEnabled() (bool, error) {
+	return !hasAttr(e.node, "disabled"), nil
+}
+
+// IsDisplayed проверяет, что ни элемент, ни его родители не скрыты: style "display: none", атрибут hidden,
+// классы d-none и скрытое модальное окно bootstrap (класс modal без show)
+func (e *Element) IsDisplayed() (bool, error) {
+	for n := e.node; n != nil && n.Type == html.ElementNode; n = n.Parent {
+		if isHidden(n) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (e *Element) GetAttribute(name string) (string, error) {
+	if booleanAttributes[name] {
+		if hasAttr(e.node, name) {
+			return "true", nil
+		}
+		return "", nil
+	}
+	return attr(e.node, name), nil
+}
+
+func (e *Element) Location() (*selenium.Point, error) {
+	return &selenium.Point{}, nil
+}
+
+func (e *Element) LocationInView() (*selenium.Point, error) {
+	return &selenium.Point{}, nil
+}
+
+func (e *Element) Size() (*selenium.Size, error) {
+	return &selenium.Size{Width: 100, Height: 100}, nil
+}
+
+// CSSProperty возвращает значение свойства из атрибута style, для размеров по умолчанию - defaultCSSSize
+func (e *Element) CSSProperty(name string) (string, error) {
+	if value, ok := styleProperty(e.node, name); ok {
+		return value, nil
+	}
+	if name == "width" || name == "height" {
+		return defaultCSSSize, nil
+	}
+	return "", nil
+}
+
+// Screenshot возвращает последнее записанное изображение капчи или пустое изображение
+func (e *Element) Screenshot(bool) ([]byte, error) {
+	if e.d.captcha != nil {
+		return e.d.captcha, nil
+	}
+	return placeholderPNG()
+}
+
+func findElement(d *Driver, root *html.Node, by, value string) (selenium.WebElement, error) {
+	elems, err := findElements(d, root, by, value)
+	if err != nil {
+		return nil, err
+	}
+	if len(elems) == 0 {
+		return nil, &selenium.Error{Err: "no such element", Message: fmt.Sprintf("element not found: %s=%s", by, value)}
+	}
+	return elems[0], nil
+}
+
+func findElements(d *Driver, root *html.Node, by, value string) ([]selenium.WebElement, error) {
+	nodes, err := pages.QueryAll(root, pages.Selector{By: by, Value: value})
+	if err != nil {
+		return nil, &selenium.Error{Err: "invalid selector", Message: err.Error()}
+	}
+
+	elems := make([]selenium.WebElement, 0, len(nodes))
+	for _, n := range nodes {
+		if n.Type != html.ElementNode {
+			continue
+		}
+		elems = append(elems, &Element{d: d, node: n})
+	}
+	return elems, nil
+}
+
+func isHidden(n *html.Node) bool {
+	if hasAttr(n, "hidden") || (n.Data == "input" && attr(n, "type") == "hidden") {
+		return true
+	}
+	if display, ok := styleProperty(n, "display"); ok && display == "none" {
+		return true
+	}
+
+	classes := strings.Fields(attr(n, "class"))
+	return containsClass(classes, "d-none") || (containsClass(classes, "modal") && !containsClass(classes, "show"))
+}
+
+func containsClass(classes []string, class string) bool {
+	for _, c := range classes {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+// styleProperty возвращает значение свойства из атрибута style элемента
+func styleProperty(n *html.Node, name string) (string, bool) {
+	for _, decl := range strings.Split(attr(n, "style"), ";") {
+		key, value, ok := strings.Cut(decl, ":")
+		if ok && strings.TrimSpace(key) == name {
+			return strings.TrimSpace(value), true
+		}
+	}
+	return "", false
+}
+
+func textContent(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+
+	var sb strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		sb.WriteString(textContent(c))
+	}
+	return sb.String()
+}
+
+func attr(n *html.Node, name string) string {
+	for _, a := range n.Attr {
+		if a.Key == name {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func hasAttr(n *html.Node, name string) bool {
+	for _, a := range n.Attr {
+		if a.Key == name {
+			return true
+		}
+	}
+	return false
+}
+
+func setAttr(n *html.Node, name, value string) {
+	for i, a := range n.Attr {
+		if a.Key == name {
+			n.Attr[i].Val = value
+			return
+		}
+	}
+	n.Attr = append(n.Attr, html.Attribute{Key: name, Val: value})
+}
+
+func removeAttr(n *html.Node, name string) {
+	attrs := n.Attr[:0]
+	for _, a := range n.Attr {
+		if a.Key != name {
+			attrs = append(attrs, a)
+		}
+	}
+	n.Attr = attrs
+}
+
+// placeholderPNG пустое изображение 1x1 вместо скриншота, которого нет в записи
+func placeholderPNG() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 1, 1))); err != nil {
+		return nil, fmt.Errorf("placeholder screenshot error: %w", err)
+	}
+	return buf.Bytes(), nil
+}
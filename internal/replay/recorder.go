@@ -0,0 +1,244 @@
+package replay
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/tebeka/selenium"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Recorder записывает состояние страниц (HTML, куки, alert, скриншоты) после каждого действия веб-драйвера.
+// Записи сохраняются в папку <root>/<name>/<время начала> и воспроизводятся через Server и Driver.
+// Записи содержат куки авторизации, их нельзя публиковать без очистки
+type Recorder struct {
+	root string
+
+	mu sync.Mutex
+	// dir папка текущей записи, пустая строка - запись не ведется
+	dir   string
+	steps []Step
+	// lastPage содержимое последней записанной страницы, чтобы не дублировать неизменившиеся страницы
+	lastPage string
+}
+
+// NewRecorder создает Recorder, сохраняющий записи в папку root
+func NewRecorder(root string) *Recorder {
+	return &Recorder{root: root}
+}
+
+// Start начинает новую запись с именем name (например, имя профиля)
+func (r *Recorder) Start(name string) error {
+	dir := filepath.Join(r.root, name, time.Now().Format("20060102-150405"))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create recording folder: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.dir = dir
+	r.steps = nil
+	r.lastPage = ""
+
+	log.Println("Recording started:", dir)
+
+	return nil
+}
+
+// Stop завершает текущую запись и возвращает ее папку
+func (r *Recorder) Stop() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	dir := r.dir
+	if dir != "" {
+		log.Printf("Recording stopped: %s, steps: %d\n", dir, len(r.steps))
+	}
+	r.dir = ""
+
+	return dir
+}
+
+// Wrap возвращает веб-драйвер, записывающий шаги в текущую запись
+func (r *Recorder) Wrap(wd selenium.WebDriver) selenium.WebDriver {
+	return &recordingDriver{WebDriver: wd, r: r}
+}
+
+// record записывает состояние страницы после действия trigger. Ошибки записи не влияют на работу драйвера
+func (r *Recorder) record(wd selenium.WebDriver, trigger string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.dir == "" {
+		return
+	}
+
+	step := Step{Trigger: trigger}
+	if current, err := wd.CurrentURL(); err == nil {
+		step.URL = urlPath(current)
+	}
+	if cookies, err := wd.GetCookies(); err == nil {
+		step.Cookies = cookies
+	}
+
+	// Пока открыт alert, исходный код страницы недоступен
+	if alert, err := wd.AlertText(); err == nil {
+		step.Alert = alert
+	} else if source, err := wd.PageSource(); err == nil && source != r.lastPage {
+		step.Page = r.writeFile(fmt.Sprintf("step%03d.html", len(r.steps)+1), []byte(source))
+		r.lastPage = source
+	}
+
+	r.steps = append(r.steps, step)
+	r.writeManifest()
+}
+
+// attach прикрепляет скриншот к последнему шагу. captcha - скриншот iframe'а капчи
+func (r *Recorder) attach(data []byte, captcha bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.dir == "" || len(r.steps) == 0 {
+		return
+	}
+
+	step := &r.steps[len(r.steps)-1]
+	n := len(r.steps)
+	if captcha {
+		step.Captcha = r.writeFile(fmt.Sprintf("step%03d_captcha.png", n), data)
+	} else {
+		step.Screenshot = r.writeFile(fmt.Sprintf("step%03d_screenshot.png", n), data)
+	}
+	r.writeManifest()
+}
+
+// writeFile сохраняет файл в папку записи и возвращает его имя, при ошибке - пустую строку
+func (r *Recorder) writeFile(name string, data []byte) string {
+	if err := os.WriteFile(filepath.Join(r.dir, name), data, 0o600); err != nil {
+		log.Println("Recording write error:", err)
+		return ""
+	}
+	return name
+}
+
+// writeManifest перезаписывает manifest.json после каждого шага, чтобы запись не терялась при падении Run
+func (r *Recorder) writeManifest() {
+	data, err := json.MarshalIndent(Manifest{Steps: r.steps}, "", "  ")
+	if err != nil {
+		log.Println("Recording manifest error:", err)
+		return
+	}
+	r.writeFile(manifestFilename, data)
+}
+
+// urlPath возвращает путь и параметры адреса без схемы и хоста
+func urlPath(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	return u.RequestURI()
+}
+
+// recordingDriver веб-драйвер, записывающий шаги после переходов, кликов и переключений фреймов
+type recordingDriver struct {
+	selenium.WebDriver
+	r *Recorder
+}
+
+func (d *recordingDriver) Get(url string) error {
+	err := d.WebDriver.Get(url)
+	d.r.record(d.WebDriver, TriggerNavigate)
+	return err
+}
+
+func (d *recordingDriver) Refresh() error {
+	err := d.WebDriver.Refresh()
+	d.r.record(d.WebDriver, TriggerNavigate)
+	return err
+}
+
+func (d *recordingDriver) SwitchFrame(frame interface{}) error {
+	err := d.WebDriver.SwitchFrame(frame)
+	if err == nil {
+		d.r.record(d.WebDriver, TriggerFrame)
+	}
+	return err
+}
+
+func (d *recordingDriver) FindElement(by, value string) (selenium.WebElement, error) {
+	elem, err := d.WebDriver.FindElement(by, value)
+	if err != nil {
+		return nil, err
+	}
+	return &recordingElement{WebElement: elem, wd: d.WebDriver, r: d.r}, nil
+}
+
+func (d *recordingDriver) FindElements(by, value string) ([]selenium.WebElement, error) {
+	elems, err := d.WebDriver.FindElements(by, value)
+	return wrapElements(elems, d.WebDriver, d.r), err
+}
+
+func (d *recordingDriver) Screenshot() ([]byte, error) {
+	data, err := d.WebDriver.Screenshot()
+	if err == nil {
+		d.r.attach(data, false)
+	}
+	return data, err
+}
+
+// recordingElement элемент страницы, записывающий шаг после клика
+type recordingElement struct {
+	selenium.WebElement
+	wd selenium.WebDriver
+	r  *Recorder
+}
+
+func (e *recordingElement) Click() error {
+	err := e.WebElement.Click()
+	if err == nil {
+		e.r.record(e.wd, TriggerClick)
+	}
+	return err
+}
+
+func (e *recordingElement) FindElement(by, value string) (selenium.WebElement, error) {
+	elem, err := e.WebElement.FindElement(by, value)
+	if err != nil {
+		return nil, err
+	}
+	return &recordingElement{WebElement: elem, wd: e.wd, r: e.r}, nil
+}
+
+func (e *recordingElement) FindElements(by, value string) ([]selenium.WebElement, error) {
+	elems, err := e.WebElement.FindElements(by, value)
+	return wrapElements(elems, e.wd, e.r), err
+}
+
+// Screenshot снимок элемента. Снимки элементов делаются только для iframe'а капчи
+func (e *recordingElement) Screenshot(scroll bool) ([]byte, error) {
+	data, err := e.WebElement.Screenshot(scroll)
+	if err == nil {
+		e.r.attach(data, true)
+	}
+	return data, err
+}
+
+// MarshalJSON передает в веб-драйвер исходный элемент, когда элемент является аргументом команды
+// (SwitchFrame, ExecuteScript)
+func (e *recordingElement) MarshalJSON() ([]byte, error) {
+	return json.Marshal(e.WebElement)
+}
+
+func wrapElements(elems []selenium.WebElement, wd selenium.WebDriver, r *Recorder) []selenium.WebElement {
+	wrapped := make([]selenium.WebElement, 0, len(elems))
+	for _, elem := range elems {
+		wrapped = append(wrapped, &recordingElement{WebElement: elem, wd: wd, r: r})
+	}
+	return wrapped
+}
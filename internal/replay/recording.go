@@ -0,0 +1,92 @@
+package replay
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/tebeka/selenium"
+	"os"
+	"path/filepath"
+)
+
+// manifestFilename файл с описанием шагов записи
+const manifestFilename = "manifest.json"
+
+// Действия веб-драйвера, после которых записывается шаг
+const (
+	// TriggerNavigate переход по адресу или обновление страницы
+	TriggerNavigate = "navigate"
+	// TriggerClick клик по элементу
+	TriggerClick = "click"
+	// TriggerFrame переключение на iframe
+	TriggerFrame = "frame"
+)
+
+// Step состояние страницы после действия веб-драйвера
+type Step struct {
+	Trigger string `json:"trigger"`
+	// URL путь и параметры адреса страницы без схемы и хоста, например "/Global/bls/VisaTypeVerification"
+	URL string `json:"url"`
+	// Page файл с HTML страницы (или документа iframe). Пустая строка - страница не изменилась
+	Page string `json:"page,omitempty"`
+	// Cookies куки после действия. Пустой список - куки не изменились
+	Cookies []selenium.Cookie `json:"cookies,omitempty"`
+	// Alert текст открытого alert'а, пустая строка - alert'а нет
+	Alert string `json:"alert,omitempty"`
+	// Captcha файл со скриншотом капчи (скриншот iframe'а), снятым на этом шаге
+	Captcha string `json:"captcha,omitempty"`
+	// Screenshot файл со скриншотом страницы, снятым на этом шаге
+	Screenshot string `json:"screenshot,omitempty"`
+}
+
+// Manifest описание записи: шаги в порядке выполнения
+type Manifest struct {
+	Steps []Step `json:"steps"`
+}
+
+// Recording загруженная запись: шаги и содержимое файлов
+type Recording struct {
+	Dir   string
+	Steps []Step
+	// files содержимое файлов страниц и скриншотов по имени
+	files map[string][]byte
+}
+
+// LoadRecording загружает запись из папки dir с файлом manifest.json
+func LoadRecording(dir string) (*Recording, error) {
+	data, err := os.ReadFile(filepath.Join(dir, manifestFilename))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read recording manifest: %w", err)
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse recording manifest: %w", err)
+	}
+
+	rec := &Recording{Dir: dir, Steps: manifest.Steps, files: make(map[string][]byte)}
+	for i, step := range manifest.Steps {
+		switch step.Trigger {
+		case TriggerNavigate, TriggerClick, TriggerFrame:
+		default:
+			return nil, fmt.Errorf("step %d: unknown trigger '%s'", i+1, step.Trigger)
+		}
+
+		for _, name := range []string{step.Page, step.Captcha, step.Screenshot} {
+			if name == "" || rec.files[name] != nil {
+				continue
+			}
+			content, err := os.ReadFile(filepath.Join(dir, name))
+			if err != nil {
+				return nil, fmt.Errorf("step %d: %w", i+1, err)
+			}
+			rec.files[name] = content
+		}
+	}
+
+	return rec, nil
+}
+
+// File возвращает содержимое файла записи, nil для пустого имени
+func (r *Recording) File(name string) []byte {
+	return r.files[name]
+}
@@ -0,0 +1,200 @@
+package replay
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/tebeka/selenium"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// Служебные адреса сервера воспроизведения
+const (
+	// nextPath выдает следующий шаг записи для Driver. Параметр trigger - действие драйвера
+	nextPath = "/__replay/next"
+	// resetPath возвращает воспроизведение к первому шагу
+	resetPath = "/__replay/reset"
+	// filesPath отдает файлы записи (скриншоты, капчу) по имени
+	filesPath = "/__replay/files/"
+)
+
+// StepResponse шаг записи с содержимым файлов, который сервер отдает Driver'у
+type StepResponse struct {
+	Index   int               `json:"index"`
+	Trigger string            `json:"trigger"`
+	URL     string            `json:"url"`
+	HTML    string            `json:"html,omitempty"`
+	Cookies []selenium.Cookie `json:"cookies,omitempty"`
+	Alert   string            `json:"alert,omitempty"`
+	// Captcha и Screenshot PNG изображения, в JSON кодируются в base64
+	Captcha    []byte `json:"captcha,omitempty"`
+	Screenshot []byte `json:"screenshot,omitempty"`
+}
+
+// Server фейковый сервер сайта BLS, воспроизводящий запись шаг за шагом.
+//
+// Driver запрашивает шаги по порядку через /__replay/next: если действие драйвера не совпадает с действием
+// в записи, сервер отвечает 409, если шаги закончились - 410.
+// Обычный браузер получает страницы по пути адреса: сервер отдает первый шаг с таким путем, начиная с текущего,
+// и переходит к следующему, поэтому повторные запросы одного адреса возвращают следующие состояния страницы
+type Server struct {
+	rec *Recording
+
+	mu sync.Mutex
+	// cursor индекс следующего шага
+	cursor int
+}
+
+// NewServer создает сервер воспроизведения записи
+func NewServer(rec *Recording) *Server {
+	return &Server{rec: rec}
+}
+
+// Remaining возвращает количество не воспроизведенных шагов
+func (s *Server) Remaining() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.rec.Steps) - s.cursor
+}
+
+// Reset возвращает воспроизведение к первому шагу
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cursor = 0
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == nextPath:
+		s.handleNext(w, r)
+	case r.URL.Path == resetPath && r.Method == http.MethodPost:
+		s.Reset()
+		w.WriteHeader(http.StatusNoContent)
+	case strings.HasPrefix(r.URL.Path, filesPath):
+		s.handleFile(w, r)
+	default:
+		s.handlePage(w, r)
+	}
+}
+
+// handleNext отдает следующий шаг, если его действие совпадает с действием драйвера
+func (s *Server) handleNext(w http.ResponseWriter, r *http.Request) {
+	trigger := r.URL.Query().Get("trigger")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cursor >= len(s.rec.Steps) {
+		http.Error(w, fmt.Sprintf("recording exhausted: all %d steps replayed, got '%s'", len(s.rec.Steps), trigger), http.StatusGone)
+		return
+	}
+
+	step := s.rec.Steps[s.cursor]
+	if step.Trigger != trigger {
+		http.Error(w, fmt.Sprintf("step %d: expected '%s', got '%s'", s.cursor+1, step.Trigger, trigger), http.StatusConflict)
+		return
+	}
+	s.cursor++
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.response(s.cursor, step))
+}
+
+// handlePage отдает браузеру страницу шага, путь которого совпадает с путем запроса.
+// Формы (POST) переводят воспроизведение к следующему шагу
+func (s *Server) handlePage(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	index := -1
+	if r.Method == http.MethodPost {
+		if s.cursor < len(s.rec.Steps) {
+			index = s.cursor
+		}
+	} else {
+		index = s.findPage(r.URL.Path)
+	}
+	if index < 0 {
+		http.NotFound(w, r)
+		return
+	}
+	if index >= s.cursor {
+		s.cursor = index + 1
+	}
+
+	step := s.rec.Steps[index]
+	for _, c := range step.Cookies {
+		http.SetCookie(w, &http.Cookie{Name: c.Name, Value: c.Value, Path: "/"})
+	}
+
+	// После POST браузер переходит на адрес шага, чтобы адрес страницы совпадал с записью
+	if r.Method == http.MethodPost && urlPathOnly(step.URL) != r.URL.Path {
+		http.Redirect(w, r, step.URL, http.StatusSeeOther)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(s.rec.File(s.pageOf(index)))
+}
+
+// findPage возвращает индекс первого шага с HTML страницей по пути path, начиная с текущего,
+// иначе последнего такого шага до текущего. -1, если шага нет
+func (s *Server) findPage(path string) int {
+	last := -1
+	for i, step := range s.rec.Steps {
+		if step.Trigger == TriggerFrame || urlPathOnly(step.URL) != path || s.pageOf(i) == "" {
+			continue
+		}
+		if i >= s.cursor {
+			return i
+		}
+		last = i
+	}
+	return last
+}
+
+// pageOf возвращает файл страницы шага. Если страница не менялась, берется страница предыдущего шага
+func (s *Server) pageOf(index int) string {
+	for i := index; i >= 0; i-- {
+		if page := s.rec.Steps[i].Page; page != "" {
+			return page
+		}
+	}
+	return ""
+}
+
+func (s *Server) handleFile(w http.ResponseWriter, r *http.Request) {
+	data := s.rec.File(strings.TrimPrefix(r.URL.Path, filesPath))
+	if data == nil {
+		http.NotFound(w, r)
+		return
+	}
+	w.Write(data)
+}
+
+func (s *Server) response(index int, step Step) StepResponse {
+	return StepResponse{
+		Index:      index,
+		Trigger:    step.Trigger,
+		URL:        step.URL,
+		HTML:       string(s.rec.File(step.Page)),
+		Cookies:    step.Cookies,
+		Alert:      step.Alert,
+		Captcha:    s.rec.File(step.Captcha),
+		Screenshot: s.rec.File(step.Screenshot),
+	}
+}
+
+// urlPathOnly возвращает путь адреса без параметров
+func urlPathOnly(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	return u.Path
+}
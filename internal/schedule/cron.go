@@ -0,0 +1,133 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSearchLimit максимальный период поиска следующего срабатывания cron выражения
+const cronSearchLimit = 366 * 24 * time.Hour
+
+// cronExpr cron выражение из 5 полей: минута, час, день месяца, месяц, день недели (0 - воскресенье)
+type cronExpr struct {
+	spec   string
+	minute []bool
+	hour   []bool
+	dom    []bool
+	month  []bool
+	dow    []bool
+	// anyDom, anyDow true, если поле начинается с "*" (например, "*" или "*/2")
+	anyDom bool
+	anyDow bool
+}
+
+// cronFieldBounds допустимые значения полей cron выражения
+var cronFieldBounds = [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 6}}
+
+// parseCron разбирает cron выражение. Поддерживаются "*", списки через запятую, диапазоны "a-b" и шаг "/n"
+func parseCron(spec string) (*cronExpr, error) {
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression '%s' must have 5 fields", spec)
+	}
+
+	var sets [5][]bool
+	for i, field := range fields {
+		set, err := parseCronField(field, cronFieldBounds[i][0], cronFieldBounds[i][1])
+		if err != nil {
+			return nil, fmt.Errorf("cron expression '%s': %w", spec, err)
+		}
+		sets[i] = set
+	}
+
+	return &cronExpr{
+		spec:   spec,
+		minute: sets[0],
+		hour:   sets[1],
+		dom:    sets[2],
+		month:  sets[3],
+		dow:    sets[4],
+		anyDom: strings.HasPrefix(fields[2], "*"),
+		anyDow: strings.HasPrefix(fields[4], "*"),
+	}, nil
+}
+
+func parseCronField(field string, min, max int) ([]bool, error) {
+	set := make([]bool, max+1)
+
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepPart)
+			if err != nil || step <= 0 {
+				return nil, fmt.Errorf("invalid step in '%s'", part)
+			}
+		}
+
+		from, to := min, max
+		if rangePart != "*" {
+			fromPart, toPart, isRange := strings.Cut(rangePart, "-")
+			var err error
+			from, err = strconv.Atoi(fromPart)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value in '%s'", part)
+			}
+			to = from
+			if isRange {
+				to, err = strconv.Atoi(toPart)
+				if err != nil {
+					return nil, fmt.Errorf("invalid range in '%s'", part)
+				}
+			} else if hasStep {
+				to = max
+			}
+		}
+		if from < min || to > max || from > to {
+			return nil, fmt.Errorf("'%s' is out of range %d-%d", part, min, max)
+		}
+
+		for v := from; v <= to; v += step {
+			set[v] = true
+		}
+	}
+
+	return set, nil
+}
+
+// match проверяет, срабатывает ли выражение в минуту t.
+// Если оба поля, день месяца и день недели, ограничены (не начинаются с "*"), достаточно совпадения одного из них,
+// иначе должны совпасть оба, как в cron
+func (c *cronExpr) match(t time.Time) bool {
+	if !c.minute[t.Minute()] || !c.hour[t.Hour()] || !c.month[int(t.Month())] {
+		return false
+	}
+
+	domMatch, dowMatch := c.dom[t.Day()], c.dow[int(t.Weekday())]
+	if c.anyDom || c.anyDow {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+// next возвращает ближайшее срабатывание строго после from. Нулевое время, если срабатываний нет в течение года
+func (c *cronExpr) next(from time.Time) time.Time {
+	t := from.Truncate(time.Minute).Add(time.Minute)
+	limit := from.Add(cronSearchLimit)
+
+	for t.Before(limit) {
+		if !c.month[int(t.Month())] {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if c.match(t) {
+			return t
+		}
+		t = t.Add(time.Minute)
+	}
+
+	return time.Time{}
+}
@@ -0,0 +1,205 @@
+package schedule
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+const (
+	cronPrefix      = "cron "
+	ruleSeparator   = ";"
+	timeOfDayLayout = "15:04"
+)
+
+// window временное окно внутри суток со своим интервалом запуска
+type window struct {
+	// start, end минуты от начала суток. Если end <= start, окно переходит через полночь
+	start, end int
+	interval   time.Duration
+}
+
+// Schedule расписание основного цикла: временные окна со своими интервалами и cron выражения.
+// Внутри окна используется его интервал, даже если он длиннее интервала по умолчанию (например, редкие проверки ночью).
+// Вне окон используется интервал по умолчанию
+type Schedule struct {
+	windows  []window
+	crons    []*cronExpr
+	interval time.Duration
+	loc      *time.Location
+	jitter   time.Duration
+}
+
+// New создает расписание.
+// Параметры:
+// - spec правила через ";": окно "HH:MM-HH:MM/интервал" (например, "08:55-09:30/2m")
+// или cron выражение "cron */5 9 * * 1-5". Пустая строка - только интервал по умолчанию
+// - interval интервал вне окон
+// - loc часовой пояс, в котором заданы окна и cron выражения
+// - jitter максимальная случайная задержка, добавляемая к каждому запуску
+func New(spec string, interval time.Duration, loc *time.Location, jitter time.Duration) (*Schedule, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("interval must be positive")
+	}
+	if jitter < 0 {
+		return nil, fmt.Errorf("jitter must not be negative")
+	}
+
+	s := &Schedule{interval: interval, loc: loc, jitter: jitter}
+
+	for _, rule := range strings.Split(spec, ruleSeparator) {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+
+		if strings.HasPrefix(rule, cronPrefix) {
+			c, err := parseCron(strings.TrimPrefix(rule, cronPrefix))
+			if err != nil {
+				return nil, err
+			}
+			s.crons = append(s.crons, c)
+			continue
+		}
+
+		w, err := parseWindow(rule)
+		if err != nil {
+			return nil, err
+		}
+		s.windows = append(s.windows, w)
+	}
+
+	return s, nil
+}
+
+// parseWindow разбирает окно вида "08:55-09:30/2m"
+func parseWindow(rule string) (window, error) {
+	bounds, intervalPart, ok := strings.Cut(rule, "/")
+	if !ok {
+		return window{}, fmt.Errorf("invalid schedule rule '%s', expected 'HH:MM-HH:MM/interval' or 'cron <expr>'", rule)
+	}
+	startPart, endPart, ok := strings.Cut(bounds, "-")
+	if !ok {
+		return window{}, fmt.Errorf("invalid window '%s', expected 'HH:MM-HH:MM'", bounds)
+	}
+
+	start, err := parseTimeOfDay(startPart)
+	if err != nil {
+		return window{}, err
+	}
+	end, err := parseTimeOfDay(endPart)
+	if err != nil {
+		return window{}, err
+	}
+	if start == end {
+		return window{}, fmt.Errorf("window '%s' is empty", bounds)
+	}
+
+	interval, err := time.ParseDuration(strings.TrimSpace(intervalPart))
+	if err != nil || interval <= 0 {
+		return window{}, fmt.Errorf("invalid interval '%s' in rule '%s'", intervalPart, rule)
+	}
+
+	return window{start: start, end: end, interval: interval}, nil
+}
+
+func parseTimeOfDay(s string) (int, error) {
+	t, err := time.Parse(timeOfDayLayout, strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time '%s', expected HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Next возвращает время следующего запуска после from со случайной задержкой
+func (s *Schedule) Next(from time.Time) time.Time {
+	next := s.next(from)
+	if s.jitter > 0 {
+		next = next.Add(time.Duration(rand.Int63n(int64(s.jitter))))
+	}
+	return next
+}
+
+// next возвращает время следующего запуска без случайной задержки: через интервал текущего окна
+// (или интервал по умолчанию), но не позже конца текущего окна, начала ближайшего окна и срабатывания cron выражения.
+// Если from попадает в несколько окон, используется самый короткий из их интервалов
+func (s *Schedule) next(from time.Time) time.Time {
+	from = from.In(s.loc)
+
+	interval, inWindow := s.interval, false
+	for _, w := range s.windows {
+		if w.contains(from) && (!inWindow || w.interval < interval) {
+			interval, inWindow = w.interval, true
+		}
+	}
+	next := from.Add(interval)
+
+	for _, w := range s.windows {
+		if start := w.nextStart(from); start.Before(next) {
+			next = start
+		}
+		// После окна с длинным интервалом запуск не откладывается дальше его конца
+		if w.contains(from) {
+			if end := w.nextEnd(from); end.Before(next) {
+				next = end
+			}
+		}
+	}
+	for _, c := range s.crons {
+		if t := c.next(from); !t.IsZero() && t.Before(next) {
+			next = t
+		}
+	}
+
+	return next
+}
+
+// contains проверяет, попадает ли t в окно
+func (w window) contains(t time.Time) bool {
+	m := t.Hour()*60 + t.Minute()
+	if w.start < w.end {
+		return m >= w.start && m < w.end
+	}
+	return m >= w.start || m < w.end
+}
+
+// nextStart возвращает ближайшее начало окна строго после from
+func (w window) nextStart(from time.Time) time.Time {
+	start := time.Date(from.Year(), from.Month(), from.Day(), w.start/60, w.start%60, 0, 0, from.Location())
+	if !start.After(from) {
+		start = start.AddDate(0, 0, 1)
+	}
+	return start
+}
+
+// nextEnd возвращает ближайший конец окна строго после from
+func (w window) nextEnd(from time.Time) time.Time {
+	end := time.Date(from.Year(), from.Month(), from.Day(), w.end/60, w.end%60, 0, 0, from.Location())
+	if !end.After(from) {
+		end = end.AddDate(0, 0, 1)
+	}
+	return end
+}
+
+// String возвращает описание расписания для лога
+func (s *Schedule) String() string {
+	var rules []string
+	for _, w := range s.windows {
+		rules = append(rules, fmt.Sprintf("every %s %s-%s", w.interval, formatTimeOfDay(w.start), formatTimeOfDay(w.end)))
+	}
+	for _, c := range s.crons {
+		rules = append(rules, "cron "+c.spec)
+	}
+	rules = append(rules, "every "+s.interval.String()+" otherwise")
+
+	desc := strings.Join(rules, ", ") + " (" + s.loc.String() + ")"
+	if s.jitter > 0 {
+		desc += ", jitter up to " + s.jitter.String()
+	}
+	return desc
+}
+
+func formatTimeOfDay(m int) string {
+	return fmt.Sprintf("%02d:%02d", (m/60)%24, m%60)
+}
@@ -0,0 +1,333 @@
+package schedule
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// testLoc часовой пояс расписания в тестах, отличный от UTC
+var testLoc = time.FixedZone("MSK", 3*60*60)
+
+func at(year int, month time.Month, day, hour, min int) time.Time {
+	return time.Date(year, month, day, hour, min, 0, 0, testLoc)
+}
+
+func TestNewInvalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		spec     string
+		interval time.Duration
+		jitter   time.Duration
+		wantErr  string
+	}{
+		{name: "negative interval", spec: "", interval: -time.Minute, wantErr: "interval must be positive"},
+		{name: "negative jitter", spec: "", jitter: -time.Second, wantErr: "jitter must not be negative"},
+		{name: "window without interval", spec: "08:55-09:30", wantErr: "expected 'HH:MM-HH:MM/interval' or 'cron <expr>'"},
+		{name: "window without end", spec: "08:55/2m", wantErr: "expected 'HH:MM-HH:MM'"},
+		{name: "invalid start", spec: "25:00-09:30/2m", wantErr: "invalid time '25:00'"},
+		{name: "invalid end", spec: "08:55-9.30/2m", wantErr: "invalid time '9.30'"},
+		{name: "empty window", spec: "09:00-09:00/2m", wantErr: "window '09:00-09:00' is empty"},
+		{name: "invalid window interval", spec: "08:55-09:30/2", wantErr: "invalid interval '2'"},
+		{name: "zero window interval", spec: "08:55-09:30/0s", wantErr: "invalid interval '0s'"},
+		{name: "invalid rule after valid one", spec: "08:55-09:30/2m; every 5m", wantErr: "invalid schedule rule 'every 5m'"},
+		{name: "cron with 4 fields", spec: "cron 0 9 * *", wantErr: "must have 5 fields"},
+		{name: "cron minute out of range", spec: "cron 60 9 * * *", wantErr: "'60' is out of range 0-59"},
+		{name: "cron day of week out of range", spec: "cron 0 9 * * 7", wantErr: "'7' is out of range 0-6"},
+		{name: "cron day of month zero", spec: "cron 0 9 0 * *", wantErr: "'0' is out of range 1-31"},
+		{name: "cron reversed range", spec: "cron 0 17-9 * * *", wantErr: "'17-9' is out of range 0-23"},
+		{name: "cron zero step", spec: "cron */0 * * * *", wantErr: "invalid step in '*/0'"},
+		{name: "cron invalid value", spec: "cron 0 9 * * mon", wantErr: "invalid value in 'mon'"},
+		{name: "cron invalid range", spec: "cron 0 9-x * * *", wantErr: "invalid range in '9-x'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			interval := tt.interval
+			if interval == 0 {
+				interval = 30 * time.Minute
+			}
+
+			_, err := New(tt.spec, interval, testLoc, tt.jitter)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("New() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestScheduleNext(t *testing.T) {
+	tests := []struct {
+		name     string
+		spec     string
+		interval time.Duration
+		from     time.Time
+		want     time.Time
+	}{
+		{
+			name: "default interval",
+			spec: " ; ",
+			from: at(2024, time.May, 14, 10, 7),
+			want: at(2024, time.May, 14, 10, 37),
+		},
+		{
+			name: "window interval",
+			spec: "08:55-09:30/2m",
+			from: at(2024, time.May, 14, 9, 0),
+			want: at(2024, time.May, 14, 9, 2),
+		},
+		{
+			name: "start of next window",
+			spec: "08:55-09:30/2m",
+			from: at(2024, time.May, 14, 8, 40),
+			want: at(2024, time.May, 14, 8, 55),
+		},
+		{
+			name: "start of window tomorrow",
+			spec: "08:55-09:30/2m",
+			from: at(2024, time.May, 14, 23, 50),
+			want: at(2024, time.May, 15, 0, 20),
+		},
+		{
+			name: "window start is not inside the window",
+			spec: "08:55-09:30/2m",
+			from: at(2024, time.May, 14, 9, 30),
+			want: at(2024, time.May, 14, 10, 0),
+		},
+		{
+			name: "window crossing midnight, before midnight",
+			spec: "23:00-01:00/5m",
+			from: at(2024, time.May, 14, 23, 50),
+			want: at(2024, time.May, 14, 23, 55),
+		},
+		{
+			name: "window crossing midnight, after midnight",
+			spec: "23:00-01:00/5m",
+			from: at(2024, time.May, 14, 0, 58),
+			want: at(2024, time.May, 14, 1, 0),
+		},
+		{
+			name: "window crossing midnight, after end",
+			spec: "23:00-01:00/5m",
+			from: at(2024, time.May, 14, 1, 0),
+			want: at(2024, time.May, 14, 1, 30),
+		},
+		{
+			name: "window crossing midnight, start",
+			spec: "23:00-01:00/5m",
+			from: at(2024, time.May, 14, 22, 45),
+			want: at(2024, time.May, 14, 23, 0),
+		},
+		{
+			name: "window slows polling",
+			spec: "00:00-06:00/1h",
+			from: at(2024, time.May, 14, 2, 0),
+			want: at(2024, time.May, 14, 3, 0),
+		},
+		{
+			name: "slow window ends before its interval",
+			spec: "00:00-06:00/1h",
+			from: at(2024, time.May, 14, 5, 30),
+			want: at(2024, time.May, 14, 6, 0),
+		},
+		{
+			name: "slow window crossing midnight",
+			spec: "22:00-06:00/2h",
+			from: at(2024, time.May, 14, 23, 0),
+			want: at(2024, time.May, 15, 1, 0),
+		},
+		{
+			name: "overlapping windows, shortest interval",
+			spec: "00:00-23:59/1h; 08:55-09:30/2m",
+			from: at(2024, time.May, 14, 9, 0),
+			want: at(2024, time.May, 14, 9, 2),
+		},
+		{
+			name: "cron before interval",
+			spec: "cron 0 14 * * 1-5",
+			from: at(2024, time.May, 14, 13, 50),
+			want: at(2024, time.May, 14, 14, 0),
+		},
+		{
+			name: "cron on weekend does not fire",
+			spec: "cron 0 14 * * 1-5",
+			from: at(2024, time.May, 18, 13, 50),
+			want: at(2024, time.May, 18, 14, 20),
+		},
+		{
+			name:     "seconds are kept",
+			spec:     "",
+			interval: time.Minute,
+			from:     time.Date(2024, time.May, 14, 10, 7, 30, 0, testLoc),
+			want:     time.Date(2024, time.May, 14, 10, 8, 30, 0, testLoc),
+		},
+		{
+			name: "from in another time zone",
+			spec: "08:55-09:30/2m",
+			from: time.Date(2024, time.May, 14, 6, 0, 0, 0, time.UTC),
+			want: at(2024, time.May, 14, 9, 2),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			interval := tt.interval
+			if interval == 0 {
+				interval = 30 * time.Minute
+			}
+
+			s, err := New(tt.spec, interval, testLoc, 0)
+			if err != nil {
+				t.Fatalf("New() error: %v", err)
+			}
+
+			if got := s.Next(tt.from); !got.Equal(tt.want) {
+				t.Errorf("Next(%s) = %s, want %s", tt.from, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	tests := []struct {
+		name string
+		spec string
+		from time.Time
+		// want нулевое время - срабатываний нет в течение года
+		want time.Time
+	}{
+		{
+			name: "step",
+			spec: "*/15 * * * *",
+			from: at(2024, time.May, 14, 10, 7),
+			want: at(2024, time.May, 14, 10, 15),
+		},
+		{
+			name: "strictly after from",
+			spec: "*/15 * * * *",
+			from: at(2024, time.May, 14, 10, 15),
+			want: at(2024, time.May, 14, 10, 30),
+		},
+		{
+			name: "list and range with step",
+			spec: "5,50 8-18/5 * * *",
+			from: at(2024, time.May, 14, 13, 51),
+			want: at(2024, time.May, 14, 18, 5),
+		},
+		{
+			name: "day rollover",
+			spec: "30 8 * * *",
+			from: at(2024, time.May, 14, 9, 0),
+			want: at(2024, time.May, 15, 8, 30),
+		},
+		{
+			name: "day of week only",
+			spec: "0 9 * * 1",
+			from: at(2024, time.May, 14, 10, 0),
+			want: at(2024, time.May, 20, 9, 0),
+		},
+		{
+			name: "sunday",
+			spec: "0 9 * * 0",
+			from: at(2024, time.May, 14, 10, 0),
+			want: at(2024, time.May, 19, 9, 0),
+		},
+		{
+			name: "day of month or day of week, day of week first",
+			spec: "0 9 13 * 5",
+			from: at(2024, time.May, 14, 10, 0),
+			want: at(2024, time.May, 17, 9, 0),
+		},
+		{
+			name: "day of month or day of week, day of month first",
+			spec: "0 9 13 * 5",
+			from: at(2024, time.June, 11, 10, 0),
+			want: at(2024, time.June, 13, 9, 0),
+		},
+		{
+			name: "day of month with star step and day of week",
+			spec: "0 9 */2 * 1",
+			from: at(2024, time.May, 14, 10, 0),
+			// Поле дня месяца начинается с "*", поэтому должны совпасть оба: нечетное число и понедельник
+			want: at(2024, time.May, 27, 9, 0),
+		},
+		{
+			name: "month rollover",
+			spec: "0 0 1 * *",
+			from: at(2024, time.January, 31, 12, 0),
+			want: at(2024, time.February, 1, 0, 0),
+		},
+		{
+			name: "day missing in month",
+			spec: "0 9 31 * *",
+			from: at(2024, time.April, 15, 0, 0),
+			want: at(2024, time.May, 31, 9, 0),
+		},
+		{
+			name: "year rollover",
+			spec: "0 0 1 1 *",
+			from: at(2024, time.June, 1, 0, 0),
+			want: at(2025, time.January, 1, 0, 0),
+		},
+		{
+			name: "month restricted",
+			spec: "30 8 * 3 *",
+			from: at(2024, time.April, 1, 0, 0),
+			want: at(2025, time.March, 1, 8, 30),
+		},
+		{
+			name: "leap day within a year",
+			spec: "0 0 29 2 *",
+			from: at(2023, time.June, 1, 0, 0),
+			want: at(2024, time.February, 29, 0, 0),
+		},
+		{
+			name: "no match within a year",
+			spec: "0 0 29 2 *",
+			from: at(2024, time.March, 1, 0, 0),
+		},
+		{
+			name: "impossible date",
+			spec: "0 0 31 2 *",
+			from: at(2024, time.January, 1, 0, 0),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := parseCron(tt.spec)
+			if err != nil {
+				t.Fatalf("parseCron() error: %v", err)
+			}
+
+			if got := c.next(tt.from); !got.Equal(tt.want) {
+				t.Errorf("next(%s) = %s, want %s", tt.from, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScheduleJitter(t *testing.T) {
+	const jitter = 20 * time.Second
+
+	s, err := New("08:55-09:30/2m", 30*time.Minute, testLoc, jitter)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	from := at(2024, time.May, 14, 9, 0)
+	base := at(2024, time.May, 14, 9, 2)
+	var minDelay, maxDelay time.Duration = jitter, 0
+	for i := 0; i < 1000; i++ {
+		delay := s.Next(from).Sub(base)
+		if delay < 0 || delay >= jitter {
+			t.Fatalf("Next() delay = %s, want in [0, %s)", delay, jitter)
+		}
+		minDelay, maxDelay = min(minDelay, delay), max(maxDelay, delay)
+	}
+
+	// Задержка случайная, а не постоянная
+	if maxDelay-minDelay < jitter/2 {
+		t.Errorf("delays in [%s, %s], want spread over jitter %s", minDelay, maxDelay, jitter)
+	}
+}
@@ -0,0 +1,97 @@
+package secrets
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// SchemeVault префикс ссылок на секреты в HashiCorp Vault: "vault:secret/data/visasolution#smtp_password"
+const SchemeVault = "vault"
+
+// knownSchemes схемы ссылок, которые распознаются, даже если провайдер не настроен.
+// Так ссылка на ненастроенное хранилище приводит к ошибке, а не используется как значение
+var knownSchemes = []string{SchemeVault}
+
+// Provider источник секретов
+type Provider interface {
+	// Get возвращает значение секрета по ссылке без префикса схемы
+	Get(ref string) (string, error)
+}
+
+// Resolver заменяет ссылки на секреты вида "<scheme>:<ref>" значениями из зарегистрированных провайдеров
+type Resolver struct {
+	providers map[string]Provider
+}
+
+func NewResolver() *Resolver {
+	return &Resolver{providers: make(map[string]Provider)}
+}
+
+// Register регистрирует провайдер для ссылок со схемой scheme
+func (r *Resolver) Register(scheme string, p Provider) {
+	r.providers[scheme] = p
+}
+
+// Resolve возвращает значение секрета, если value - ссылка на секрет.
+// Второе значение - схема ссылки, пустая строка, если value не ссылка и возвращается без изменений
+func (r *Resolver) Resolve(value string) (string, string, error) {
+	scheme, ref, ok := strings.Cut(value, ":")
+	if !ok {
+		return value, "", nil
+	}
+
+	p, registered := r.providers[scheme]
+	if !registered {
+		for _, known := range knownSchemes {
+			if scheme == known {
+				return "", scheme, fmt.Errorf("secret provider '%s' is not configured", scheme)
+			}
+		}
+		return value, "", nil
+	}
+
+	secret, err := p.Get(ref)
+	if err != nil {
+		return "", scheme, fmt.Errorf("failed to get secret from %s: %w", scheme, err)
+	}
+
+	return secret, scheme, nil
+}
+
+// FromEnv создает Resolver с провайдерами, настроенными переменными окружения процесса:
+// VAULT_ADDR и VAULT_TOKEN (или VAULT_TOKEN_FILE) для HashiCorp Vault
+func FromEnv() (*Resolver, error) {
+	r := NewResolver()
+
+	vaultAddr := os.Getenv("VAULT_ADDR")
+	if vaultAddr == "" {
+		return r, nil
+	}
+
+	token := os.Getenv("VAULT_TOKEN")
+	if tokenFile := os.Getenv("VAULT_TOKEN_FILE"); token == "" && tokenFile != "" {
+		var err error
+		token, err = ReadFile(tokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("VAULT_TOKEN_FILE: %w", err)
+		}
+	}
+	if token == "" {
+		return nil, fmt.Errorf("VAULT_TOKEN or VAULT_TOKEN_FILE is required when VAULT_ADDR is set")
+	}
+
+	r.Register(SchemeVault, NewVaultKV(vaultAddr, token))
+
+	return r, nil
+}
+
+// ReadFile читает секрет из файла (например, Docker или Kubernetes secret), отбрасывая завершающий перевод строки
+func ReadFile(filePath string) (string, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret file: %w", err)
+	}
+
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
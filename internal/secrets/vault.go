@@ -0,0 +1,100 @@
+package secrets
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const vaultTimeout = 10 * time.Second
+
+// VaultKV читает секреты из KV хранилища HashiCorp Vault (версий 1 и 2) через HTTP API
+type VaultKV struct {
+	addr   string
+	token  string
+	client *http.Client
+
+	// cache прочитанные секреты по пути, чтобы не запрашивать один путь для каждого ключа
+	cache map[string]map[string]any
+}
+
+type vaultResponse struct {
+	Data   map[string]any `json:"data"`
+	Errors []string       `json:"errors"`
+}
+
+// NewVaultKV создает провайдер для Vault по адресу addr (например, http://127.0.0.1:8200) с токеном token
+func NewVaultKV(addr, token string) *VaultKV {
+	return &VaultKV{
+		addr:   strings.TrimRight(addr, "/"),
+		token:  token,
+		client: &http.Client{Timeout: vaultTimeout},
+		cache:  make(map[string]map[string]any),
+	}
+}
+
+// Get принимает ссылку вида "path#key", где path - путь API без префикса /v1/
+// (для KV v2 - с сегментом data: "secret/data/visasolution"), key - ключ внутри секрета
+func (v *VaultKV) Get(ref string) (string, error) {
+	secretPath, key, ok := strings.Cut(ref, "#")
+	if !ok || secretPath == "" || key == "" {
+		return "", fmt.Errorf("invalid vault reference '%s', expected 'path#key'", ref)
+	}
+
+	data, err := v.read(strings.Trim(secretPath, "/"))
+	if err != nil {
+		return "", err
+	}
+
+	value, ok := data[key]
+	if !ok {
+		return "", fmt.Errorf("key '%s' not found in secret '%s'", key, secretPath)
+	}
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+
+	return fmt.Sprint(value), nil
+}
+
+// read возвращает ключи секрета по пути. Для KV v2 значения находятся во вложенном поле data
+func (v *VaultKV) read(secretPath string) (map[string]any, error) {
+	if data, ok := v.cache[secretPath]; ok {
+		return data, nil
+	}
+
+	req, err := http.NewRequest(http.MethodGet, v.addr+"/v1/"+secretPath, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create vault request: %w", err)
+	}
+	req.Header.Set("X-Vault-Token", v.token)
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("vault request error: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var body vaultResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil && resp.StatusCode == http.StatusOK {
+		return nil, fmt.Errorf("failed to decode vault response: %w", err)
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, fmt.Errorf("secret '%s' not found", secretPath)
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("vault returned %s: %s", resp.Status, strings.Join(body.Errors, "; "))
+	}
+
+	data := body.Data
+	if inner, ok := data["data"].(map[string]any); ok {
+		if _, isV2 := data["metadata"]; isV2 {
+			data = inner
+		}
+	}
+	v.cache[secretPath] = data
+
+	return data, nil
+}
@@ -0,0 +1,265 @@
+package secrets
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+const testVaultToken = "s.test-token"
+
+// newTestVault поднимает сервер с HTTP API Vault: KV v2 смонтирован в secret/, KV v1 - в kv/.
+// Возвращает адрес сервера и счетчик запросов
+func newTestVault(t *testing.T) (string, *atomic.Int32) {
+	t.Helper()
+
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+
+		if r.Header.Get("X-Vault-Token") != testVaultToken {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+
+		switch r.URL.Path {
+		case "/v1/secret/data/visasolution":
+			w.Write([]byte(`{"data":{"data":{"smtp_password":"v2-secret","port":587},"metadata":{"version":3}}}`))
+		case "/v1/kv/visasolution":
+			// В KV v1 поле data может содержать ключ "data", это обычный ключ секрета
+			w.Write([]byte(`{"data":{"smtp_password":"v1-secret","data":{"nested":"value"}}}`))
+		case "/v1/secret/data/broken":
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"errors":["internal error","storage unavailable"]}`))
+		case "/v1/secret/data/garbage":
+			w.Write([]byte(`not json`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors":[]}`))
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv.URL, &requests
+}
+
+func TestVaultKVGet(t *testing.T) {
+	addr, _ := newTestVault(t)
+
+	tests := []struct {
+		name    string
+		token   string
+		ref     string
+		want    string
+		wantErr string
+	}{
+		{
+			name: "kv v2",
+			ref:  "secret/data/visasolution#smtp_password",
+			want: "v2-secret",
+		},
+		{
+			name: "kv v2, non-string value",
+			ref:  "secret/data/visasolution#port",
+			want: "587",
+		},
+		{
+			name: "kv v1",
+			ref:  "kv/visasolution#smtp_password",
+			want: "v1-secret",
+		},
+		{
+			name:    "kv v1 data key is not unwrapped",
+			ref:     "kv/visasolution#nested",
+			wantErr: "key 'nested' not found in secret 'kv/visasolution'",
+		},
+		{
+			name: "leading and trailing slashes",
+			ref:  "/secret/data/visasolution/#smtp_password",
+			want: "v2-secret",
+		},
+		{
+			name:    "secret not found",
+			ref:     "secret/data/missing#smtp_password",
+			wantErr: "secret 'secret/data/missing' not found",
+		},
+		{
+			name:    "key not found",
+			ref:     "secret/data/visasolution#imgur_secret",
+			wantErr: "key 'imgur_secret' not found in secret 'secret/data/visasolution'",
+		},
+		{
+			name:    "error body",
+			ref:     "secret/data/broken#smtp_password",
+			wantErr: "vault returned 500 Internal Server Error: internal error; storage unavailable",
+		},
+		{
+			name:    "invalid response",
+			ref:     "secret/data/garbage#smtp_password",
+			wantErr: "failed to decode vault response",
+		},
+		{
+			name:    "wrong token",
+			token:   "s.wrong",
+			ref:     "secret/data/visasolution#smtp_password",
+			wantErr: "vault returned 403 Forbidden: permission denied",
+		},
+		{
+			name:    "reference without key",
+			ref:     "secret/data/visasolution",
+			wantErr: "expected 'path#key'",
+		},
+		{
+			name:    "reference with empty path",
+			ref:     "#smtp_password",
+			wantErr: "expected 'path#key'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := tt.token
+			if token == "" {
+				token = testVaultToken
+			}
+
+			got, err := NewVaultKV(addr+"/", token).Get(tt.ref)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Get() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Get() error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Get() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVaultKVCache(t *testing.T) {
+	addr, requests := newTestVault(t)
+	v := NewVaultKV(addr, testVaultToken)
+
+	for _, ref := range []string{"secret/data/visasolution#smtp_password", "secret/data/visasolution#port", "kv/visasolution#smtp_password"} {
+		if _, err := v.Get(ref); err != nil {
+			t.Fatalf("Get(%s) error: %v", ref, err)
+		}
+	}
+
+	if n := requests.Load(); n != 2 {
+		t.Errorf("vault requests = %d, want one per secret path", n)
+	}
+}
+
+func TestResolverFromEnv(t *testing.T) {
+	addr, _ := newTestVault(t)
+	tokenFile := filepath.Join(t.TempDir(), "vault_token")
+	if err := os.WriteFile(tokenFile, []byte(testVaultToken+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		env        map[string]string
+		value      string
+		want       string
+		wantScheme string
+		// wantEnvErr ошибка FromEnv, wantErr - ошибка Resolve
+		wantEnvErr string
+		wantErr    string
+	}{
+		{
+			name:       "vault reference",
+			env:        map[string]string{"VAULT_ADDR": addr, "VAULT_TOKEN": testVaultToken},
+			value:      "vault:secret/data/visasolution#smtp_password",
+			want:       "v2-secret",
+			wantScheme: SchemeVault,
+		},
+		{
+			name:       "token from file",
+			env:        map[string]string{"VAULT_ADDR": addr, "VAULT_TOKEN_FILE": tokenFile},
+			value:      "vault:kv/visasolution#smtp_password",
+			want:       "v1-secret",
+			wantScheme: SchemeVault,
+		},
+		{
+			name:       "vault reference without VAULT_ADDR",
+			value:      "vault:secret/data/visasolution#smtp_password",
+			wantScheme: SchemeVault,
+			wantErr:    "secret provider 'vault' is not configured",
+		},
+		{
+			name:       "vault error",
+			env:        map[string]string{"VAULT_ADDR": addr, "VAULT_TOKEN": testVaultToken},
+			value:      "vault:secret/data/missing#smtp_password",
+			wantScheme: SchemeVault,
+			wantErr:    "failed to get secret from vault: secret 'secret/data/missing' not found",
+		},
+		{
+			name:  "plain value",
+			value: "password",
+			want:  "password",
+		},
+		{
+			name:  "value with unknown scheme",
+			value: "https://bls.test/",
+			want:  "https://bls.test/",
+		},
+		{
+			name:       "VAULT_ADDR without token",
+			env:        map[string]string{"VAULT_ADDR": addr},
+			wantEnvErr: "VAULT_TOKEN or VAULT_TOKEN_FILE is required",
+		},
+		{
+			name:       "missing token file",
+			env:        map[string]string{"VAULT_ADDR": addr, "VAULT_TOKEN_FILE": filepath.Join(t.TempDir(), "missing")},
+			wantEnvErr: "VAULT_TOKEN_FILE",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"VAULT_ADDR", "VAULT_TOKEN", "VAULT_TOKEN_FILE"} {
+				t.Setenv(key, tt.env[key])
+			}
+
+			r, err := FromEnv()
+			if tt.wantEnvErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantEnvErr) {
+					t.Fatalf("FromEnv() error = %v, want %q", err, tt.wantEnvErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("FromEnv() error: %v", err)
+			}
+
+			got, scheme, err := r.Resolve(tt.value)
+			if scheme != tt.wantScheme {
+				t.Errorf("Resolve() scheme = %q, want %q", scheme, tt.wantScheme)
+			}
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Resolve() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Resolve() error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Resolve() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,284 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"github.com/tebeka/selenium"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"visasolution/internal/pages"
+	util2 "visasolution/pkg/util"
+)
+
+// Поля формы выбора слота (id input'а без цифр). Селекторы элементов страниц бронирования берутся из набора (pages.BookingPages)
+const (
+	appointmentDateField = "AppointmentDate"
+	appointmentSlotField = "AppointmentSlot"
+)
+
+// calendarMonthsAhead количество месяцев после текущего, которые просматриваются в календаре
+const calendarMonthsAhead = 2
+
+// bookingReferenceRegexp номер записи на странице подтверждения
+var bookingReferenceRegexp = regexp.MustCompile(`(?i)(?:reference|appointment)\s*(?:no|number|#)\.?\s*[:#]?\s*([A-Z0-9/-]{4,})`)
+
+var BookingReferenceNotFoundError = errors.New("booking reference not found")
+
+// AppointmentDay доступная для записи дата и свободные слоты на нее
+type AppointmentDay struct {
+	Date  time.Time `json:"date"`
+	Slots []string  `json:"slots"`
+}
+
+// CountSlots возвращает общее количество слотов во всех днях
+func CountSlots(days []AppointmentDay) int {
+	var cnt int
+	for _, day := range days {
+		cnt += len(day.Slots)
+	}
+	return cnt
+}
+
+// AvailableDates возвращает доступные для записи даты из календаря на странице выбора слота.
+// Просматривается текущий месяц и calendarMonthsAhead следующих
+func (s *SeleniumService) AvailableDates() ([]time.Time, error) {
+	inputId, err := s.waitDisplayedInputId(s.pages.Booking.Inputs, appointmentDateField)
+	if err != nil {
+		return nil, fmt.Errorf("find appointment date input error: %w", err)
+	}
+
+	if err := s.waitAndClickButton(s.pages.Booking.CalendarOpener.With(inputId)); err != nil {
+		return nil, fmt.Errorf("open calendar error: %w", err)
+	}
+	// Календарь закрывается повторным кликом, чтобы не перекрывать остальные элементы формы
+	defer s.clickButton(s.pages.Booking.CalendarOpener.With(inputId))
+
+	var dates []time.Time
+	for month := 0; month <= calendarMonthsAhead; month++ {
+		if month > 0 {
+			if err := s.clickButton(s.pages.Booking.CalendarNext.With(inputId)); err != nil {
+				return nil, fmt.Errorf("switch calendar month error: %w", err)
+			}
+			time.Sleep(time.Millisecond * 500)
+		}
+
+		days, err := s.pages.Booking.CalendarDays.With(inputId).FindAll(s.wd)
+		if err != nil {
+			return nil, fmt.Errorf("find calendar days error: %w", err)
+		}
+
+		for _, day := range days {
+			value, err := day.GetAttribute("data-value")
+			if err != nil {
+				return nil, fmt.Errorf("get calendar day value error: %w", err)
+			}
+
+			date, err := parseCalendarValue(value)
+			if err != nil {
+				return nil, err
+			}
+			dates = append(dates, date)
+		}
+	}
+
+	return dates, nil
+}
+
+// SelectDate выбирает дату в календаре на странице выбора слота через API kendo datepicker
+func (s *SeleniumService) SelectDate(date time.Time) error {
+	inputId, err := s.waitDisplayedInputId(s.pages.Booking.Inputs, appointmentDateField)
+	if err != nil {
+		return fmt.Errorf("find appointment date input error: %w", err)
+	}
+
+	input, err := s.wd.FindElement(selenium.ByID, inputId)
+	if err != nil {
+		return fmt.Errorf("find appointment date input error: %w", err)
+	}
+
+	script := `
+    var picker = $(arguments[0]).data("kendoDatePicker");
+    picker.value(new Date(arguments[1], arguments[2], arguments[3]));
+    picker.trigger("change");
+`
+	// Месяцы в JS нумеруются с 0
+	_, err = s.wd.ExecuteScript(script, []interface{}{input, date.Year(), int(date.Month()) - 1, date.Day()})
+	if err != nil {
+		return fmt.Errorf("set appointment date error: %w", err)
+	}
+
+	return nil
+}
+
+// AvailableSlots возвращает видимый текст доступных слотов для выбранной даты
+func (s *SeleniumService) AvailableSlots() ([]string, error) {
+	inputId, err := s.waitDisplayedInputId(s.pages.Booking.Inputs, appointmentSlotField)
+	if err != nil {
+		return nil, fmt.Errorf("find appointment slot input error: %w", err)
+	}
+
+	_, texts, err := s.dropdownOptions(inputId)
+	if err != nil {
+		return nil, err
+	}
+
+	// Список закрывается повторным кликом
+	s.clickButton(s.pages.BookNew.DropdownOpener.With(inputId))
+
+	slots := make([]string, 0, len(texts))
+	for _, text := range texts {
+		if text != "" {
+			slots = append(slots, text)
+		}
+	}
+
+	return slots, nil
+}
+
+// SelectSlot выбирает слот по видимому тексту
+func (s *SeleniumService) SelectSlot(slot string) error {
+	inputId, err := s.waitDisplayedInputId(s.pages.Booking.Inputs, appointmentSlotField)
+	if err != nil {
+		return fmt.Errorf("find appointment slot input error: %w", err)
+	}
+
+	return s.selectDropdownOption(inputId, slot)
+}
+
+// SubmitSlot отправляет форму выбора слота и переходит к странице данных заявителя
+func (s *SeleniumService) SubmitSlot() error {
+	if err := s.waitAndClickButton(s.pages.Booking.SlotSubmit); err != nil {
+		return fmt.Errorf("submit slot error: %w", err)
+	}
+	return nil
+}
+
+// IsCaptchaPresent проверяет, есть ли на странице кнопка прохождения капчи
+func (s *SeleniumService) IsCaptchaPresent() (bool, error) {
+	elems, err := s.pages.Captcha.VerifyButton.FindAll(s.wd)
+	if err != nil {
+		return false, err
+	}
+	return len(elems) > 0, nil
+}
+
+// FillApplicantDetails заполняет страницу данных заявителя: выбирает заявителя по имени
+// (первого, если имя пустое) и отмечает все отображаемые чекбоксы согласий
+func (s *SeleniumService) FillApplicantDetails(applicantName string) error {
+	var err error
+	if applicantName != "" {
+		err = s.waitAndClickButton(s.pages.Booking.ApplicantLabel.WithText(applicantName))
+	} else {
+		err = s.waitAndClickButton(s.pages.Booking.FirstApplicant)
+	}
+	if err != nil {
+		return fmt.Errorf("select applicant error: %w", err)
+	}
+
+	checkboxes, err := s.pages.Booking.ConsentCheckboxes.FindAll(s.wd)
+	if err != nil {
+		return fmt.Errorf("find consent checkboxes error: %w", err)
+	}
+
+	for _, checkbox := range checkboxes {
+		displayed, err := checkbox.IsDisplayed()
+		if err != nil || !displayed {
+			continue
+		}
+
+		selected, err := checkbox.IsSelected()
+		if err != nil {
+			return fmt.Errorf("check consent checkbox error: %w", err)
+		}
+		if !selected {
+			if err := checkbox.Click(); err != nil {
+				return fmt.Errorf("click consent checkbox error: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// ConfirmBooking отправляет финальную форму бронирования
+func (s *SeleniumService) ConfirmBooking() error {
+	if err := s.waitAndClickButton(s.pages.Booking.Confirm); err != nil {
+		return fmt.Errorf("confirm booking error: %w", err)
+	}
+	return nil
+}
+
+// BookingReference возвращает номер записи со страницы подтверждения
+func (s *SeleniumService) BookingReference() (string, error) {
+	body, err := s.waitAndFind(pages.Tag("body"))
+	if err != nil {
+		return "", fmt.Errorf("find page body error: %w", err)
+	}
+
+	text, err := body.Text()
+	if err != nil {
+		return "", fmt.Errorf("get page text error: %w", err)
+	}
+
+	match := bookingReferenceRegexp.FindStringSubmatch(text)
+	if match == nil {
+		return "", BookingReferenceNotFoundError
+	}
+
+	return match[1], nil
+}
+
+// waitDisplayedInputId ожидает появления среди inputs отображаемого input'а, id которого без цифр совпадает с field, и возвращает его id
+func (s *SeleniumService) waitDisplayedInputId(inputs pages.Element, field string) (string, error) {
+	maxTries := 10                  // HARD CODED
+	delay := time.Millisecond * 500 // HARD CODED
+
+	for i := 0; i < maxTries; i++ {
+		elems, err := inputs.FindAll(s.wd)
+		if err != nil {
+			return "", err
+		}
+
+		for _, input := range elems {
+			id, err := input.GetAttribute("id")
+			if err != nil || util2.WithoutDigits(id) != field {
+				continue
+			}
+
+			// Сам input у kendo виджетов скрыт, поэтому проверяется отображение его контейнера
+			container, err := input.FindElement(selenium.ByXPATH, "./..")
+			if err != nil {
+				continue
+			}
+			if displayed, _ := container.IsDisplayed(); displayed {
+				return id, nil
+			}
+		}
+
+		time.Sleep(delay)
+	}
+
+	return "", fmt.Errorf("displayed input '%s' not found", field)
+}
+
+// parseCalendarValue разбирает значение data-value дня kendo календаря вида "2024/9/15".
+// Месяц в значении нумеруется с 0
+func parseCalendarValue(value string) (time.Time, error) {
+	parts := strings.Split(value, "/")
+	if len(parts) != 3 {
+		return time.Time{}, fmt.Errorf("invalid calendar value '%s'", value)
+	}
+
+	nums := make([]int, 3)
+	for i, part := range parts {
+		num, err := strconv.Atoi(part)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid calendar value '%s': %w", value, err)
+		}
+		nums[i] = num
+	}
+
+	return time.Date(nums[0], time.Month(nums[1]+1), nums[2], 0, 0, 0, 0, time.Local), nil
+}
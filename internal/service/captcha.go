@@ -0,0 +1,249 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/sashabaranov/go-openai"
+	"image"
+	"image/draw"
+	"image/png"
+	"log"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+	"visasolution/internal/apperr"
+	util "visasolution/pkg/util"
+)
+
+// captchaPrompt сообщение, которое отправляется chat api
+const captchaPrompt = `you see an image with the task: ‘Select all squares with the number …’ Recognize the text in each square and send ONLY the cell numbers that contain this number, separated by commas without spaces. Numbering is left to right starting with 1.Take your time when choosing cards. The wrong decision is costly ”`
+
+// captchaGridSize размер сетки карточек капчи
+const captchaGridSize = 3
+
+// CaptchaLayout расположение карточек капчи на ее изображении: в пикселях относительно левого верхнего угла iframe'а.
+// Вычисляется по размерам и положению изображений карточек, по нему же кликается по карточкам
+type CaptchaLayout struct {
+	// Header заголовок с искомым числом над сеткой
+	Header image.Rectangle
+	// Cards карточки сетки 3x3 слева направо, сверху вниз
+	Cards []image.Rectangle
+}
+
+// CaptchaImage изображение капчи в формате PNG и расположение карточек на нем
+type CaptchaImage struct {
+	PNG    []byte
+	Layout CaptchaLayout
+}
+
+// newCaptchaLayout вычисляет расположение карточек по прямоугольникам отображаемых изображений карточек.
+// Изображений либо 9 (по одному на карточку), либо одно на всю сетку, которое делится на 3x3
+func newCaptchaLayout(images []image.Rectangle) (CaptchaLayout, error) {
+	var cards []image.Rectangle
+	switch len(images) {
+	case captchaGridSize * captchaGridSize:
+		cards = append(cards, images...)
+		sort.Slice(cards, func(i, j int) bool {
+			// Карточки одной строки могут отличаться по вертикали на несколько пикселей
+			if dy := cards[i].Min.Y - cards[j].Min.Y; dy < -cards[i].Dy()/2 || dy > cards[i].Dy()/2 {
+				return dy < 0
+			}
+			return cards[i].Min.X < cards[j].Min.X
+		})
+	case 1:
+		grid := images[0]
+		cardW, cardH := grid.Dx()/captchaGridSize, grid.Dy()/captchaGridSize
+		for n := 0; n < captchaGridSize*captchaGridSize; n++ {
+			min := grid.Min.Add(image.Pt(n%captchaGridSize*cardW, n/captchaGridSize*cardH))
+			cards = append(cards, image.Rectangle{Min: min, Max: min.Add(image.Pt(cardW, cardH))})
+		}
+	default:
+		return CaptchaLayout{}, apperr.New(apperr.ClassLayoutChanged,
+			fmt.Sprintf("expected %d captcha card images or one grid image, found %d", captchaGridSize*captchaGridSize, len(images)))
+	}
+
+	grid := cards[0]
+	for _, c := range cards[1:] {
+		grid = grid.Union(c)
+	}
+	for _, c := range cards {
+		if c.Empty() {
+			return CaptchaLayout{}, apperr.New(apperr.ClassLayoutChanged, "captcha card image has zero size")
+		}
+	}
+
+	return CaptchaLayout{
+		Header: image.Rect(grid.Min.X, 0, grid.Max.X, grid.Min.Y),
+		Cards:  cards,
+	}, nil
+}
+
+// Card возвращает прямоугольник карточки с номером cardNum (с 1 по 9)
+func (l CaptchaLayout) Card(cardNum int) (image.Rectangle, error) {
+	if cardNum < 1 || cardNum > len(l.Cards) {
+		return image.Rectangle{}, fmt.Errorf("card number %d out of range 1-%d", cardNum, len(l.Cards))
+	}
+	return l.Cards[cardNum-1], nil
+}
+
+// tesseractBin имя исполняемого файла tesseract
+const tesseractBin = "tesseract"
+
+var digitsRegexp = regexp.MustCompile(`\d+`)
+
+// ChatCaptchaSolver решает капчу с помощью ChatGPT Vision
+type ChatCaptchaSolver struct {
+	chat Chat
+	// fallback используется для загрузки изображения на Imgur, если запрос с изображением в base64 не удался.
+	// Может быть nil
+	fallback Image
+}
+
+func NewChatCaptchaSolver(chat Chat, fallback Image) *ChatCaptchaSolver {
+	return &ChatCaptchaSolver{chat: chat, fallback: fallback}
+}
+
+// Solve отправляет изображение капчи в chat api в виде base64 data URL.
+// Если запрос не удался и задан fallback, изображение загружается на Imgur и запрос повторяется со ссылкой
+func (s *ChatCaptchaSolver) Solve(imagePath string, _ CaptchaLayout) ([]int, error) {
+	img, err := os.ReadFile(imagePath)
+	if err != nil {
+		return nil, fmt.Errorf("read captcha image error:%w", err)
+	}
+
+	resp, err := s.chat.Request4VPreviewWithImageBytes(captchaPrompt, img)
+	if err != nil && s.fallback != nil {
+		log.Println("request to chat api with inline image error, falling back to imgur:", err)
+		resp, err = s.requestWithUploadedImage(imagePath)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("request to chat api with image error:%w", err)
+	}
+
+	cardNums, err := util.StrToIntSlice(s.chat.GetRespMsg(resp), ",")
+	if err != nil && len(cardNums) == 0 {
+		return nil, fmt.Errorf("parse chat api response error:%w", err)
+	}
+
+	return cardNums, nil
+}
+
+// requestWithUploadedImage загружает изображение капчи на Imgur и отправляет ссылку в chat api
+func (s *ChatCaptchaSolver) requestWithUploadedImage(imagePath string) (openai.ChatCompletionResponse, error) {
+	link, err := s.fallback.UploadImage(imagePath)
+	if err != nil {
+		return openai.ChatCompletionResponse{}, fmt.Errorf("failed to upload captcha:%w", err)
+	}
+	log.Println("captcha was uploaded, link: ", link)
+
+	return s.chat.Request4VPreviewWithImage(captchaPrompt, link)
+}
+
+// OCRCaptchaSolver решает капчу локально: вырезает карточки сетки 3x3 и заголовок
+// и распознает числа на них с помощью tesseract
+type OCRCaptchaSolver struct {
+	// recognize распознает текст на изображении в формате PNG
+	recognize func(img []byte, digitsOnly bool) (string, error)
+}
+
+func NewOCRCaptchaSolver() *OCRCaptchaSolver {
+	return &OCRCaptchaSolver{recognize: tesseractRecognize}
+}
+
+// Solve возвращает номера карточек (с 1 по 9), число на которых совпадает с числом из заголовка капчи.
+// Карточки и заголовок вырезаются по расположению layout
+func (s *OCRCaptchaSolver) Solve(imagePath string, layout CaptchaLayout) ([]int, error) {
+	file, err := os.Open(imagePath)
+	if err != nil {
+		return nil, fmt.Errorf("open captcha image error:%w", err)
+	}
+	defer file.Close()
+
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return nil, fmt.Errorf("decode captcha image error:%w", err)
+	}
+
+	if len(layout.Cards) != captchaGridSize*captchaGridSize {
+		return nil, fmt.Errorf("captcha layout has %d cards, expected %d", len(layout.Cards), captchaGridSize*captchaGridSize)
+	}
+
+	headerText, err := s.recognizeRect(img, layout.Header, false)
+	if err != nil {
+		return nil, fmt.Errorf("recognize captcha header error:%w", err)
+	}
+
+	target := lastNumber(headerText)
+	if target == "" {
+		return nil, fmt.Errorf("target number not found in captcha header '%s'", headerText)
+	}
+
+	var cardNums []int
+	for n, card := range layout.Cards {
+		tileText, err := s.recognizeRect(img, card, true)
+		if err != nil {
+			return nil, fmt.Errorf("recognize card №%d error:%w", n+1, err)
+		}
+
+		if lastNumber(tileText) == target {
+			cardNums = append(cardNums, n+1)
+		}
+	}
+
+	if len(cardNums) == 0 {
+		return nil, fmt.Errorf("no cards with number %s recognized", target)
+	}
+
+	return cardNums, nil
+}
+
+// recognizeRect вырезает прямоугольник rect из изображения и распознает текст на нем
+func (s *OCRCaptchaSolver) recognizeRect(img image.Image, rect image.Rectangle, digitsOnly bool) (string, error) {
+	rect = rect.Add(img.Bounds().Min).Intersect(img.Bounds())
+	if rect.Empty() {
+		return "", errors.New("crop area is out of image bounds")
+	}
+
+	cropped := image.NewRGBA(image.Rect(0, 0, rect.Dx(), rect.Dy()))
+	draw.Draw(cropped, cropped.Bounds(), img, rect.Min, draw.Src)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, cropped); err != nil {
+		return "", fmt.Errorf("encode cropped image error:%w", err)
+	}
+
+	return s.recognize(buf.Bytes(), digitsOnly)
+}
+
+// lastNumber возвращает последнее число в строке или пустую строку, если чисел нет
+func lastNumber(text string) string {
+	nums := digitsRegexp.FindAllString(text, -1)
+	if len(nums) == 0 {
+		return ""
+	}
+	return nums[len(nums)-1]
+}
+
+// tesseractRecognize распознает текст на изображении с помощью tesseract, запущенного как отдельный процесс.
+// Изображение передается через stdin, результат читается из stdout
+func tesseractRecognize(img []byte, digitsOnly bool) (string, error) {
+	args := []string{"stdin", "stdout", "--psm", "7"}
+	if digitsOnly {
+		args = append(args, "-c", "tessedit_char_whitelist=0123456789")
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(tesseractBin, args...)
+	cmd.Stdin = bytes.NewReader(img)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("tesseract error: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return strings.TrimSpace(stdout.String()), nil
+}
@@ -0,0 +1,147 @@
+package service
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"visasolution/internal/apperr"
+)
+
+// testCardRects карточки 110x110 с отступом 5px, сетка начинается с (55, 120)
+func testCardRects() []image.Rectangle {
+	var rects []image.Rectangle
+	for n := 0; n < 9; n++ {
+		min := image.Pt(55+n%3*115, 120+n/3*115)
+		rects = append(rects, image.Rectangle{Min: min, Max: min.Add(image.Pt(110, 110))})
+	}
+	return rects
+}
+
+func TestNewCaptchaLayout(t *testing.T) {
+	cards := testCardRects()
+
+	// Изображения карточек в DOM идут не по порядку, строки смещены на пару пикселей
+	shuffled := []image.Rectangle{cards[8], cards[4], cards[0], cards[6], cards[2].Add(image.Pt(0, 2)), cards[5], cards[1].Sub(image.Pt(0, 1)), cards[7], cards[3]}
+	want := append([]image.Rectangle(nil), cards...)
+	want[2] = want[2].Add(image.Pt(0, 2))
+	want[1] = want[1].Sub(image.Pt(0, 1))
+
+	tests := []struct {
+		name       string
+		images     []image.Rectangle
+		wantCards  []image.Rectangle
+		wantHeader image.Rectangle
+		wantClass  string
+	}{
+		{
+			name:       "card images",
+			images:     shuffled,
+			wantCards:  want,
+			wantHeader: image.Rect(55, 0, 395, 119),
+		},
+		{
+			name:   "single grid image",
+			images: []image.Rectangle{image.Rect(10, 60, 340, 390)},
+			wantCards: []image.Rectangle{
+				image.Rect(10, 60, 120, 170), image.Rect(120, 60, 230, 170), image.Rect(230, 60, 340, 170),
+				image.Rect(10, 170, 120, 280), image.Rect(120, 170, 230, 280), image.Rect(230, 170, 340, 280),
+				image.Rect(10, 280, 120, 390), image.Rect(120, 280, 230, 390), image.Rect(230, 280, 340, 390),
+			},
+			wantHeader: image.Rect(10, 0, 340, 60),
+		},
+		{
+			name:      "unexpected number of images",
+			images:    cards[:4],
+			wantClass: apperr.ClassLayoutChanged,
+		},
+		{
+			name:      "no images",
+			wantClass: apperr.ClassLayoutChanged,
+		},
+		{
+			name:      "zero size image",
+			images:    []image.Rectangle{image.Rect(10, 10, 10, 10)},
+			wantClass: apperr.ClassLayoutChanged,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			layout, err := newCaptchaLayout(tt.images)
+			if class := apperr.ClassOf(err); class != tt.wantClass {
+				t.Fatalf("error class = %q, want %q (error: %v)", class, tt.wantClass, err)
+			}
+			if err != nil {
+				return
+			}
+			if !reflect.DeepEqual(layout.Cards, tt.wantCards) {
+				t.Errorf("Cards = %v, want %v", layout.Cards, tt.wantCards)
+			}
+			if layout.Header != tt.wantHeader {
+				t.Errorf("Header = %v, want %v", layout.Header, tt.wantHeader)
+			}
+		})
+	}
+}
+
+// TestOCRCaptchaSolverCrops проверяет, что решатель распознает ровно те области изображения, которые задает layout:
+// каждая карточка и заголовок закрашены своим цветом, а распознавание возвращает текст по цвету области
+func TestOCRCaptchaSolverCrops(t *testing.T) {
+	cards := testCardRects()
+	layout, err := newCaptchaLayout(cards)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, 450, 480))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(img, layout.Header, image.NewUniform(color.Gray{Y: 200}), image.Point{}, draw.Src)
+	for n, card := range cards {
+		draw.Draw(img, card, image.NewUniform(color.Gray{Y: uint8(10 + n)}), image.Point{}, draw.Src)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	imgPath := filepath.Join(t.TempDir(), "captcha.png")
+	if err := os.WriteFile(imgPath, buf.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Число 123 на карточках 2, 6 и 7
+	texts := map[uint8]string{200: "Please select all boxes with number 123", 11: "123", 15: "123", 16: "123"}
+	solver := &OCRCaptchaSolver{recognize: func(data []byte, digitsOnly bool) (string, error) {
+		cropped, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			return "", err
+		}
+
+		// Область должна быть закрашена одним цветом, иначе вырезано лишнее
+		b := cropped.Bounds()
+		first := color.GrayModel.Convert(cropped.At(b.Min.X, b.Min.Y)).(color.Gray).Y
+		for _, p := range []image.Point{{b.Max.X - 1, b.Min.Y}, {b.Min.X, b.Max.Y - 1}, {b.Max.X - 1, b.Max.Y - 1}} {
+			if y := color.GrayModel.Convert(cropped.At(p.X, p.Y)).(color.Gray).Y; y != first {
+				t.Errorf("crop %v is not a single area: colors %d and %d", b, first, y)
+			}
+		}
+		if text, ok := texts[first]; ok {
+			return text, nil
+		}
+		return "45", nil
+	}}
+
+	got, err := solver.Solve(imgPath, layout)
+	if err != nil {
+		t.Fatalf("Solve() error: %v", err)
+	}
+	if want := []int{2, 6, 7}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Solve() = %v, want %v", got, want)
+	}
+}
@@ -2,15 +2,21 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/sashabaranov/go-openai"
 	"net/http"
+	"visasolution/internal/apperr"
 	cfg "visasolution/internal/config"
 	pkgService "visasolution/pkg/service"
+	"visasolution/pkg/util"
 )
 
 const testMsgReq = "Hello, World!"
 
+// insufficientQuotaCode код ошибки OpenAI API при исчерпании квоты (в отличие от временного rate limit)
+const insufficientQuotaCode = "insufficient_quota"
+
 type ChatService struct {
 	token  string
 	client *openai.Client
@@ -26,7 +32,7 @@ func (s *ChatService) ClientInitWithProxy(proxy cfg.Proxy) error {
 	var err error
 	transport := http.DefaultTransport
 
-	if proxy.IsEmpty() {
+	if !proxy.IsEmpty() {
 		transport, err = pkgService.ProxyTransport(proxy.URL())
 		if err != nil {
 			return fmt.Errorf("failed to create proxy transport: %w", err)
@@ -51,7 +57,7 @@ func (s *ChatService) TestConnection() error {
 }
 
 func (s *ChatService) Request3DOT5Turbo(content string) (openai.ChatCompletionResponse, error) {
-	return s.client.CreateChatCompletion(
+	resp, err := s.client.CreateChatCompletion(
 		context.Background(),
 		openai.ChatCompletionRequest{
 			Model: openai.GPT3Dot5Turbo,
@@ -63,10 +69,11 @@ func (s *ChatService) Request3DOT5Turbo(content string) (openai.ChatCompletionRe
 			},
 		},
 	)
+	return resp, classifyChatError(err)
 }
 
 func (s *ChatService) Request4VPreviewWithImage(content, imageUrl string) (openai.ChatCompletionResponse, error) {
-	return s.client.CreateChatCompletion(
+	resp, err := s.client.CreateChatCompletion(
 		context.Background(),
 		openai.ChatCompletionRequest{
 			Model: openai.GPT4o,
@@ -89,8 +96,24 @@ func (s *ChatService) Request4VPreviewWithImage(content, imageUrl string) (opena
 			},
 		},
 	)
+	return resp, classifyChatError(err)
+}
+
+// Request4VPreviewWithImageBytes отправляет изображение в запросе в виде base64 data URL,
+// без загрузки на сторонний хостинг
+func (s *ChatService) Request4VPreviewWithImageBytes(content string, image []byte) (openai.ChatCompletionResponse, error) {
+	return s.Request4VPreviewWithImage(content, util.EncodeBase64Image(image))
 }
 
 func (s *ChatService) GetRespMsg(resp openai.ChatCompletionResponse) string {
 	return resp.Choices[0].Message.Content
 }
+
+// classifyChatError помечает ошибку исчерпания квоты OpenAI API: без пополнения баланса запросы не пройдут
+func classifyChatError(err error) error {
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) && (apiErr.Type == insufficientQuotaCode || apiErr.Code == insufficientQuotaCode) {
+		return apperr.Wrap(apperr.ClassQuotaExceeded, "chat api quota exceeded", err)
+	}
+	return err
+}
@@ -11,6 +11,7 @@ import (
 const (
 	emailTemplateFilename = "availbility-email-template.html"
 	emailSubject          = "VisaSolution| Запись на подачу документов"
+	emailFrom             = "visasolution@passwordhash.tech"
 	assetsFolder          = "assets/"
 	screenshotCID         = "12345"
 )
@@ -20,8 +21,6 @@ type EmailDeps struct {
 	Port     int
 	Username string
 	Password string
-
-	ScreenshotFilePath string
 }
 
 type EmailService struct {
@@ -32,8 +31,10 @@ func NewEmailService(d EmailDeps) *EmailService {
 	return &EmailService{d: d}
 }
 
-func (e *EmailService) SendAvailbilityNotification(to string) error {
-	screenshotFullPath := util.GetAbsolutePath(e.d.ScreenshotFilePath)
+// SendAvailbilityNotification отправляет письмо о результате проверки доступности записи.
+// screenshotPath - путь к скриншоту страницы, который прикрепляется к письму
+func (e *EmailService) SendAvailbilityNotification(to, screenshotPath string) error {
+	screenshotFullPath := util.GetAbsolutePath(screenshotPath)
 
 	emailTemplate, err := e.getEmailTemplate()
 	if err != nil {
@@ -45,7 +46,7 @@ func (e *EmailService) SendAvailbilityNotification(to string) error {
 
 	// прикрепляет картинку как вложение
 	m.Attach(screenshotFullPath)
-	m.SetHeader("From", "visasolution@passwordhash.tech")
+	m.SetHeader("From", emailFrom)
 	m.SetHeader("To", to)
 	m.SetHeader("Subject", emailSubject)
 	m.Embed(screenshotFullPath, gomail.SetHeader(map[string][]string{
@@ -53,11 +54,43 @@ func (e *EmailService) SendAvailbilityNotification(to string) error {
 	}))
 	m.SetBody("text/html", emailTemplate)
 
+	return e.dialer().DialAndSend(m)
+}
+
+// Notify отправляет уведомление на адрес заявителя.
+// О появлении записи отправляется письмо по шаблону, остальные уведомления - простым текстом.
+// Если адрес не задан, уведомления на почту для профиля отключены (например, только Telegram)
+func (e *EmailService) Notify(n Notification) error {
+	if n.Email == "" {
+		return nil
+	}
+	if n.Kind == NotificationAvailable && n.ScreenshotPath != "" {
+		return e.SendAvailbilityNotification(n.Email, n.ScreenshotPath)
+	}
+	return e.sendText(n.Email, n.Text, n.ScreenshotPath)
+}
+
+// sendText отправляет письмо с текстом text. Если screenshotPath не пустой, скриншот прикрепляется как вложение
+func (e *EmailService) sendText(to, text, screenshotPath string) error {
+	m := gomail.NewMessage()
+
+	if screenshotPath != "" {
+		m.Attach(util.GetAbsolutePath(screenshotPath))
+	}
+	m.SetHeader("From", emailFrom)
+	m.SetHeader("To", to)
+	m.SetHeader("Subject", emailSubject)
+	m.SetBody("text/plain", text)
+
+	return e.dialer().DialAndSend(m)
+}
+
+func (e *EmailService) dialer() *gomail.Dialer {
 	d := gomail.NewDialer(e.d.Host, e.d.Port, e.d.Username, e.d.Password)
 
 	d.TLSConfig = &tls.Config{InsecureSkipVerify: true}
 
-	return d.DialAndSend(m)
+	return d
 }
 
 func (e *EmailService) getEmailTemplate() (string, error) {
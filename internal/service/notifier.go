@@ -0,0 +1,66 @@
+package service
+
+import (
+	"errors"
+	"sync"
+	"visasolution/internal/apperr"
+)
+
+// Типы уведомлений
+const (
+	// NotificationAvailable появилась доступная запись
+	NotificationAvailable = "available"
+	// NotificationUnavailable доступная запись пропала
+	NotificationUnavailable = "unavailable"
+	// NotificationDigest ежедневная сводка
+	NotificationDigest = "digest"
+	// NotificationBooked запись забронирована (или бронирование остановлено перед подтверждением в режиме dry run)
+	NotificationBooked = "booked"
+	// NotificationAlert бот остановлен из-за повторяющихся ошибок и требует вмешательства
+	NotificationAlert = "alert"
+)
+
+// Notification уведомление для заявителя
+type Notification struct {
+	Kind    string
+	Profile string
+	// Email адрес заявителя для уведомлений. Используется EmailService
+	Email string
+	Text  string
+	// ScreenshotPath путь к скриншоту страницы. Может быть пустым
+	ScreenshotPath string
+}
+
+type Notifier interface {
+	Notify(n Notification) error
+}
+
+// MultiNotifier рассылает уведомление через все notifiers одновременно
+type MultiNotifier struct {
+	notifiers []Notifier
+}
+
+func NewMultiNotifier(notifiers ...Notifier) *MultiNotifier {
+	return &MultiNotifier{notifiers: notifiers}
+}
+
+// Notify отправляет уведомление через все notifiers. Ошибка одного из них не мешает остальным,
+// возвращаются все ошибки с классом apperr.ClassNotifierFailed
+func (m *MultiNotifier) Notify(n Notification) error {
+	errs := make([]error, len(m.notifiers))
+
+	var wg sync.WaitGroup
+	for i, notifier := range m.notifiers {
+		wg.Add(1)
+		go func(i int, notifier Notifier) {
+			defer wg.Done()
+			errs[i] = notifier.Notify(n)
+		}(i, notifier)
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return apperr.Wrap(apperr.ClassNotifierFailed, "notify error", err)
+	}
+	return nil
+}
@@ -5,84 +5,120 @@ import (
 	"fmt"
 	"github.com/tebeka/selenium"
 	"github.com/tebeka/selenium/chrome"
+	"image"
 	"log"
 	"strings"
 	"time"
+	"visasolution/internal/apperr"
+	cfg "visasolution/internal/config"
+	"visasolution/internal/metrics"
+	"visasolution/internal/pages"
+	"visasolution/internal/replay"
+	"visasolution/internal/session"
 	util2 "visasolution/pkg/util"
 )
 
-const authCookieKey = ".AspNetCore.Cookies"
-
-// Константы для нахождения элементов на странице
 const (
-	captchaIFrameXPath         = `//*[@id="popup_1"]/iframe`
-	captchaDragableCSSSelector = `body > div.k-widget.k-window`
-	captchaCardImgXPath        = `//*[@id="captcha-main-div"]/div/div[2]/div/img`
-	submitCaptchaXPath         = `//*[@id="captchaForm"]/div[2]/div[3]`
-
-	verifyBtnIdCSSSelector = `#btnVerify`
-
-	formInputsXPath            = `/html/body/main/main/div/div/div[2]/div[2]/form/div/input`
-	formSubmitId               = `btnSubmit`
-	bookNewAppointmentId       = `btnSubmit`
-	bookNewAppointmentSubmitId = `btnSubmit`
-	bookNewFormXPath           = `//*[@id="div-main"]/div/div/div[2]/form`
-	bookNewFormControlsXPath   = `//*[@id="div-main"]/div/div/div[2]/form/div`
-
-	commonModalId       = `commonModal`
-	commonModalHeaderId = `commonModalHeader`
+	availabilityCheckMsg = "No Appointments Available"
+	invalidSelectionMsg  = "Invalid selection"
+)
 
-	bookNewBtnXPath = `//*[@id="tns1-item1"]/div/div/div/div/a`
+// Признаки страниц ошибок в заголовке страницы (без учета регистра)
+var (
+	tooManyRequestsTitles = []string{"too many requests", "429"}
+	maintenanceTitles     = []string{"maintenance", "service unavailable", "503"}
 )
 
-const (
-	availabilityCheckMsg = "No Appointments Available"
-	invalidSelectionMsg  = "Invalid selection"
+// Коды сетевых ошибок Chrome в тексте ошибки перехода на страницу.
+// Блокировкой прокси сайтом они не считаются: ее признак - страница 429 (см. tooManyRequestsTitles)
+var (
+	// proxyNetErrors прокси отклонил авторизацию или соединение (в том числе ответ 407 на CONNECT)
+	proxyNetErrors = []string{"net::ERR_PROXY_AUTH", "net::ERR_TUNNEL_CONNECTION_FAILED", "net::ERR_PROXY_CONNECTION_FAILED"}
+	// unreachableNetErrors соединение через прокси не установлено вовремя или оборвано.
+	// Таймауты самого веб-драйвера (например, ожидание ответа от renderer) сюда не относятся
+	unreachableNetErrors = []string{
+		"net::ERR_TIMED_OUT", "net::ERR_CONNECTION_TIMED_OUT", "net::ERR_EMPTY_RESPONSE",
+		"net::ERR_CONNECTION_RESET", "net::ERR_CONNECTION_CLOSED",
+	}
 )
 
 // SeleniumLegacyCode тип для легаси кодов ошибок Selenium WebDriver
 type SeleniumLegacyCode int
 
 const (
-	invalidSessionId = iota
+	// invalidSessionId код "NoSuchDriver" протокола JSON Wire: сессия не найдена
+	invalidSessionId SeleniumLegacyCode = 6
 )
 
-var InvalidSelectionError = errors.New("captcha invalid selection")
+var InvalidSelectionError = apperr.New(apperr.ClassCaptchaInvalid, "captcha invalid selection")
 
-var InvalidSessionError = errors.New("invalid session id")
+var InvalidSessionError = apperr.New(apperr.ClassSessionLost, "invalid session id")
 
-// Количество табов до первого input'а в форме Book New Appointment
-const tabsCountToFirstInput = 14
+// TooManyRequestsError сайт ограничил запросы с текущего прокси
+var TooManyRequestsError = apperr.New(apperr.ClassTooManyRequests, "too many requests")
 
-// Нужное количество нажатий на стрелку вниз для выбора каждого элемента формы по id. -1 означает, что элемент не нужно выбирать
-var inputKeyDownCounts = map[string]int{
-	"self":                  -1,
-	"ApplicantsNo":          -1,
-	"JurisdictionId":        1,
-	"loc":                   4,
-	"VisaType":              1,
-	"VisaSubType":           9,
-	"AppointmentCategoryId": 1,
+// SiteMaintenanceError сайт на техническом обслуживании
+var SiteMaintenanceError = apperr.New(apperr.ClassSiteMaintenance, "site is under maintenance")
+
+// LoggedOutError сайт не авторизовал пользователя после отправки формы авторизации
+var LoggedOutError = apperr.New(apperr.ClassLoggedOut, "not authorized after login")
+
+// visaFormFields поля формы "Book New Appointment" (id input'а без цифр) в порядке заполнения.
+// Порядок важен: список опций следующего поля зависит от выбранного значения предыдущего
+var visaFormFields = []string{
+	"JurisdictionId",
+	"loc",
+	"VisaType",
+	"VisaSubType",
+	"AppointmentCategoryId",
+}
+
+// visaFormValues сопоставляет полям формы "Book New Appointment" видимый текст опции, который нужно выбрать
+func visaFormValues(prefs cfg.VisaPreferences) map[string]string {
+	return map[string]string{
+		"JurisdictionId":        prefs.Jurisdiction,
+		"loc":                   prefs.Location,
+		"VisaType":              prefs.VisaType,
+		"VisaSubType":           prefs.VisaSubType,
+		"AppointmentCategoryId": prefs.AppointmentCategory,
+	}
 }
 
 type SeleniumService struct {
 	wd selenium.WebDriver
+	// pages объекты страниц сайта с селекторами элементов
+	pages *pages.Pages
+	// recorder записывает страницы сайта после действий веб-драйвера. nil - запись отключена
+	recorder *replay.Recorder
 
 	maxTries    int
 	seleniumURL string
-	blsEmail    string
-	blsPassword string
 }
 
-func NewSeleniumService(maxTries int, blsEmail string, seleniumURL string, blsPassword string) *SeleniumService {
+// NewSeleniumService создает сервис для работы с Selenium WebDriver.
+// Если p равен nil, используются страницы со встроенным набором селекторов
+func NewSeleniumService(maxTries int, seleniumURL string, p *pages.Pages) *SeleniumService {
+	if p == nil {
+		p = pages.Default()
+	}
+
 	return &SeleniumService{
+		pages:       p,
 		maxTries:    maxTries,
-		blsEmail:    blsEmail,
 		seleniumURL: seleniumURL,
-		blsPassword: blsPassword,
 	}
 }
 
+// SetRecorder включает запись страниц сайта. Применяется при следующем подключении к веб-драйверу
+func (s *SeleniumService) SetRecorder(recorder *replay.Recorder) {
+	s.recorder = recorder
+}
+
+// SetWebDriver заменяет веб-драйвер без подключения к Selenium, например, заглушкой replay.Driver
+func (s *SeleniumService) SetWebDriver(wd selenium.WebDriver) {
+	s.wd = wd
+}
+
 // ConnectWithProxy подключается к selenium с прокси аутентификацией.
 // url - адрес нашего драйвера
 // chromeExtensionPath - путь к расширению для авторизации через прокси
@@ -115,8 +151,17 @@ func (s *SeleniumService) ConnectWithProxy(chromeExtensionPath string) error {
 		time.Sleep(3 * time.Second)
 	}
 
+	if err == nil && s.recorder != nil {
+		wd = s.recorder.Wrap(wd)
+	}
 	s.wd = wd
 
+	if err != nil {
+		metrics.WebDriverConnects.WithLabelValues("error").Inc()
+	} else {
+		metrics.WebDriverConnects.WithLabelValues("success").Inc()
+	}
+
 	return err
 }
 
@@ -133,23 +178,79 @@ func (s *SeleniumService) TestPage() error {
 	return nil
 }
 
-// GoTo переходит на страницу по url
+// GoTo переходит на страницу по url.
+// Ошибки перехода и страницы ошибок сайта (429, техническое обслуживание) возвращаются с классом (см. apperr)
 func (s *SeleniumService) GoTo(url string) error {
 	err := s.wd.Get(url)
+	if err != nil {
+		return classifyNavigationError(err)
+	}
+
+	title, err := s.wd.Title()
+	if err != nil {
+		// Заголовок нужен только для распознавания страниц ошибок
+		return nil
+	}
+	title = strings.ToLower(title)
+	switch {
+	case containsAny(title, tooManyRequestsTitles):
+		return TooManyRequestsError
+	case containsAny(title, maintenanceTitles):
+		return SiteMaintenanceError
+	}
+
+	return nil
+}
+
+// classifyNavigationError определяет класс ошибки перехода на страницу
+func classifyNavigationError(err error) error {
 	var seleniumErr *selenium.Error
 	if errors.As(err, &seleniumErr) {
-		if seleniumErr.LegacyCode == invalidSessionId || seleniumErr.Err == InvalidSessionError.Error() {
+		if SeleniumLegacyCode(seleniumErr.LegacyCode) == invalidSessionId || seleniumErr.Err == InvalidSessionError.Error() {
 			return InvalidSessionError
 		}
-		return err
 	}
 
-	return nil
+	msg := err.Error()
+	switch {
+	case containsAny(msg, proxyNetErrors):
+		return apperr.Wrap(apperr.ClassProxyAuthFailed, "proxy error", err)
+	case containsAny(msg, unreachableNetErrors):
+		return apperr.Wrap(apperr.ClassProxyUnreachable, "page load error", err)
+	}
+
+	return err
 }
 
-// IsAuthorized проверяет авторизован ли пользователь, но только для одной конкретной страницы - проверка по URL - VisaTypeVerification
-// TODO: сделать общий метод для проверки авторизации
+func containsAny(s string, substrs []string) bool {
+	for _, sub := range substrs {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsAuthorized проверяет авторизован ли пользователь по признакам на текущей странице: ссылке выхода
+// или форме авторизации. Если на странице нет ни одного признака, сравнивает текущий адрес с neededURL -
+// неавторизованного пользователя сайт перенаправляет на страницу авторизации
 func (s *SeleniumService) IsAuthorized(neededURL string) (bool, error) {
+	loggedIn, err := s.pages.Session.LoggedIn.FindAll(s.wd)
+	if err != nil {
+		return false, fmt.Errorf("find logged in marker error: %w", err)
+	}
+	if len(loggedIn) > 0 {
+		return true, nil
+	}
+
+	loggedOut, err := s.pages.Session.LoggedOut.FindAll(s.wd)
+	if err != nil {
+		return false, fmt.Errorf("find logged out marker error: %w", err)
+	}
+	if len(loggedOut) > 0 {
+		return false, nil
+	}
+
 	curURL, err := s.wd.CurrentURL()
 	if err != nil {
 		return false, err
@@ -160,7 +261,7 @@ func (s *SeleniumService) IsAuthorized(neededURL string) (bool, error) {
 
 // AuthCookie возвращает куки авторизации
 func (s *SeleniumService) AuthCookie() (selenium.Cookie, error) {
-	return s.wd.GetCookie(authCookieKey)
+	return s.wd.GetCookie(session.AuthCookie)
 }
 
 func (s *SeleniumService) Cookies() ([]selenium.Cookie, error) {
@@ -176,6 +277,42 @@ func (s *SeleniumService) SetCookies(cookies []selenium.Cookie) error {
 	return nil
 }
 
+// LocalStorage возвращает содержимое localStorage текущего сайта
+func (s *SeleniumService) LocalStorage() (map[string]string, error) {
+	const script = `return Object.assign({}, window.localStorage);`
+
+	res, err := s.wd.ExecuteScript(script, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	storage := make(map[string]string)
+	values, _ := res.(map[string]interface{})
+	for k, v := range values {
+		if str, ok := v.(string); ok {
+			storage[k] = str
+		}
+	}
+	return storage, nil
+}
+
+// SetLocalStorage записывает значения в localStorage текущего сайта. Страница должна быть открыта на сайте
+func (s *SeleniumService) SetLocalStorage(storage map[string]string) error {
+	if len(storage) == 0 {
+		return nil
+	}
+
+	const script = `
+		var items = arguments[0];
+		for (var key in items) {
+			window.localStorage.setItem(key, items[key]);
+		}
+	`
+
+	_, err := s.wd.ExecuteScript(script, []interface{}{storage})
+	return err
+}
+
 func (s *SeleniumService) DeleteCookie(name string) error {
 	return s.wd.DeleteCookie(name)
 }
@@ -201,39 +338,78 @@ func (s *SeleniumService) PullPageScreenshot() ([]byte, error) {
 	return s.wd.Screenshot()
 }
 
-// PullCaptchaImage возвращает изображение капчи в виде среза байт
-func (s *SeleniumService) PullCaptchaImage() ([]byte, error) {
+// PullCaptchaImage возвращает изображение капчи и расположение карточек на нем
+func (s *SeleniumService) PullCaptchaImage() (CaptchaImage, error) {
 	// переключаемся на iframe капчи, находим контейнер, возращаемся обратно,
 	// чтобы на скрине было видно содержимое капчи
 	var err error
 
-	iframe, err := s.waitAndSwitchIFrame(selenium.ByXPATH, captchaIFrameXPath)
+	captcha := s.pages.Captcha
+
+	iframe, err := s.waitAndSwitchIFrame(captcha.IFrame)
 	if err != nil {
-		return []byte{}, fmt.Errorf("switch iframe error:%w", err)
+		return CaptchaImage{}, fmt.Errorf("switch iframe error:%w", err)
 	}
 
-	_, err = s.wd.FindElement(selenium.ByCSSSelector, `#captcha-main-div > div`)
+	_, err = captcha.Container.Find(s.wd)
 	if err != nil {
-		return []byte{}, err
+		return CaptchaImage{}, err
+	}
+
+	layout, err := s.captchaLayout()
+	if err != nil {
+		return CaptchaImage{}, fmt.Errorf("captcha layout error:%w", err)
 	}
 
 	err = s.switchToDefault()
 	if err != nil {
-		return []byte{}, fmt.Errorf("switch to default frame error:%w", err)
+		return CaptchaImage{}, fmt.Errorf("switch to default frame error:%w", err)
 	}
 
 	img, err := iframe.Screenshot(false)
 	if err != nil {
-		return []byte{}, err
+		return CaptchaImage{}, err
 	}
 
-	return img, nil
+	return CaptchaImage{PNG: img, Layout: layout}, nil
+}
+
+// captchaLayout вычисляет расположение карточек по отображаемым изображениям карточек.
+// Должна вызываться внутри iframe капчи: координаты элементов отсчитываются от его левого верхнего угла
+func (s *SeleniumService) captchaLayout() (CaptchaLayout, error) {
+	cardImgs, err := s.pages.Captcha.CardImage.FindAll(s.wd)
+	if err != nil {
+		return CaptchaLayout{}, fmt.Errorf("find card images error:%w", err)
+	}
+
+	var rects []image.Rectangle
+	for _, img := range cardImgs {
+		displayed, err := img.IsDisplayed()
+		if err != nil || !displayed {
+			continue
+		}
+
+		location, err := img.Location()
+		if err != nil {
+			return CaptchaLayout{}, fmt.Errorf("card image location error:%w", err)
+		}
+		size, err := img.Size()
+		if err != nil {
+			return CaptchaLayout{}, fmt.Errorf("card image size error:%w", err)
+		}
+
+		rects = append(rects, image.Rect(location.X, location.Y, location.X+size.Width, location.Y+size.Height))
+	}
+
+	return newCaptchaLayout(rects)
 }
 
 // SolveCaptcha проходит уже решенную капчу. На вход принимает срез номеров карточек с 1 по 9
 // TODO: сделать возращение bool, свидетельствующее о том, что капча решена/не решена
 func (s *SeleniumService) SolveCaptcha(numbers []int) error {
-	dragable, err := s.wd.FindElement(selenium.ByCSSSelector, captchaDragableCSSSelector)
+	captcha := s.pages.Captcha
+
+	dragable, err := captcha.Window.Find(s.wd)
 	if err != nil {
 		return fmt.Errorf("find element 'dragable' error:%w", err)
 	}
@@ -246,31 +422,28 @@ func (s *SeleniumService) SolveCaptcha(numbers []int) error {
 		return fmt.Errorf("change element property error:%w", err)
 	}
 
-	_, err = s.waitAndSwitchIFrame(selenium.ByXPATH, captchaIFrameXPath)
+	_, err = s.waitAndSwitchIFrame(captcha.IFrame)
 	if err != nil {
 		return fmt.Errorf("switch iframe error:%w", err)
 	}
 	defer s.switchToDefault()
 
-	cardImg, err := s.wd.FindElement(selenium.ByXPATH, captchaCardImgXPath)
-	if err != nil {
-		return err
-	}
-
-	cardW, cardH, err := s.getElementSizes(cardImg)
+	// Клики по тем же прямоугольникам карточек, по которым решатель распознает изображение
+	layout, err := s.captchaLayout()
 	if err != nil {
-		return fmt.Errorf("getting card image sizes error:%w", err)
+		return fmt.Errorf("captcha layout error:%w", err)
 	}
 
-	// Вычисления в примерных значения
-	horPadding := cardW / 2
-	vertPadding := int(float64(cardH) * 1.15)
-
-	// Проходимся по номерам карточек и кликаем вычисленным координатам для каждого номера
+	// Проходимся по номерам карточек и кликаем по центру каждой
 	for _, n := range numbers {
 		time.Sleep(time.Millisecond * 200)
-		x, y := getCardCoordinates(n, cardW, cardH)
-		err = s.clickByCoords(x+horPadding, y+vertPadding)
+		card, err := layout.Card(n)
+		if err != nil {
+			return err
+		}
+
+		center := card.Min.Add(card.Size().Div(2))
+		err = s.clickByCoords(center.X, center.Y)
 		if err != nil {
 			return fmt.Errorf("click by coords for card number №%d error:%w", n, err)
 		}
@@ -278,7 +451,7 @@ func (s *SeleniumService) SolveCaptcha(numbers []int) error {
 
 	time.Sleep(time.Second * 2)
 
-	if err := s.waitAndClickButton(selenium.ByXPATH, submitCaptchaXPath); err != nil {
+	if err := s.waitAndClickButton(captcha.Submit); err != nil {
 		return fmt.Errorf("click submit captcha error:%w", err)
 	}
 	log.Println("submit captcha")
@@ -295,8 +468,9 @@ func (s *SeleniumService) SolveCaptcha(numbers []int) error {
 	return nil
 }
 
-func (s *SeleniumService) Authorize() error {
-	formContols, err := s.wd.FindElements(selenium.ByXPATH, formInputsXPath)
+// Authorize заполняет форму авторизации данными заявителя и отправляет ее
+func (s *SeleniumService) Authorize(email, password string) error {
+	formContols, err := s.pages.Login.Inputs.FindAll(s.wd)
 	if err != nil {
 		return err
 	}
@@ -310,16 +484,20 @@ func (s *SeleniumService) Authorize() error {
 		}
 	}
 
-	err = controls[0].SendKeys(s.blsEmail)
+	if len(controls) < 2 {
+		return apperr.New(apperr.ClassLayoutChanged, "authorization form inputs not found")
+	}
+
+	err = controls[0].SendKeys(email)
 	if err != nil {
 		return err
 	}
-	err = controls[1].SendKeys(s.blsPassword)
+	err = controls[1].SendKeys(password)
 	if err != nil {
 		return err
 	}
 
-	err = s.waitAndClickButton(selenium.ByID, formSubmitId)
+	err = s.waitAndClickButton(s.pages.Login.Submit)
 	if err != nil {
 		return fmt.Errorf("submit click error:%w", err)
 	}
@@ -329,7 +507,7 @@ func (s *SeleniumService) Authorize() error {
 
 // BookNew кликает по кнопке "Book new" на странице. Синхронный метод.
 func (s *SeleniumService) BookNew() error {
-	err := s.waitAndClickButton(selenium.ByXPATH, bookNewBtnXPath)
+	err := s.waitAndClickButton(s.pages.VisaType.BookNewLink)
 	if err != nil {
 		return fmt.Errorf("click book new btn error:%w", err)
 	}
@@ -337,58 +515,50 @@ func (s *SeleniumService) BookNew() error {
 	return nil
 }
 
-// BookNewAppointment заполняет форму "Book New Appointment" и отправляет ее
-func (s *SeleniumService) BookNewAppointment() error {
-	if err := s.waitAndClickButton(selenium.ByID, bookNewAppointmentId); err != nil {
+// BookNewAppointment заполняет форму "Book New Appointment" и отправляет ее.
+// Значения выпадающих списков выбираются по видимому тексту опций из prefs.
+// Если нужная опция не найдена, возвращается ошибка, форма не отправляется
+func (s *SeleniumService) BookNewAppointment(prefs cfg.VisaPreferences) error {
+	if err := s.waitAndClickButton(s.pages.VisaType.Submit); err != nil {
 		return fmt.Errorf("submit to book new appointment error: %w", err)
 	}
 
-	if _, err := s.waitAndFind(selenium.ByXPATH, bookNewFormXPath); err != nil {
+	if _, err := s.waitAndFind(s.pages.BookNew.Form); err != nil {
 		return fmt.Errorf("find 'book new' form error: %w", err)
 	}
 
 	// TODO: сделать ожидание появления элементов формы
 	time.Sleep(time.Second * 3)
 
-	formControlsDisplayed, err := s.getDisplayedFormControls()
-	if err != nil {
-		return fmt.Errorf("get displayed form control items error: %w", err)
-	}
+	values := visaFormValues(prefs)
 
-	if err := s.keyDownFor(tabsCountToFirstInput, selenium.TabKey); err != nil {
-		return fmt.Errorf("move to first input error: %w", err)
-	}
-
-	for _, el := range formControlsDisplayed {
+	for _, field := range visaFormFields {
 		time.Sleep(time.Millisecond * 300)
 
-		input, err := el.FindElement(selenium.ByTagName, "input")
-		if err != nil {
-			continue
-		}
+		text := values[field]
 
-		id, err := input.GetAttribute("id")
+		// Поля формы появляются в зависимости от уже выбранных значений,
+		// поэтому отображаемые элементы ищутся заново для каждого поля
+		inputId, err := s.findDisplayedFormInputId(field)
 		if err != nil {
-			return fmt.Errorf("get input id error: %w", err)
+			return fmt.Errorf("find form input '%s' error: %w", field, err)
 		}
-		// HARD CODED:
-		if strings.Contains(id, "ApplicantsNo") {
+		if inputId == "" {
+			if text != "" {
+				return fmt.Errorf("form input '%s' is not displayed, but value '%s' is configured", field, text)
+			}
 			continue
 		}
-
-		sanitizedId := util2.WithoutDigits(id)
-
-		keyDownCount := inputKeyDownCounts[sanitizedId]
-		if err := s.keyDownFor(keyDownCount, selenium.DownArrowKey); err != nil {
-			return fmt.Errorf("press arrow down error: %w", err)
+		if text == "" {
+			return fmt.Errorf("value for form input '%s' is not configured", field)
 		}
 
-		if err := s.wd.KeyDown(selenium.TabKey); err != nil {
-			return fmt.Errorf("press tabkey down error: %w", err)
+		if err := s.selectDropdownOption(inputId, text); err != nil {
+			return fmt.Errorf("select '%s' for '%s' error: %w", text, field, err)
 		}
 	}
 
-	if err := s.waitAndClickButton(selenium.ByID, bookNewAppointmentSubmitId); err != nil {
+	if err := s.waitAndClickButton(s.pages.BookNew.Submit); err != nil {
 		return fmt.Errorf("submit book new appointment form error: %w", err)
 	}
 
@@ -397,7 +567,7 @@ func (s *SeleniumService) BookNewAppointment() error {
 
 // CheckAvailability проверяет доступность регистрации на получение визы
 func (s *SeleniumService) CheckAvailability() (bool, error) {
-	commonModal, err := s.waitAndFind(selenium.ByID, commonModalId)
+	commonModal, err := s.waitAndFind(s.pages.Availability.Modal)
 	if err != nil {
 		return false, fmt.Errorf("find common modal error: %w", err)
 	}
@@ -407,9 +577,9 @@ func (s *SeleniumService) CheckAvailability() (bool, error) {
 		return false, fmt.Errorf("check common modal displayed error: %w", err)
 	}
 
-	header, err := commonModal.FindElement(selenium.ByID, commonModalHeaderId)
+	header, err := s.pages.Availability.Header.Find(commonModal)
 	if err != nil {
-		return false, fmt.Errorf("find common modal header error: %w", err)
+		return false, apperr.Wrap(apperr.ClassLayoutChanged, "find common modal header error", err)
 	}
 
 	text, err := header.Text()
@@ -424,12 +594,12 @@ func (s *SeleniumService) CheckAvailability() (bool, error) {
 
 // ClickVerifyBtn кликает по кнопке с ожиданием появления элемента. Синхронный метод.
 func (s *SeleniumService) ClickVerifyBtn() error {
-	return s.waitAndClickButton(selenium.ByCSSSelector, verifyBtnIdCSSSelector)
+	return s.waitAndClickButton(s.pages.Captcha.VerifyButton)
 }
 
-// clickButton кликает по элементу по заданным параметрам
-func (s *SeleniumService) clickButton(byWhat, value string) error {
-	elem, err := s.wd.FindElement(byWhat, value)
+// clickButton кликает по элементу страницы
+func (s *SeleniumService) clickButton(e pages.Element) error {
+	elem, err := e.Find(s.wd)
 	if err != nil {
 		return err
 	}
@@ -440,14 +610,14 @@ func (s *SeleniumService) clickButton(byWhat, value string) error {
 // TODO: refactor all wait funcs
 
 // waitAndFind ожидает появления элемента и возвращает его
-func (s *SeleniumService) waitAndFind(byWhat, value string) (selenium.WebElement, error) {
+func (s *SeleniumService) waitAndFind(e pages.Element) (selenium.WebElement, error) {
 	var element selenium.WebElement
 	var err error
 	maxTries := 10                  // HARD CODED
 	delay := time.Millisecond * 500 // HARD CODED
 
 	for i := 0; i < maxTries; i++ {
-		element, err = s.wd.FindElement(byWhat, value)
+		element, err = e.Find(s.wd)
 		if err == nil {
 			return element, nil
 		}
@@ -458,12 +628,12 @@ func (s *SeleniumService) waitAndFind(byWhat, value string) (selenium.WebElement
 }
 
 // waitAndClickButton ожидает появления элемента и кликает по нему
-func (s *SeleniumService) waitAndClickButton(byWhat, value string) error {
+func (s *SeleniumService) waitAndClickButton(e pages.Element) error {
 	var err error
 	maxTries := s.maxTries          // HARD CODED
 	delay := time.Millisecond * 500 // HARD CODED
 	for i := 0; i < maxTries; i++ {
-		err = s.clickButton(byWhat, value)
+		err = s.clickButton(e)
 		if err == nil {
 			return nil
 		}
@@ -473,7 +643,7 @@ func (s *SeleniumService) waitAndClickButton(byWhat, value string) error {
 }
 
 // waitAndSwitchIFrame ожидает появления IFrame'а и переключается на него
-func (s *SeleniumService) waitAndSwitchIFrame(byWhat, value string) (selenium.WebElement, error) {
+func (s *SeleniumService) waitAndSwitchIFrame(e pages.Element) (selenium.WebElement, error) {
 	var iframe selenium.WebElement
 	var err error
 	maxTries := 10 // HARD CODED
@@ -481,7 +651,7 @@ func (s *SeleniumService) waitAndSwitchIFrame(byWhat, value string) (selenium.We
 	delay := time.Second * 2
 
 	for i := 0; i < maxTries; i++ {
-		iframe, err = s.wd.FindElement(byWhat, value)
+		iframe, err = e.Find(s.wd)
 		if err == nil {
 			err = s.wd.SwitchFrame(iframe)
 			if err == nil {
@@ -508,33 +678,6 @@ func (s *SeleniumService) changeElementProperties(elem selenium.WebElement, prop
 	return err
 }
 
-func (s *SeleniumService) getElementSizes(elem selenium.WebElement) (int, int, error) {
-	var width, heigth int
-	var err error
-
-	widthProp, err := elem.CSSProperty("width")
-	if err != nil {
-		return 0, 0, err
-	}
-
-	width, err = util2.PxToInt(widthProp)
-	if err != nil {
-		return 0, 0, fmt.Errorf("convert px to int error:%w", err)
-	}
-
-	heightProp, err := elem.CSSProperty("height")
-	if err != nil {
-		return 0, 0, err
-	}
-
-	heigth, err = util2.PxToInt(heightProp)
-	if err != nil {
-		return 0, 0, fmt.Errorf("convert px to int error:%w", err)
-	}
-
-	return width, heigth, nil
-}
-
 func (s *SeleniumService) clickByCoords(x, y int) error {
 	script := `
     var event = new MouseEvent('click', {
@@ -550,26 +693,90 @@ func (s *SeleniumService) clickByCoords(x, y int) error {
 	return err
 }
 
-// keyDownFor нажимает key клавишу times раз
-func (s *SeleniumService) keyDownFor(times int, key string) error {
-	for i := 0; i < times; i++ {
-		if err := s.wd.KeyDown(key); err != nil {
-			return err
+// findDisplayedFormInputId возвращает id отображаемого input'а формы "Book New Appointment",
+// id которого без цифр совпадает с field. Если такой input не отображается, возвращается пустая строка
+func (s *SeleniumService) findDisplayedFormInputId(field string) (string, error) {
+	formControlsDisplayed, err := s.getDisplayedFormControls()
+	if err != nil {
+		return "", fmt.Errorf("get displayed form control items error: %w", err)
+	}
+
+	for _, el := range formControlsDisplayed {
+		input, err := el.FindElement(selenium.ByTagName, "input")
+		if err != nil {
+			continue
+		}
+
+		id, err := input.GetAttribute("id")
+		if err != nil {
+			return "", fmt.Errorf("get input id error: %w", err)
+		}
+
+		if util2.WithoutDigits(id) == field {
+			return id, nil
 		}
 	}
-	return nil
+
+	return "", nil
+}
+
+// selectDropdownOption открывает выпадающий список (kendo dropdown), привязанный к input'у с id inputId,
+// и кликает по опции, видимый текст которой совпадает с text без учета регистра
+func (s *SeleniumService) selectDropdownOption(inputId, text string) error {
+	options, texts, err := s.dropdownOptions(inputId)
+	if err != nil {
+		return err
+	}
+
+	for i, optionText := range texts {
+		if strings.EqualFold(optionText, strings.TrimSpace(text)) {
+			return options[i].Click()
+		}
+	}
+
+	// Опция из настроек могла быть переименована на сайте
+	return apperr.Wrap(apperr.ClassLayoutChanged, "option not found", fmt.Errorf("available options: %q", texts))
+}
+
+// dropdownOptions открывает выпадающий список (kendo dropdown), привязанный к input'у с id inputId,
+// и возвращает его опции и их видимый текст
+func (s *SeleniumService) dropdownOptions(inputId string) ([]selenium.WebElement, []string, error) {
+	if err := s.waitAndClickButton(s.pages.BookNew.DropdownOpener.With(inputId)); err != nil {
+		return nil, nil, fmt.Errorf("open dropdown error: %w", err)
+	}
+
+	optionsElement := s.pages.BookNew.DropdownOptions.With(inputId)
+	if _, err := s.waitAndFind(optionsElement); err != nil {
+		return nil, nil, fmt.Errorf("find dropdown options error: %w", err)
+	}
+
+	options, err := optionsElement.FindAll(s.wd)
+	if err != nil {
+		return nil, nil, fmt.Errorf("find dropdown options error: %w", err)
+	}
+
+	texts := make([]string, 0, len(options))
+	for _, option := range options {
+		optionText, err := option.Text()
+		if err != nil {
+			return nil, nil, fmt.Errorf("get option text error: %w", err)
+		}
+		texts = append(texts, strings.TrimSpace(optionText))
+	}
+
+	return options, texts, nil
 }
 
 // getDisplayedFormControls возвращает только отображаемые элементы формы.
 // Только для страницы Book New Appointment (форма для проверки доступности записи)
 func (s *SeleniumService) getDisplayedFormControls() ([]selenium.WebElement, error) {
-	formControls, err := s.wd.FindElements(selenium.ByXPATH, bookNewFormControlsXPath)
+	formControls, err := s.pages.BookNew.Controls.FindAll(s.wd)
 	if err != nil {
 		return nil, fmt.Errorf("find 'book new' form controls error: %w", err)
 	}
 
 	if len(formControls) < 2 {
-		return nil, errors.New("no form controls found")
+		return nil, apperr.New(apperr.ClassLayoutChanged, "no form controls found")
 	}
 
 	formControlsDisplayed := make([]selenium.WebElement, 0)
@@ -585,13 +792,3 @@ func (s *SeleniumService) getDisplayedFormControls() ([]selenium.WebElement, err
 
 	return formControlsDisplayed, nil
 }
-
-func getCardCoordinates(cardNum, cardWidth, cardHeight int) (int, int) {
-	row := (cardNum - 1) / 3
-	col := (cardNum - 1) % 3
-
-	x := col*cardWidth + cardWidth/2
-	y := row*cardHeight + cardHeight/2
-
-	return x, y
-}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"errors"
+	"github.com/tebeka/selenium"
+	"testing"
+	"visasolution/internal/apperr"
+)
+
+func TestClassifyNavigationError(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		wantClass string
+		// wantRotate основной цикл сменит прокси после такой ошибки
+		wantRotate bool
+	}{
+		{
+			name:      "invalid session",
+			err:       &selenium.Error{Err: "invalid session id", LegacyCode: int(invalidSessionId)},
+			wantClass: apperr.ClassSessionLost,
+		},
+		{
+			name:       "proxy auth",
+			err:        errors.New("unknown error: net::ERR_PROXY_AUTH_UNSUPPORTED"),
+			wantClass:  apperr.ClassProxyAuthFailed,
+			wantRotate: true,
+		},
+		{
+			name:       "tunnel failed",
+			err:        errors.New("unknown error: net::ERR_TUNNEL_CONNECTION_FAILED"),
+			wantClass:  apperr.ClassProxyAuthFailed,
+			wantRotate: true,
+		},
+		{
+			name:       "slow proxy is not a ban",
+			err:        errors.New("unknown error: net::ERR_TIMED_OUT"),
+			wantClass:  apperr.ClassProxyUnreachable,
+			wantRotate: true,
+		},
+		{
+			name:       "connection timed out",
+			err:        errors.New("unknown error: net::ERR_CONNECTION_TIMED_OUT"),
+			wantClass:  apperr.ClassProxyUnreachable,
+			wantRotate: true,
+		},
+		{
+			name:       "connection reset is not a ban",
+			err:        errors.New("unknown error: net::ERR_CONNECTION_RESET"),
+			wantClass:  apperr.ClassProxyUnreachable,
+			wantRotate: true,
+		},
+		{
+			name:       "empty response is not a ban",
+			err:        errors.New("unknown error: net::ERR_EMPTY_RESPONSE"),
+			wantClass:  apperr.ClassProxyUnreachable,
+			wantRotate: true,
+		},
+		{
+			name:      "renderer timeout is not a proxy error",
+			err:       errors.New("timeout: Timed out receiving message from renderer: 300.000"),
+			wantClass: apperr.ClassUnknown,
+		},
+		{
+			name:      "webdriver timeout is not a proxy error",
+			err:       &selenium.Error{Err: "timeout", Message: "script timeout"},
+			wantClass: apperr.ClassUnknown,
+		},
+		{
+			name:      "other error",
+			err:       errors.New("unknown error: net::ERR_NAME_NOT_RESOLVED"),
+			wantClass: apperr.ClassUnknown,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := classifyNavigationError(tt.err)
+			class, policy := apperr.Classify(err)
+			if class != tt.wantClass {
+				t.Errorf("class of %v = %s, want %s", err, class, tt.wantClass)
+			}
+			if rotate := policy.Recovery == apperr.RecoveryRotateProxy; rotate != tt.wantRotate {
+				t.Errorf("proxy rotation = %v, want %v", rotate, tt.wantRotate)
+			}
+		})
+	}
+}
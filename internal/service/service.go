@@ -3,7 +3,10 @@ package service
 import (
 	"github.com/sashabaranov/go-openai"
 	"github.com/tebeka/selenium"
+	"time"
 	cfg "visasolution/internal/config"
+	"visasolution/internal/pages"
+	"visasolution/internal/replay"
 )
 
 type ProxyConnecter interface {
@@ -20,6 +23,8 @@ type Selenium interface {
 	AuthCookie() (selenium.Cookie, error)
 	Cookies() ([]selenium.Cookie, error)
 	SetCookies(cookies []selenium.Cookie) error
+	LocalStorage() (map[string]string, error)
+	SetLocalStorage(storage map[string]string) error
 	DeleteCookie(key string) error
 	DeleteAllCookies() error
 	MaximizeWindow() error
@@ -34,13 +39,23 @@ type Selenium interface {
 	ClickVerifyBtn() error
 
 	PullPageScreenshot() ([]byte, error)
-	PullCaptchaImage() ([]byte, error)
+	PullCaptchaImage() (CaptchaImage, error)
 	SolveCaptcha(numbers []int) error
-	Authorize() error
+	Authorize(email, password string) error
 	BookNew() error
-	BookNewAppointment() error
+	BookNewAppointment(prefs cfg.VisaPreferences) error
 	CheckAvailability() (bool, error)
 
+	AvailableDates() ([]time.Time, error)
+	SelectDate(date time.Time) error
+	AvailableSlots() ([]string, error)
+	SelectSlot(slot string) error
+	SubmitSlot() error
+	IsCaptchaPresent() (bool, error)
+	FillApplicantDetails(applicantName string) error
+	ConfirmBooking() error
+	BookingReference() (string, error)
+
 	Quit() error
 }
 
@@ -49,6 +64,7 @@ type Chat interface {
 	GetRespMsg(resp openai.ChatCompletionResponse) string
 	Request3DOT5Turbo(content string) (openai.ChatCompletionResponse, error)
 	Request4VPreviewWithImage(content, imageUrl string) (openai.ChatCompletionResponse, error)
+	Request4VPreviewWithImageBytes(content string, image []byte) (openai.ChatCompletionResponse, error)
 	Proxier
 }
 
@@ -57,8 +73,23 @@ type Image interface {
 	UploadImage(imagePath string) (string, error)
 }
 
+// CaptchaSolver решает капчу "Select all squares with the number ..."
+type CaptchaSolver interface {
+	// Solve принимает путь к изображению капчи и расположение карточек на нем
+	// и возвращает номера карточек (с 1 по 9), которые нужно выбрать
+	Solve(imagePath string, layout CaptchaLayout) ([]int, error)
+}
+
 type Email interface {
-	SendAvailbilityNotification(to string) error
+	Notifier
+	SendAvailbilityNotification(to, screenshotPath string) error
+}
+
+type Telegram interface {
+	Proxier
+	Notifier
+	// SetChatIDs заменяет список получателей уведомлений
+	SetChatIDs(chatIDs []int64)
 }
 
 type Service struct {
@@ -66,6 +97,12 @@ type Service struct {
 	Chat
 	Image
 	Email
+	CaptchaSolver
+
+	// Telegram nil, если токен бота не задан
+	Telegram Telegram
+	// Notifier рассылает уведомления через все настроенные каналы (email, telegram)
+	Notifier Notifier
 }
 
 type Deps struct {
@@ -74,22 +111,61 @@ type Deps struct {
 
 	MaxTries int
 
-	BlsEmail    string
-	BlsPassword string
+	// Pages объекты страниц сайта с селекторами из набора. Если nil, используется встроенный набор
+	Pages *pages.Pages
+	// Recorder записывает страницы сайта после действий веб-драйвера. Если nil, запись отключена
+	Recorder *replay.Recorder
 
 	ChatApiKey string
 
+	// CaptchaSolver тип решателя капчи: cfg.CaptchaSolverGPT или cfg.CaptchaSolverOCR
+	CaptchaSolver string
+	// ImgurFallback включает загрузку капчи на Imgur, если не удалось отправить изображение в chat api напрямую
+	ImgurFallback bool
+
 	ImgurClientId     string
 	ImgurClientSecret string
 
 	EmailDeps
+	// TelegramDeps уведомления в Telegram отключены, если токен не задан
+	TelegramDeps TelegramDeps
 }
 
 func NewService(deps Deps) *Service {
+	chat := NewChatService(deps.ChatApiKey)
+	image := NewImageService(deps.ImgurClientId, deps.ImgurClientSecret)
+
+	var captchaSolver CaptchaSolver
+	switch deps.CaptchaSolver {
+	case cfg.CaptchaSolverOCR:
+		captchaSolver = NewOCRCaptchaSolver()
+	default:
+		var fallback Image
+		if deps.ImgurFallback {
+			fallback = image
+		}
+		captchaSolver = NewChatCaptchaSolver(chat, fallback)
+	}
+
+	email := NewEmailService(deps.EmailDeps)
+	notifiers := []Notifier{email}
+
+	var telegram Telegram
+	if deps.TelegramDeps.Token != "" {
+		telegram = NewTelegramService(deps.TelegramDeps)
+		notifiers = append(notifiers, telegram)
+	}
+
+	seleniumService := NewSeleniumService(deps.MaxTries, deps.SeleniumURL, deps.Pages)
+	seleniumService.SetRecorder(deps.Recorder)
+
 	return &Service{
-		Selenium: NewSeleniumService(deps.MaxTries, deps.BlsEmail, deps.SeleniumURL, deps.BlsPassword),
-		Chat:     NewChatService(deps.ChatApiKey),
-		Image:    NewImageService(deps.ImgurClientId, deps.ImgurClientSecret),
-		Email:    NewEmailService(deps.EmailDeps),
+		Selenium:      seleniumService,
+		Chat:          chat,
+		Image:         image,
+		Email:         email,
+		CaptchaSolver: captchaSolver,
+		Telegram:      telegram,
+		Notifier:      NewMultiNotifier(notifiers...),
 	}
 }
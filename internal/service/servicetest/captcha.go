@@ -0,0 +1,25 @@
+package servicetest
+
+import (
+	"visasolution/internal/service"
+)
+
+var _ service.CaptchaSolver = (*CaptchaSolver)(nil)
+
+// CaptchaSolver фейк service.CaptchaSolver. Ответы метода Solve - номера карточек ([]int)
+type CaptchaSolver struct {
+	*Script
+}
+
+// NewCaptchaSolver создает фейк service.CaptchaSolver, который по умолчанию выбирает карточки cards
+func NewCaptchaSolver(cards ...int) *CaptchaSolver {
+	f := &CaptchaSolver{}
+	f.Script = newScript(f)
+	f.SetDefault("Solve", Return(cards))
+	return f
+}
+
+func (f *CaptchaSolver) Solve(imagePath string, layout service.CaptchaLayout) ([]int, error) {
+	r := f.call("Solve", imagePath, layout)
+	return valueOf[[]int](r), r.Err
+}
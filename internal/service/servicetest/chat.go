@@ -0,0 +1,62 @@
+package servicetest
+
+import (
+	"github.com/sashabaranov/go-openai"
+	cfg "visasolution/internal/config"
+	"visasolution/internal/service"
+)
+
+var _ service.Chat = (*Chat)(nil)
+
+// Chat фейк service.Chat. Ответы задаются по имени метода интерфейса (см. Script)
+type Chat struct {
+	*Script
+}
+
+// NewChat создает фейк service.Chat, все методы которого по умолчанию выполняются успешно
+func NewChat() *Chat {
+	f := &Chat{}
+	f.Script = newScript(f)
+	return f
+}
+
+// ChatResponse ответ chat api с одним сообщением text
+func ChatResponse(text string) openai.ChatCompletionResponse {
+	return openai.ChatCompletionResponse{
+		Choices: []openai.ChatCompletionChoice{
+			{Message: openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: text}},
+		},
+	}
+}
+
+func (f *Chat) TestConnection() error {
+	return f.call("TestConnection").Err
+}
+
+// GetRespMsg возвращает ответ из очереди, если он задан, иначе текст первого сообщения resp
+func (f *Chat) GetRespMsg(resp openai.ChatCompletionResponse) string {
+	r := f.call("GetRespMsg", resp)
+	if r.Value == nil && len(resp.Choices) > 0 {
+		return resp.Choices[0].Message.Content
+	}
+	return valueOf[string](r)
+}
+
+func (f *Chat) Request3DOT5Turbo(content string) (openai.ChatCompletionResponse, error) {
+	r := f.call("Request3DOT5Turbo", content)
+	return valueOf[openai.ChatCompletionResponse](r), r.Err
+}
+
+func (f *Chat) Request4VPreviewWithImage(content, imageUrl string) (openai.ChatCompletionResponse, error) {
+	r := f.call("Request4VPreviewWithImage", content, imageUrl)
+	return valueOf[openai.ChatCompletionResponse](r), r.Err
+}
+
+func (f *Chat) Request4VPreviewWithImageBytes(content string, image []byte) (openai.ChatCompletionResponse, error) {
+	r := f.call("Request4VPreviewWithImageBytes", content, image)
+	return valueOf[openai.ChatCompletionResponse](r), r.Err
+}
+
+func (f *Chat) ClientInitWithProxy(proxy cfg.Proxy) error {
+	return f.call("ClientInitWithProxy", proxy).Err
+}
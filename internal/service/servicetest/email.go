@@ -0,0 +1,38 @@
+package servicetest
+
+import (
+	"visasolution/internal/service"
+)
+
+var _ service.Email = (*Email)(nil)
+
+// Email фейк service.Email. Подходит и как service.Notifier.
+// Ответы задаются по имени метода интерфейса (см. Script)
+type Email struct {
+	*Script
+}
+
+// NewEmail создает фейк service.Email, все методы которого по умолчанию выполняются успешно
+func NewEmail() *Email {
+	f := &Email{}
+	f.Script = newScript(f)
+	return f
+}
+
+func (f *Email) Notify(n service.Notification) error {
+	return f.call("Notify", n).Err
+}
+
+func (f *Email) SendAvailbilityNotification(to, screenshotPath string) error {
+	return f.call("SendAvailbilityNotification", to, screenshotPath).Err
+}
+
+// Notifications возвращает отправленные уведомления в порядке отправки
+func (f *Email) Notifications() []service.Notification {
+	calls := f.Calls("Notify")
+	notifications := make([]service.Notification, 0, len(calls))
+	for _, c := range calls {
+		notifications = append(notifications, c.Args[0].(service.Notification))
+	}
+	return notifications
+}
@@ -0,0 +1,29 @@
+package servicetest
+
+import (
+	cfg "visasolution/internal/config"
+	"visasolution/internal/service"
+)
+
+var _ service.Image = (*Image)(nil)
+
+// Image фейк service.Image. Ответы задаются по имени метода интерфейса (см. Script)
+type Image struct {
+	*Script
+}
+
+// NewImage создает фейк service.Image, все методы которого по умолчанию выполняются успешно
+func NewImage() *Image {
+	f := &Image{}
+	f.Script = newScript(f)
+	return f
+}
+
+func (f *Image) ClientInitWithProxy(proxy cfg.Proxy) error {
+	return f.call("ClientInitWithProxy", proxy).Err
+}
+
+func (f *Image) UploadImage(imagePath string) (string, error) {
+	r := f.call("UploadImage", imagePath)
+	return valueOf[string](r), r.Err
+}
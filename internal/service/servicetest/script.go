@@ -0,0 +1,178 @@
+// Package servicetest содержит фейковые реализации интерфейсов service для тестов:
+// ответы методов задаются очередями, вызовы записываются в журнал.
+//
+// Пример:
+//
+//	sel := servicetest.NewSelenium()
+//	sel.QueueErr("GoTo", nil, service.InvalidSessionError)
+//	sel.SetDefault("IsAuthorized", servicetest.Return(true))
+//	...
+//	if sel.CallCount("GoTo") != 2 { ... }
+package servicetest
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// Call вызов метода фейка
+type Call struct {
+	Method string
+	Args   []any
+}
+
+// Response ответ метода фейка. Value используется методами, которые возвращают значение помимо ошибки,
+// тип Value должен совпадать с типом результата метода
+type Response struct {
+	Value any
+	Err   error
+}
+
+// Return ответ со значением без ошибки
+func Return(value any) Response {
+	return Response{Value: value}
+}
+
+// Fail ответ с ошибкой
+func Fail(err error) Response {
+	return Response{Err: err}
+}
+
+// Script очереди ответов и журнал вызовов методов фейка.
+// Ответ берется из очереди метода, а если она пуста - ответ по умолчанию (нулевое значение без ошибки, если не задан)
+type Script struct {
+	// methods методы фейка, для которых можно задать ответы. Защищает от опечаток в именах методов
+	methods map[string]bool
+
+	mu       sync.Mutex
+	queues   map[string][]Response
+	defaults map[string]Response
+	calls    []Call
+}
+
+// newScript создает Script для методов типа fake (указатель на структуру фейка)
+func newScript(fake any) *Script {
+	t := reflect.TypeOf(fake)
+	methods := make(map[string]bool, t.NumMethod())
+	for i := 0; i < t.NumMethod(); i++ {
+		methods[t.Method(i).Name] = true
+	}
+
+	return &Script{
+		methods:  methods,
+		queues:   make(map[string][]Response),
+		defaults: make(map[string]Response),
+	}
+}
+
+// Queue добавляет ответы метода в очередь. Каждый вызов метода забирает из очереди один ответ
+func (s *Script) Queue(method string, responses ...Response) {
+	s.checkMethod(method)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.queues[method] = append(s.queues[method], responses...)
+}
+
+// QueueErr добавляет в очередь метода ответы с ошибками. nil - успешный ответ
+func (s *Script) QueueErr(method string, errs ...error) {
+	responses := make([]Response, 0, len(errs))
+	for _, err := range errs {
+		responses = append(responses, Fail(err))
+	}
+	s.Queue(method, responses...)
+}
+
+// SetDefault задает ответ метода, который возвращается, когда очередь метода пуста
+func (s *Script) SetDefault(method string, response Response) {
+	s.checkMethod(method)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.defaults[method] = response
+}
+
+// Calls возвращает вызовы методов methods в порядке выполнения. Без аргументов - все вызовы
+func (s *Script) Calls(methods ...string) []Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var calls []Call
+	for _, c := range s.calls {
+		if len(methods) == 0 || contains(methods, c.Method) {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+// CallCount возвращает количество вызовов метода
+func (s *Script) CallCount(method string) int {
+	return len(s.Calls(method))
+}
+
+// Methods возвращает имена вызванных методов в порядке вызова
+func (s *Script) Methods() []string {
+	calls := s.Calls()
+	methods := make([]string, 0, len(calls))
+	for _, c := range calls {
+		methods = append(methods, c.Method)
+	}
+	return methods
+}
+
+// Reset очищает очереди, ответы по умолчанию и журнал вызовов
+func (s *Script) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.queues = make(map[string][]Response)
+	s.defaults = make(map[string]Response)
+	s.calls = nil
+}
+
+// call записывает вызов метода и возвращает его ответ
+func (s *Script) call(method string, args ...any) Response {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.calls = append(s.calls, Call{Method: method, Args: args})
+
+	if queue := s.queues[method]; len(queue) > 0 {
+		s.queues[method] = queue[1:]
+		return queue[0]
+	}
+	return s.defaults[method]
+}
+
+func (s *Script) checkMethod(method string) {
+	if !s.methods[method] {
+		panic(fmt.Sprintf("servicetest: unknown method '%s'", method))
+	}
+}
+
+// valueOf возвращает значение ответа с типом результата метода
+func valueOf[T any](r Response) T {
+	if r.Value == nil {
+		var zero T
+		return zero
+	}
+
+	v, ok := r.Value.(T)
+	if !ok {
+		panic(fmt.Sprintf("servicetest: response value has type %T, expected %T", r.Value, v))
+	}
+	return v
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
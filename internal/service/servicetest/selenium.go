@@ -0,0 +1,157 @@
+package servicetest
+
+import (
+	"github.com/tebeka/selenium"
+	"time"
+	cfg "visasolution/internal/config"
+	"visasolution/internal/service"
+)
+
+var _ service.Selenium = (*Selenium)(nil)
+
+// Selenium фейк service.Selenium. Ответы задаются по имени метода интерфейса (см. Script)
+type Selenium struct {
+	*Script
+}
+
+// NewSelenium создает фейк service.Selenium, все методы которого по умолчанию выполняются успешно
+func NewSelenium() *Selenium {
+	f := &Selenium{}
+	f.Script = newScript(f)
+	return f
+}
+
+func (f *Selenium) ConnectWithProxy(extensionPath string) error {
+	return f.call("ConnectWithProxy", extensionPath).Err
+}
+
+func (f *Selenium) AuthCookie() (selenium.Cookie, error) {
+	r := f.call("AuthCookie")
+	return valueOf[selenium.Cookie](r), r.Err
+}
+
+func (f *Selenium) Cookies() ([]selenium.Cookie, error) {
+	r := f.call("Cookies")
+	return valueOf[[]selenium.Cookie](r), r.Err
+}
+
+func (f *Selenium) SetCookies(cookies []selenium.Cookie) error {
+	return f.call("SetCookies", cookies).Err
+}
+
+func (f *Selenium) LocalStorage() (map[string]string, error) {
+	r := f.call("LocalStorage")
+	return valueOf[map[string]string](r), r.Err
+}
+
+func (f *Selenium) SetLocalStorage(storage map[string]string) error {
+	return f.call("SetLocalStorage", storage).Err
+}
+
+func (f *Selenium) DeleteCookie(key string) error {
+	return f.call("DeleteCookie", key).Err
+}
+
+func (f *Selenium) DeleteAllCookies() error {
+	return f.call("DeleteAllCookies").Err
+}
+
+func (f *Selenium) MaximizeWindow() error {
+	return f.call("MaximizeWindow").Err
+}
+
+func (f *Selenium) GoTo(url string) error {
+	return f.call("GoTo", url).Err
+}
+
+func (f *Selenium) Refresh() error {
+	return f.call("Refresh").Err
+}
+
+func (f *Selenium) TestPage() error {
+	return f.call("TestPage").Err
+}
+
+func (f *Selenium) IsAuthorized(neededURLPath string) (bool, error) {
+	r := f.call("IsAuthorized", neededURLPath)
+	return valueOf[bool](r), r.Err
+}
+
+func (f *Selenium) ClickVerifyBtn() error {
+	return f.call("ClickVerifyBtn").Err
+}
+
+func (f *Selenium) PullPageScreenshot() ([]byte, error) {
+	r := f.call("PullPageScreenshot")
+	return valueOf[[]byte](r), r.Err
+}
+
+func (f *Selenium) PullCaptchaImage() (service.CaptchaImage, error) {
+	r := f.call("PullCaptchaImage")
+	return valueOf[service.CaptchaImage](r), r.Err
+}
+
+func (f *Selenium) SolveCaptcha(numbers []int) error {
+	return f.call("SolveCaptcha", numbers).Err
+}
+
+func (f *Selenium) Authorize(email, password string) error {
+	return f.call("Authorize", email, password).Err
+}
+
+func (f *Selenium) BookNew() error {
+	return f.call("BookNew").Err
+}
+
+func (f *Selenium) BookNewAppointment(prefs cfg.VisaPreferences) error {
+	return f.call("BookNewAppointment", prefs).Err
+}
+
+func (f *Selenium) CheckAvailability() (bool, error) {
+	r := f.call("CheckAvailability")
+	return valueOf[bool](r), r.Err
+}
+
+func (f *Selenium) AvailableDates() ([]time.Time, error) {
+	r := f.call("AvailableDates")
+	return valueOf[[]time.Time](r), r.Err
+}
+
+func (f *Selenium) SelectDate(date time.Time) error {
+	return f.call("SelectDate", date).Err
+}
+
+func (f *Selenium) AvailableSlots() ([]string, error) {
+	r := f.call("AvailableSlots")
+	return valueOf[[]string](r), r.Err
+}
+
+func (f *Selenium) SelectSlot(slot string) error {
+	return f.call("SelectSlot", slot).Err
+}
+
+func (f *Selenium) SubmitSlot() error {
+	return f.call("SubmitSlot").Err
+}
+
+func (f *Selenium) IsCaptchaPresent() (bool, error) {
+	r := f.call("IsCaptchaPresent")
+	return valueOf[bool](r), r.Err
+}
+
+func (f *Selenium) FillApplicantDetails(applicantName string) error {
+	return f.call("FillApplicantDetails", applicantName).Err
+}
+
+func (f *Selenium) ConfirmBooking() error {
+	return f.call("ConfirmBooking").Err
+}
+
+func (f *Selenium) BookingReference() (string, error) {
+	r := f.call("BookingReference")
+	return valueOf[string](r), r.Err
+}
+
+func (f *Selenium) Quit() error {
+	return f.call("Quit").Err
+}
@@ -0,0 +1,34 @@
+package servicetest
+
+import (
+	"visasolution/internal/service"
+)
+
+// Fakes фейки всех сервисов, из которых собран Service
+type Fakes struct {
+	Selenium      *Selenium
+	Chat          *Chat
+	Image         *Image
+	Email         *Email
+	CaptchaSolver *CaptchaSolver
+}
+
+// NewService создает service.Service из фейков. Уведомления рассылаются через фейк Email, Telegram отключен
+func NewService() (*service.Service, *Fakes) {
+	fakes := &Fakes{
+		Selenium:      NewSelenium(),
+		Chat:          NewChat(),
+		Image:         NewImage(),
+		Email:         NewEmail(),
+		CaptchaSolver: NewCaptchaSolver(1, 5, 9),
+	}
+
+	return &service.Service{
+		Selenium:      fakes.Selenium,
+		Chat:          fakes.Chat,
+		Image:         fakes.Image,
+		Email:         fakes.Email,
+		CaptchaSolver: fakes.CaptchaSolver,
+		Notifier:      fakes.Email,
+	}, fakes
+}
@@ -0,0 +1,240 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	cfg "visasolution/internal/config"
+	pkgService "visasolution/pkg/service"
+)
+
+const (
+	DefaultTelegramBaseURL = "https://api.telegram.org"
+	telegramTimeout        = 30 * time.Second
+)
+
+// Ограничения Bot API на длину текста (в символах UTF-16)
+const (
+	telegramCaptionLimit = 1024
+	telegramMessageLimit = 4096
+)
+
+type TelegramDeps struct {
+	Token string
+	// BaseURL адрес Telegram Bot API. По умолчанию DefaultTelegramBaseURL
+	BaseURL string
+	ChatIDs []int64
+}
+
+type telegramResponse struct {
+	Ok          bool   `json:"ok"`
+	Description string `json:"description"`
+}
+
+// TelegramService отправляет уведомления через Telegram Bot API
+type TelegramService struct {
+	d      TelegramDeps
+	client *http.Client
+}
+
+func NewTelegramService(d TelegramDeps) *TelegramService {
+	if d.BaseURL == "" {
+		d.BaseURL = DefaultTelegramBaseURL
+	}
+	return &TelegramService{
+		d:      d,
+		client: &http.Client{Timeout: telegramTimeout},
+	}
+}
+
+// SetChatIDs заменяет список чатов, в которые отправляются уведомления
+func (t *TelegramService) SetChatIDs(chatIDs []int64) {
+	t.d.ChatIDs = chatIDs
+}
+
+// ClientInitWithProxy инициализация клиента с прокси.
+// параметр proxy может быть пустой структурой, тогда клиент будет инициализирован без прокси.
+func (t *TelegramService) ClientInitWithProxy(proxy cfg.Proxy) error {
+	if proxy.IsEmpty() {
+		return nil
+	}
+
+	transport, err := pkgService.ProxyTransport(proxy.URL())
+	if err != nil {
+		return fmt.Errorf("failed to create proxy transport: %w", err)
+	}
+	t.client = &http.Client{Transport: transport, Timeout: telegramTimeout}
+
+	return nil
+}
+
+// Notify отправляет уведомление во все чаты. Если есть скриншот, он отправляется как фото с подписью.
+// Подпись к фото ограничена 1024 символами, поэтому длинный текст (например, со списком слотов) отправляется
+// после фото отдельными сообщениями, а подписью становится его первая строка.
+// Ошибка отправки в один чат не мешает отправке в остальные, ошибки всех чатов возвращаются вместе
+func (t *TelegramService) Notify(n Notification) error {
+	var screenshot []byte
+	if n.ScreenshotPath != "" {
+		// Если скриншот не удалось прочитать, отправляется только текст
+		screenshot, _ = os.ReadFile(n.ScreenshotPath)
+	}
+
+	var errs []error
+	for _, chatID := range t.d.ChatIDs {
+		if err := t.notifyChat(chatID, n.Text, n.ScreenshotPath, screenshot); err != nil {
+			errs = append(errs, fmt.Errorf("telegram notify chat %d error: %w", chatID, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (t *TelegramService) notifyChat(chatID int64, text, screenshotPath string, screenshot []byte) error {
+	if len(screenshot) > 0 {
+		caption := text
+		if utf16Len(text) > telegramCaptionLimit {
+			caption = truncateUTF16(firstLine(text), telegramCaptionLimit)
+		} else {
+			text = ""
+		}
+
+		if err := t.sendPhoto(chatID, caption, filepath.Base(screenshotPath), screenshot); err != nil {
+			return err
+		}
+	}
+
+	for _, chunk := range splitText(text, telegramMessageLimit) {
+		if err := t.sendMessage(chatID, chunk); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (t *TelegramService) sendMessage(chatID int64, text string) error {
+	body, err := json.Marshal(map[string]any{
+		"chat_id": chatID,
+		"text":    text,
+	})
+	if err != nil {
+		return err
+	}
+
+	return t.do("sendMessage", "application/json", bytes.NewReader(body))
+}
+
+func (t *TelegramService) sendPhoto(chatID int64, caption, filename string, photo []byte) error {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	if err := writer.WriteField("chat_id", strconv.FormatInt(chatID, 10)); err != nil {
+		return err
+	}
+	if err := writer.WriteField("caption", caption); err != nil {
+		return err
+	}
+
+	part, err := writer.CreateFormFile("photo", filename)
+	if err != nil {
+		return fmt.Errorf("create form file error: %v", err)
+	}
+	if _, err := part.Write(photo); err != nil {
+		return fmt.Errorf("write photo error: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	return t.do("sendPhoto", writer.FormDataContentType(), &body)
+}
+
+// do выполняет запрос к методу Bot API и проверяет ответ
+func (t *TelegramService) do(method, contentType string, body io.Reader) error {
+	endpoint := fmt.Sprintf("%s/bot%s/%s", t.d.BaseURL, t.d.Token, method)
+
+	resp, err := t.client.Post(endpoint, contentType, body)
+	if err != nil {
+		// url.Error содержит адрес запроса вместе с токеном, поэтому в ошибку попадает только причина
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("client do error: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var tgResp telegramResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tgResp); err != nil {
+		return fmt.Errorf("decode response error: %v (status code %d)", err, resp.StatusCode)
+	}
+
+	if !tgResp.Ok {
+		return fmt.Errorf("telegram api error: %s (status code %d)", tgResp.Description, resp.StatusCode)
+	}
+
+	return nil
+}
+
+// splitText делит текст на части не длиннее limit символов UTF-16, по возможности по переводам строк
+func splitText(text string, limit int) []string {
+	var chunks []string
+	for utf16Len(text) > limit {
+		chunk := truncateUTF16(text, limit)
+		if i := strings.LastIndex(chunk, "\n"); i > 0 {
+			chunk = chunk[:i+1]
+		}
+		chunks = append(chunks, chunk)
+		text = text[len(chunk):]
+	}
+	if text != "" {
+		chunks = append(chunks, text)
+	}
+	return chunks
+}
+
+// truncateUTF16 возвращает начало строки не длиннее limit символов UTF-16, не разрезая символы
+func truncateUTF16(text string, limit int) string {
+	n := 0
+	for i, r := range text {
+		n += utf16RuneLen(r)
+		if n > limit {
+			return text[:i]
+		}
+	}
+	return text
+}
+
+// utf16Len возвращает длину строки в символах UTF-16: так длину текста считает Bot API
+func utf16Len(text string) int {
+	n := 0
+	for _, r := range text {
+		n += utf16RuneLen(r)
+	}
+	return n
+}
+
+func firstLine(text string) string {
+	if i := strings.IndexByte(text, '\n'); i >= 0 {
+		return text[:i]
+	}
+	return text
+}
+
+// utf16RuneLen возвращает число символов UTF-16 для руны: символы вне базовой плоскости (эмодзи) занимают два
+func utf16RuneLen(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
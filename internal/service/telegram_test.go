@@ -0,0 +1,251 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// telegramCall запрос к фейковому Bot API
+type telegramCall struct {
+	method string
+	chatID string
+	text   string
+	photo  bool
+}
+
+// blockedChatID чат, который заблокировал бота: фейковый Bot API отклоняет отправку в него
+const blockedChatID = "13"
+
+// newTelegramAPI поднимает фейковый Bot API, который как настоящий отклоняет слишком длинные подпись и текст
+func newTelegramAPI(t *testing.T) (*httptest.Server, func() []telegramCall) {
+	t.Helper()
+
+	var (
+		mu    sync.Mutex
+		calls []telegramCall
+	)
+	reply := func(w http.ResponseWriter, status int, description string) {
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(map[string]any{"ok": status == http.StatusOK, "description": description})
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var call telegramCall
+		switch r.URL.Path {
+		case "/bottoken/sendPhoto":
+			if err := r.ParseMultipartForm(1 << 20); err != nil {
+				reply(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			_, _, err := r.FormFile("photo")
+			call = telegramCall{method: "sendPhoto", chatID: r.FormValue("chat_id"), text: r.FormValue("caption"), photo: err == nil}
+			if utf16Len(call.text) > telegramCaptionLimit {
+				reply(w, http.StatusBadRequest, "Bad Request: message caption is too long")
+				return
+			}
+		case "/bottoken/sendMessage":
+			var body struct {
+				ChatID json.Number `json:"chat_id"`
+				Text   string      `json:"text"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				reply(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			call = telegramCall{method: "sendMessage", chatID: body.ChatID.String(), text: body.Text}
+			if utf16Len(call.text) > telegramMessageLimit {
+				reply(w, http.StatusBadRequest, "Bad Request: message is too long")
+				return
+			}
+		default:
+			reply(w, http.StatusNotFound, "Not Found")
+			return
+		}
+		if call.chatID == blockedChatID {
+			reply(w, http.StatusForbidden, "Forbidden: bot was blocked by the user")
+			return
+		}
+
+		mu.Lock()
+		calls = append(calls, call)
+		mu.Unlock()
+		reply(w, http.StatusOK, "")
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv, func() []telegramCall {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]telegramCall(nil), calls...)
+	}
+}
+
+func TestTelegramNotify(t *testing.T) {
+	screenshotPath := filepath.Join(t.TempDir(), "screenshot.png")
+	if err := os.WriteFile(screenshotPath, []byte("png"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Длинный список слотов, как в уведомлении о появлении записи
+	var slots strings.Builder
+	slots.WriteString("Появилась запись для профиля 'main'\n")
+	for i := 0; i < 300; i++ {
+		slots.WriteString("2024-05-14: 09:00, 09:30, 10:00 📅\n")
+	}
+	longText := slots.String()
+
+	tests := []struct {
+		name       string
+		text       string
+		screenshot string
+		want       func(t *testing.T, calls []telegramCall)
+	}{
+		{
+			name:       "short text as photo caption",
+			text:       "Появилась запись",
+			screenshot: screenshotPath,
+			want: func(t *testing.T, calls []telegramCall) {
+				if len(calls) != 1 || calls[0].method != "sendPhoto" || !calls[0].photo || calls[0].text != "Появилась запись" {
+					t.Errorf("calls = %+v, want one sendPhoto with the text as caption", calls)
+				}
+			},
+		},
+		{
+			name:       "long text after photo",
+			text:       longText,
+			screenshot: screenshotPath,
+			want: func(t *testing.T, calls []telegramCall) {
+				if len(calls) < 3 || calls[0].method != "sendPhoto" || !calls[0].photo {
+					t.Fatalf("calls = %d, want sendPhoto followed by several sendMessage", len(calls))
+				}
+				if calls[0].text != "Появилась запись для профиля 'main'" {
+					t.Errorf("caption = %q, want the first line of the text", calls[0].text)
+				}
+
+				var text strings.Builder
+				for _, call := range calls[1:] {
+					if call.method != "sendMessage" {
+						t.Fatalf("method = %s, want sendMessage", call.method)
+					}
+					if !strings.HasSuffix(call.text, "\n") {
+						t.Errorf("message chunk is not split on a line break: %q", call.text[len(call.text)-20:])
+					}
+					text.WriteString(call.text)
+				}
+				if text.String() != longText {
+					t.Error("message chunks do not add up to the notification text")
+				}
+			},
+		},
+		{
+			name: "long text without screenshot",
+			text: longText,
+			want: func(t *testing.T, calls []telegramCall) {
+				var text strings.Builder
+				for _, call := range calls {
+					if call.method != "sendMessage" {
+						t.Fatalf("method = %s, want sendMessage", call.method)
+					}
+					text.WriteString(call.text)
+				}
+				if len(calls) < 2 || text.String() != longText {
+					t.Errorf("calls = %d, want the text split into several messages", len(calls))
+				}
+			},
+		},
+		{
+			name:       "unreadable screenshot",
+			text:       "Появилась запись",
+			screenshot: filepath.Join(t.TempDir(), "missing.png"),
+			want: func(t *testing.T, calls []telegramCall) {
+				if len(calls) != 1 || calls[0].method != "sendMessage" || calls[0].text != "Появилась запись" {
+					t.Errorf("calls = %+v, want one sendMessage", calls)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, calls := newTelegramAPI(t)
+			telegram := NewTelegramService(TelegramDeps{Token: "token", BaseURL: srv.URL, ChatIDs: []int64{42}})
+
+			if err := telegram.Notify(Notification{Profile: "main", Text: tt.text, ScreenshotPath: tt.screenshot}); err != nil {
+				t.Fatalf("Notify() error: %v", err)
+			}
+
+			got := calls()
+			for _, call := range got {
+				if call.chatID != "42" {
+					t.Errorf("chat_id = %s, want 42", call.chatID)
+				}
+			}
+			tt.want(t, got)
+		})
+	}
+}
+
+func TestTelegramNotifyAPIError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"ok":false,"description":"Forbidden: bot was blocked by the user"}`))
+	}))
+	defer srv.Close()
+
+	telegram := NewTelegramService(TelegramDeps{Token: "token", BaseURL: srv.URL, ChatIDs: []int64{42}})
+	err := telegram.Notify(Notification{Text: "text"})
+	if err == nil || !strings.Contains(err.Error(), "bot was blocked") {
+		t.Fatalf("Notify() error = %v, want telegram api error", err)
+	}
+	if strings.Contains(err.Error(), "token") {
+		t.Errorf("error %q leaks the bot token", err)
+	}
+}
+
+func TestTelegramNotifyFailedChat(t *testing.T) {
+	srv, calls := newTelegramAPI(t)
+	telegram := NewTelegramService(TelegramDeps{Token: "token", BaseURL: srv.URL, ChatIDs: []int64{13, 42}})
+
+	err := telegram.Notify(Notification{Text: "text"})
+	if err == nil || !strings.Contains(err.Error(), "chat 13") || !strings.Contains(err.Error(), "bot was blocked") {
+		t.Fatalf("Notify() error = %v, want error of chat 13", err)
+	}
+
+	// Уведомление отправлено во второй чат, несмотря на ошибку первого
+	got := calls()
+	if len(got) != 1 || got[0].chatID != "42" || got[0].text != "text" {
+		t.Errorf("calls = %+v, want message to chat 42", got)
+	}
+}
+
+func TestSplitText(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		limit int
+		want  []string
+	}{
+		{name: "empty", text: "", limit: 10, want: nil},
+		{name: "fits", text: "abc", limit: 10, want: []string{"abc"}},
+		{name: "split on line break", text: "aaa\nbbb\nccc", limit: 9, want: []string{"aaa\nbbb\n", "ccc"}},
+		{name: "long line", text: "abcdefgh", limit: 3, want: []string{"abc", "def", "gh"}},
+		{name: "multibyte", text: "абвгд", limit: 2, want: []string{"аб", "вг", "д"}},
+		// Эмодзи занимает два символа UTF-16 и не разрезается
+		{name: "surrogate pair", text: "a😀b", limit: 2, want: []string{"a", "😀", "b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := splitText(tt.text, tt.limit)
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") || len(got) != len(tt.want) {
+				t.Errorf("splitText() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
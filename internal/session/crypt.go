@@ -0,0 +1,87 @@
+package session
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// KeySize размер ключа шифрования сессий (AES-256)
+const KeySize = 32
+
+// KeyMismatchError файл сессии зашифрован другим ключом, например, после замены SESSION_KEY
+var KeyMismatchError = errors.New("session file is encrypted with a different key")
+
+// envelope зашифрованный файл сессии
+type envelope struct {
+	// KeyID отпечаток ключа, которым зашифрован файл. Позволяет отличить смену ключа от повреждения файла
+	KeyID string `json:"key_id"`
+	Nonce []byte `json:"nonce"`
+	Data  []byte `json:"data"`
+}
+
+// cipherBox шифрует файлы сессий ключом AES-256-GCM
+type cipherBox struct {
+	aead  cipher.AEAD
+	keyID string
+}
+
+func newCipherBox(key []byte) (*cipherBox, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("session key must be %d bytes, got %d", KeySize, len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(key)
+	return &cipherBox{aead: aead, keyID: hex.EncodeToString(sum[:4])}, nil
+}
+
+// seal шифрует данные сессии. ad - дополнительные данные, привязывающие файл к прокси
+func (b *cipherBox) seal(plaintext, ad []byte) ([]byte, error) {
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("cannot generate nonce: %w", err)
+	}
+
+	return json.Marshal(envelope{
+		KeyID: b.keyID,
+		Nonce: nonce,
+		Data:  b.aead.Seal(nil, nonce, plaintext, ad),
+	})
+}
+
+// open расшифровывает файл сессии. Возвращает KeyMismatchError, если файл зашифрован другим ключом
+func (b *cipherBox) open(data, ad []byte) ([]byte, error) {
+	var e envelope
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal session file: %w", err)
+	}
+	if e.KeyID == "" || len(e.Data) == 0 {
+		return nil, errors.New("session file is not encrypted")
+	}
+	if e.KeyID != b.keyID {
+		return nil, KeyMismatchError
+	}
+	if len(e.Nonce) != b.aead.NonceSize() {
+		return nil, errors.New("invalid session file nonce")
+	}
+
+	plaintext, err := b.aead.Open(nil, e.Nonce, e.Data, ad)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decrypt session file: %w", err)
+	}
+	return plaintext, nil
+}
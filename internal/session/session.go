@@ -0,0 +1,208 @@
+// Package session сохраняет сессию сайта BLS (все куки и localStorage) для каждой пары профиль-прокси
+// и отслеживает срок ее действия, чтобы авторизоваться заново до того, как сайт завершит сессию.
+// Файлы сессий шифруются AES-256-GCM, т.к. по ним можно войти в аккаунт заявителя.
+package session
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/tebeka/selenium"
+	"os"
+	"path/filepath"
+	"time"
+	"visasolution/pkg/util"
+)
+
+// AuthCookie кука авторизации сайта BLS
+const AuthCookie = ".AspNetCore.Cookies"
+
+// sessionFilePerm права файлов сессий: читать их может только владелец
+const sessionFilePerm = 0600
+
+// Значения по умолчанию для NewManager
+const (
+	DefaultTTL           = 20 * time.Minute
+	DefaultRefreshBefore = 5 * time.Minute
+)
+
+// NotFoundError сохраненной сессии для прокси нет
+var NotFoundError = errors.New("session not found")
+
+// ExpiringError сессия истекла или истечет в ближайшее время, нужна повторная авторизация
+var ExpiringError = errors.New("session expires soon")
+
+// Session сессия сайта, сохраненная после авторизации
+type Session struct {
+	Profile string `json:"profile"`
+	// Proxy прокси (host:port), через который выполнена авторизация. Пустая строка - без прокси
+	Proxy        string            `json:"proxy"`
+	Cookies      []selenium.Cookie `json:"cookies"`
+	LocalStorage map[string]string `json:"local_storage,omitempty"`
+
+	LoggedInAt time.Time `json:"logged_in_at"`
+	SavedAt    time.Time `json:"saved_at"`
+	// ExpiresAt время, после которого сайт завершит сессию: TTL с последнего сохранения
+	// или срок действия куки авторизации, если он наступит раньше
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// ExpiresWithin проверяет, истечет ли сессия в течение d с момента now
+func (s *Session) ExpiresWithin(now time.Time, d time.Duration) bool {
+	return !now.Add(d).Before(s.ExpiresAt)
+}
+
+// Manager хранит сессии одного профиля в папке dir, по зашифрованному файлу на прокси
+type Manager struct {
+	dir string
+	box *cipherBox
+	// ttl время жизни сессии с момента последнего использования
+	ttl time.Duration
+	// refreshBefore за сколько до истечения сессия считается истекающей
+	refreshBefore time.Duration
+}
+
+// NewManager создает менеджер сессий. key - ключ шифрования размером KeySize.
+// Нулевые ttl и refreshBefore заменяются значениями по умолчанию
+func NewManager(dir string, key []byte, ttl, refreshBefore time.Duration) (*Manager, error) {
+	box, err := newCipherBox(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	if refreshBefore <= 0 {
+		refreshBefore = DefaultRefreshBefore
+	}
+
+	return &Manager{
+		dir:           dir,
+		box:           box,
+		ttl:           ttl,
+		refreshBefore: refreshBefore,
+	}, nil
+}
+
+// Load загружает сессию для прокси proxyKey.
+// Возвращает NotFoundError, если сессии нет, сессию вместе с ExpiringError, если она скоро истечет,
+// и KeyMismatchError, если сессия зашифрована другим ключом
+func (m *Manager) Load(proxyKey string) (*Session, error) {
+	encrypted, err := os.ReadFile(m.path(proxyKey))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, NotFoundError
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read session: %w", err)
+	}
+
+	data, err := m.box.open(encrypted, []byte(proxyKey))
+	if err != nil {
+		return nil, fmt.Errorf("cannot load session %s: %w", filepath.Base(m.path(proxyKey)), err)
+	}
+
+	var s Session
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal session: %w", err)
+	}
+
+	if s.ExpiresWithin(time.Now(), m.refreshBefore) {
+		return &s, ExpiringError
+	}
+
+	return &s, nil
+}
+
+// Save сохраняет сессию для прокси proxyKey. loggedInAt - время авторизации, с которой началась сессия.
+// Куки должны содержать куку авторизации, иначе сохранять нечего
+func (m *Manager) Save(profile, proxyKey string, cookies []selenium.Cookie, localStorage map[string]string, loggedInAt time.Time) (*Session, error) {
+	now := time.Now()
+	s := &Session{
+		Profile:      profile,
+		Proxy:        proxyKey,
+		Cookies:      cookies,
+		LocalStorage: localStorage,
+		LoggedInAt:   loggedInAt,
+		SavedAt:      now,
+		ExpiresAt:    now.Add(m.ttl),
+	}
+
+	auth, ok := findCookie(cookies, AuthCookie)
+	if !ok {
+		return nil, fmt.Errorf("no %s cookie to save", AuthCookie)
+	}
+	// Expiry равен 0 у сессионной куки, тогда срок определяется только TTL
+	if auth.Expiry > 0 {
+		if expiry := time.Unix(int64(auth.Expiry), 0); expiry.Before(s.ExpiresAt) {
+			s.ExpiresAt = expiry
+		}
+	}
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil, fmt.Errorf("cannot marshal session: %w", err)
+	}
+
+	// Прокси - дополнительные данные шифрования, поэтому файл сессии нельзя подложить для другого прокси
+	encrypted, err := m.box.seal(data, []byte(proxyKey))
+	if err != nil {
+		return nil, fmt.Errorf("cannot encrypt session: %w", err)
+	}
+
+	// Атомарная запись: при сбое остается предыдущая сессия, а не обрезанный файл
+	if err := util.WriteFileAtomic(m.path(proxyKey), encrypted, sessionFilePerm); err != nil {
+		return nil, fmt.Errorf("cannot write session: %w", err)
+	}
+
+	return s, nil
+}
+
+// Delete удаляет сессию для прокси proxyKey. Отсутствие сессии не считается ошибкой
+func (m *Manager) Delete(proxyKey string) error {
+	err := os.Remove(m.path(proxyKey))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("cannot remove session: %w", err)
+	}
+	return nil
+}
+
+// DeleteAll удаляет сессии профиля для всех прокси
+func (m *Manager) DeleteAll() error {
+	paths, err := filepath.Glob(filepath.Join(m.dir, "session*.json"))
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, p := range paths {
+		if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("cannot remove sessions: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// path возвращает путь к файлу сессии. Ключ прокси хешируется, т.к. содержит недопустимые в имени файла символы
+func (m *Manager) path(proxyKey string) string {
+	if proxyKey == "" {
+		return filepath.Join(m.dir, "session.json")
+	}
+
+	sum := sha256.Sum256([]byte(proxyKey))
+	return filepath.Join(m.dir, "session_"+hex.EncodeToString(sum[:6])+".json")
+}
+
+func findCookie(cookies []selenium.Cookie, name string) (selenium.Cookie, bool) {
+	for _, c := range cookies {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	return selenium.Cookie{}, false
+}
@@ -0,0 +1,158 @@
+package session
+
+import (
+	"bytes"
+	"errors"
+	"github.com/tebeka/selenium"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testProxyKey = "10.0.0.1:8080"
+
+var testCookies = []selenium.Cookie{
+	{Name: AuthCookie, Value: "auth-secret"},
+	{Name: "antiforgery", Value: "token"},
+}
+
+func newTestManager(t *testing.T, dir string, keyByte byte) *Manager {
+	t.Helper()
+
+	m, err := NewManager(dir, bytes.Repeat([]byte{keyByte}, KeySize), time.Hour, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestManagerSaveLoad(t *testing.T) {
+	dir := t.TempDir()
+	m := newTestManager(t, dir, 1)
+
+	loggedInAt := time.Now().Add(-time.Minute).Truncate(time.Second)
+	if _, err := m.Save("test", testProxyKey, testCookies, map[string]string{"lang": "ru"}, loggedInAt); err != nil {
+		t.Fatalf("Save() error: %v", err)
+	}
+
+	info, err := os.Stat(m.path(testProxyKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != sessionFilePerm {
+		t.Errorf("session file permissions = %o, want %o", perm, sessionFilePerm)
+	}
+	data, err := os.ReadFile(m.path(testProxyKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "auth-secret") {
+		t.Error("session file contains cookie in plaintext")
+	}
+	if tmp, _ := filepath.Glob(filepath.Join(dir, "*.tmp")); len(tmp) != 0 {
+		t.Errorf("temporary files left: %v", tmp)
+	}
+
+	s, err := m.Load(testProxyKey)
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if len(s.Cookies) != 2 || s.Cookies[0].Value != "auth-secret" || s.LocalStorage["lang"] != "ru" {
+		t.Errorf("unexpected session: %+v", s)
+	}
+	if !s.LoggedInAt.Equal(loggedInAt) || s.Profile != "test" || s.Proxy != testProxyKey {
+		t.Errorf("unexpected session: %+v", s)
+	}
+}
+
+func TestManagerLoadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(t *testing.T, dir string)
+		wantErr error
+		// wantAny ожидается любая ошибка, кроме перечисленных
+		wantAny bool
+	}{
+		{
+			name:    "not found",
+			setup:   func(t *testing.T, dir string) {},
+			wantErr: NotFoundError,
+		},
+		{
+			name: "auth cookie expires soon",
+			setup: func(t *testing.T, dir string) {
+				cookies := []selenium.Cookie{{Name: AuthCookie, Value: "auth", Expiry: uint(time.Now().Add(30 * time.Second).Unix())}}
+				if _, err := newTestManager(t, dir, 1).Save("test", testProxyKey, cookies, nil, time.Now()); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantErr: ExpiringError,
+		},
+		{
+			name: "key changed",
+			setup: func(t *testing.T, dir string) {
+				if _, err := newTestManager(t, dir, 2).Save("test", testProxyKey, testCookies, nil, time.Now()); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantErr: KeyMismatchError,
+		},
+		{
+			name: "file of another proxy",
+			setup: func(t *testing.T, dir string) {
+				m := newTestManager(t, dir, 1)
+				if _, err := m.Save("test", "10.0.0.2:8080", testCookies, nil, time.Now()); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.Rename(m.path("10.0.0.2:8080"), m.path(testProxyKey)); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantAny: true,
+		},
+		{
+			name: "plaintext file",
+			setup: func(t *testing.T, dir string) {
+				m := newTestManager(t, dir, 1)
+				if err := os.WriteFile(m.path(testProxyKey), []byte(`{"cookies":[{"name":".AspNetCore.Cookies"}]}`), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantAny: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			tt.setup(t, dir)
+
+			_, err := newTestManager(t, dir, 1).Load(testProxyKey)
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Load() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantAny && (err == nil || errors.Is(err, NotFoundError) || errors.Is(err, ExpiringError)) {
+				t.Errorf("Load() error = %v, want decrypt error", err)
+			}
+		})
+	}
+}
+
+func TestManagerSaveWithoutAuthCookie(t *testing.T) {
+	m := newTestManager(t, t.TempDir(), 1)
+
+	if _, err := m.Save("test", testProxyKey, []selenium.Cookie{{Name: "antiforgery"}}, nil, time.Now()); err == nil {
+		t.Error("Save() error = nil, want error")
+	}
+	if _, err := m.Load(testProxyKey); !errors.Is(err, NotFoundError) {
+		t.Errorf("Load() error = %v, want %v", err, NotFoundError)
+	}
+}
+
+func TestNewManagerInvalidKey(t *testing.T) {
+	if _, err := NewManager(t.TempDir(), []byte("short"), 0, 0); err == nil {
+		t.Error("NewManager() error = nil, want error")
+	}
+}
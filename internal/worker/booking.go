@@ -0,0 +1,286 @@
+package worker
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"sort"
+	"time"
+	cfg "visasolution/internal/config"
+	"visasolution/internal/service"
+	"visasolution/pkg/util"
+)
+
+const confirmationScreenshotFilename = "confirmation.png"
+
+// bookedFilename файл с результатом подтвержденного бронирования. Пока он есть, бронирование для профиля не выполняется.
+// dryRunFilename файл с результатом последнего бронирования в режиме DryRun: для тех же даты и слота оно не повторяется
+const (
+	bookedFilename = "booked.json"
+	dryRunFilename = "dry_run.json"
+	bookedFilePerm = 0600
+)
+
+// NoMatchingSlotError ни один из доступных слотов не подходит под ограничения бронирования
+var NoMatchingSlotError = errors.New("no slot matches booking constraints")
+
+// BookingResult результат автоматического бронирования
+type BookingResult struct {
+	Date time.Time `json:"date"`
+	Slot string    `json:"slot"`
+	// DryRun true, если бронирование остановлено перед финальным подтверждением
+	DryRun bool `json:"dry_run"`
+	// Reference номер записи со страницы подтверждения. Пустой при DryRun
+	Reference string `json:"reference"`
+	// ScreenshotPath путь к скриншоту последней страницы бронирования (страницы подтверждения)
+	ScreenshotPath string `json:"screenshot_path"`
+	// BookedAt время подтверждения записи. Нулевое при DryRun
+	BookedAt time.Time `json:"booked_at"`
+}
+
+// collectAppointmentDays читает из календаря доступные даты и свободные слоты на каждую из них.
+// Даты возвращаются в порядке возрастания
+func (w *Worker) collectAppointmentDays() ([]service.AppointmentDay, error) {
+	dates, err := w.services.Selenium.AvailableDates()
+	if err != nil {
+		return nil, fmt.Errorf("get available dates error:%w", err)
+	}
+	sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+
+	days := make([]service.AppointmentDay, 0, len(dates))
+	for _, date := range dates {
+		err := w.services.Selenium.SelectDate(date)
+		if err != nil {
+			return nil, fmt.Errorf("select date error:%w", err)
+		}
+
+		slots, err := w.services.Selenium.AvailableSlots()
+		if err != nil {
+			return nil, fmt.Errorf("get available slots for %s error:%w", date.Format(time.DateOnly), err)
+		}
+
+		days = append(days, service.AppointmentDay{Date: date, Slots: slots})
+	}
+
+	return days, nil
+}
+
+// handleBooking выбирает из доступных дней самую раннюю дату и время, подходящие под ограничения профиля,
+// и бронирует запись, если она еще не подтверждена для профиля. В режиме DryRun бронирование для тех же даты и слота
+// не повторяется. Отсутствие подходящих слотов и пропуск бронирования - обычный результат, он записывается в сводку без ошибки
+func (w *Worker) handleBooking() error {
+	booked, err := w.loadBooked()
+	if err != nil {
+		// Без отметки нельзя исключить повторное бронирование, поэтому оно не выполняется
+		return err
+	}
+	if booked != nil {
+		w.report.AlreadyBooked = true
+		log.Printf("Appointment already booked on %s %s (reference %s), skip booking. Remove %s to book again\n",
+			booked.Date.Format(time.DateOnly), booked.Slot, booked.Reference, w.bookedPath())
+		return nil
+	}
+
+	date, slot, err := chooseSlot(w.d.Profile.Booking, w.report.Days)
+	if errors.Is(err, NoMatchingSlotError) {
+		w.report.NoMatchingSlot = true
+		log.Println("No slot matches booking constraints, skip booking")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	log.Printf("Slot chosen: %s %s\n", date.Format(time.DateOnly), slot)
+
+	if w.d.Profile.Booking.DryRun && w.dryRunDone(date, slot) {
+		w.report.DryRunRepeated = true
+		log.Println("Dry run for this slot already done, skip booking")
+		return nil
+	}
+
+	w.report.Booking, err = w.book(date, slot)
+	return err
+}
+
+// book выполняет бронирование выбранных даты и слота после обнаружения доступной записи:
+// заполняет данные заявителя, проходит капчу и подтверждает запись.
+// В режиме DryRun останавливается перед финальным подтверждением
+func (w *Worker) book(date time.Time, slot string) (*BookingResult, error) {
+	prefs := w.d.Profile.Booking
+
+	result := &BookingResult{Date: date, Slot: slot, DryRun: prefs.DryRun}
+
+	// После сбора слотов в календаре выбрана последняя дата
+	err := w.services.Selenium.SelectDate(date)
+	if err != nil {
+		return result, fmt.Errorf("select date error:%w", err)
+	}
+
+	err = w.services.Selenium.SelectSlot(slot)
+	if err != nil {
+		return result, fmt.Errorf("select slot error:%w", err)
+	}
+
+	err = w.services.Selenium.SubmitSlot()
+	if err != nil {
+		return result, fmt.Errorf("submit slot error:%w", err)
+	}
+
+	err = w.handleOptionalCaptcha()
+	if err != nil {
+		return result, fmt.Errorf("booking captcha error:%w", err)
+	}
+
+	err = w.services.Selenium.FillApplicantDetails(prefs.ApplicantName)
+	if err != nil {
+		return result, fmt.Errorf("fill applicant details error:%w", err)
+	}
+
+	if prefs.DryRun {
+		log.Println("Dry run: booking stopped before final submit")
+		result.ScreenshotPath = w.saveBookingScreenshot()
+		if err := w.saveMarker(w.dryRunPath(), result); err != nil {
+			log.Println("Cannot save dry run marker, dry run will be repeated:", err)
+		}
+		return result, nil
+	}
+
+	err = w.services.Selenium.ConfirmBooking()
+	if err != nil {
+		return result, fmt.Errorf("confirm booking error:%w", err)
+	}
+	result.BookedAt = time.Now()
+	result.ScreenshotPath = w.saveBookingScreenshot()
+
+	reference, err := w.services.Selenium.BookingReference()
+	if err != nil {
+		// Запись уже подтверждена, номер можно найти на скриншоте
+		log.Println("Cannot get booking reference:", err)
+	}
+	result.Reference = reference
+
+	log.Println("!!!Appointment booked!!! Reference:", reference)
+
+	// Запись подтверждена, повторное бронирование может привести к дублю записи или блокировке аккаунта
+	if err := w.saveMarker(w.bookedPath(), result); err != nil {
+		log.Println("Cannot save booked marker, booking will be repeated:", err)
+	}
+
+	return result, nil
+}
+
+// loadBooked возвращает результат подтвержденного ранее бронирования профиля или nil, если бронирования не было
+func (w *Worker) loadBooked() (*BookingResult, error) {
+	return loadMarker(w.bookedPath())
+}
+
+// dryRunDone проверяет, выполнялось ли уже бронирование в режиме DryRun для даты date и слота slot.
+// Если отметку не удалось прочитать, бронирование повторяется: в режиме DryRun запись не подтверждается
+func (w *Worker) dryRunDone(date time.Time, slot string) bool {
+	last, err := loadMarker(w.dryRunPath())
+	if err != nil {
+		log.Println("Cannot load dry run marker:", err)
+		return false
+	}
+	return last != nil && last.Date.Equal(date) && last.Slot == slot
+}
+
+// loadMarker читает результат бронирования из файла filePath. Возвращает nil, если файла нет
+func loadMarker(filePath string) (*BookingResult, error) {
+	data, err := os.ReadFile(filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read booking marker: %w", err)
+	}
+
+	var result BookingResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal booking marker: %w", err)
+	}
+
+	return &result, nil
+}
+
+// saveMarker сохраняет результат бронирования профиля в файл filePath
+func (w *Worker) saveMarker(filePath string, result *BookingResult) error {
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return fmt.Errorf("cannot marshal booking marker: %w", err)
+	}
+
+	return util.WriteFileAtomic(filePath, data, bookedFilePerm)
+}
+
+func (w *Worker) bookedPath() string {
+	return path.Join(w.d.TmpFolder, bookedFilename)
+}
+
+func (w *Worker) dryRunPath() string {
+	return path.Join(w.d.TmpFolder, dryRunFilename)
+}
+
+// chooseSlot выбирает самую раннюю дату и самый ранний слот, подходящие под ограничения.
+// days должны быть отсортированы по возрастанию даты
+func chooseSlot(prefs cfg.BookingPreferences, days []service.AppointmentDay) (time.Time, string, error) {
+	for _, day := range days {
+		if !prefs.MatchDate(day.Date) {
+			continue
+		}
+
+		if slot := earliestSlot(prefs, day.Slots); slot != "" {
+			return day.Date, slot, nil
+		}
+	}
+
+	return time.Time{}, "", NoMatchingSlotError
+}
+
+// earliestSlot возвращает самый ранний по времени начала слот, подходящий под ограничения, или пустую строку
+func earliestSlot(prefs cfg.BookingPreferences, slots []string) string {
+	var best string
+	for _, slot := range slots {
+		if !prefs.MatchSlot(slot) {
+			continue
+		}
+		if best == "" || cfg.SlotStartTime(slot) < cfg.SlotStartTime(best) {
+			best = slot
+		}
+	}
+	return best
+}
+
+// handleOptionalCaptcha проходит капчу, если она есть на странице
+func (w *Worker) handleOptionalCaptcha() error {
+	present, err := w.services.Selenium.IsCaptchaPresent()
+	if err != nil {
+		return fmt.Errorf("check captcha presence error:%w", err)
+	}
+	if !present {
+		return nil
+	}
+
+	return w.handleCaptcha()
+}
+
+// saveBookingScreenshot сохраняет скриншот страницы бронирования и возвращает путь к нему.
+// Ошибка не прерывает бронирование, в этом случае возвращается пустая строка
+func (w *Worker) saveBookingScreenshot() string {
+	data, err := w.services.Selenium.PullPageScreenshot()
+	if err != nil {
+		log.Println("Cannot pull booking screenshot:", err)
+		return ""
+	}
+
+	screenshotPath := path.Join(w.d.TmpFolder, confirmationScreenshotFilename)
+	if err := util.WriteFile(screenshotPath, data); err != nil {
+		log.Println("Cannot save booking screenshot:", err)
+		return ""
+	}
+
+	return screenshotPath
+}
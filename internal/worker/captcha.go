@@ -4,52 +4,52 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"path"
+	"visasolution/internal/apperr"
+	"visasolution/internal/metrics"
 	"visasolution/internal/service"
 	util "visasolution/pkg/util"
 )
 
-const (
-	// msg сообщение, которое отправляется chat api
-	msg                = `you see an image with the task: ‘Select all squares with the number …’ Recognize the text in each square and send ONLY the cell numbers that contain this number, separated by commas without spaces. Numbering is left to right starting with 1.Take your time when choosing cards. The wrong decision is costly ”`
-	captchaImgFilename = "captcha.png"
-)
+const captchaImgFilename = "captcha.png"
 
 // RetryProcessCaptcha пытается решить капчу заданное количество раз
 func (w *Worker) RetryProcessCaptcha(maxTries int) error {
 	for cntTries := 1; cntTries <= maxTries; cntTries++ {
 		log.Printf("try No %d to solve the captcha starts ...\n", cntTries)
+		w.report.CaptchaAttempts++
 		err := w.processCaptcha()
 		if err == nil {
+			w.report.CaptchaSolved++
+			metrics.CaptchaAttempts.WithLabelValues("solved").Inc()
+			metrics.CaptchaAttemptsPerSolve.WithLabelValues("solved").Observe(float64(cntTries))
 			return nil
 		}
 		if errors.Is(err, service.InvalidSelectionError) {
+			w.report.CaptchaInvalid++
+			metrics.CaptchaAttempts.WithLabelValues("invalid_selection").Inc()
 			log.Println("invalid selection error, try again")
 			continue
 		}
+		metrics.CaptchaAttempts.WithLabelValues("error").Inc()
+		metrics.CaptchaAttemptsPerSolve.WithLabelValues("failed").Observe(float64(cntTries))
 		return err
 	}
-	return fmt.Errorf("couldnt solve captcha after %d tries", maxTries)
+	metrics.CaptchaAttemptsPerSolve.WithLabelValues("failed").Observe(float64(maxTries))
+	return apperr.New(apperr.ClassCaptchaUnsolvable, fmt.Sprintf("couldnt solve captcha after %d tries", maxTries))
 }
 
 // processCaptcha обрабатывает капчу, занимается ее решением
 func (w *Worker) processCaptcha() error {
-	err := w.saveCaptchaImage(w.captchaImgPath())
+	layout, err := w.saveCaptchaImage(w.captchaImgPath())
 	if err != nil {
 		return fmt.Errorf("save captcha image error:%w", err)
 	}
 
-	link, err := w.services.UploadImage(w.captchaImgPath())
+	cardNums, err := w.services.CaptchaSolver.Solve(w.captchaImgPath(), layout)
 	if err != nil {
-		return fmt.Errorf("failed to upload captcha:%w", err)
+		return fmt.Errorf("solve captcha error:%w", err)
 	}
-	log.Println("captcha was uploaded, link: ", link)
-
-	resp, err := w.services.Chat.Request4VPreviewWithImage(msg, link)
-	if err != nil {
-		return fmt.Errorf("request to chat api with image url error:%w", err)
-	}
-
-	cardNums, err := util.StrToIntSlice(w.services.Chat.GetRespMsg(resp), ",")
 	log.Println("cards to select: ", cardNums)
 
 	err = w.services.Selenium.SolveCaptcha(cardNums)
@@ -60,15 +60,15 @@ func (w *Worker) processCaptcha() error {
 	return nil
 }
 
-// saveCaptchaImage сохраняет изображение капчи
-func (w *Worker) saveCaptchaImage(relativePath string) error {
+// saveCaptchaImage сохраняет изображение капчи и возвращает расположение карточек на нем
+func (w *Worker) saveCaptchaImage(relativePath string) (service.CaptchaLayout, error) {
 	img, err := w.services.Selenium.PullCaptchaImage()
 	if err != nil {
-		return fmt.Errorf("cannot pull captcha image:%w", err)
+		return service.CaptchaLayout{}, fmt.Errorf("cannot pull captcha image:%w", err)
 	}
-	return util.WriteFile(relativePath, img)
+	return img.Layout, util.WriteFile(relativePath, img.PNG)
 }
 
 func (w *Worker) captchaImgPath() string {
-	return w.d.TmpFolder + captchaImgFilename
+	return path.Join(w.d.TmpFolder, captchaImgFilename)
 }
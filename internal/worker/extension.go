@@ -1,6 +1,7 @@
 package worker
 
 import (
+	"encoding/json"
 	"fmt"
 	"path"
 	"visasolution/internal/config"
@@ -37,8 +38,8 @@ var config = {
         mode: "fixed_servers",
         rules: {
         singleProxy: {
-            scheme: "http",
-            host: "%s",
+            scheme: %s,
+            host: %s,
             port: parseInt(%s)
         },
         bypassList: ["localhost"]
@@ -46,12 +47,16 @@ var config = {
     };
 
 chrome.proxy.settings.set({value: config, scope: "regular"}, function() {});
+`
 
+// backgroundAuthJS шаблон обработчика авторизации на прокси. Добавляется, только если у прокси есть логин и пароль.
+// Chrome не поддерживает авторизацию для socks5 прокси, поэтому в списке российских прокси socks5 прокси с логином и паролем не принимаются
+const backgroundAuthJS = `
 function callbackFn(details) {
     return {
         authCredentials: {
-            username: "%s",
-            password: "%s"
+            username: %s,
+            password: %s
         }
     };
 }
@@ -63,13 +68,24 @@ chrome.webRequest.onAuthRequired.addListener(
 );
 `
 
-// GenerateProxyAuthExtension приминает прокси в виде "ip:host@usrname:pswrd"
+// GenerateProxyAuthExtension создает расширение Chrome, которое направляет трафик через прокси
+// с учетом его схемы и при необходимости авторизуется на нем
 func (w *Worker) GenerateProxyAuthExtension(proxy config.Proxy) (string, error) {
 	extensionPath := w.chromeExtensionPath()
 
+	scheme := proxy.Scheme
+	if scheme == "" {
+		scheme = config.ProxySchemeHTTP
+	}
+
+	script := fmt.Sprintf(backgroundJS, jsString(scheme), jsString(proxy.Host), jsString(proxy.Port))
+	if proxy.HasAuth() {
+		script += fmt.Sprintf(backgroundAuthJS, jsString(proxy.Username), jsString(proxy.Password))
+	}
+
 	filenames := []string{"manifest.json", "background.js"}
-	manifestContent := []byte(fmt.Sprintf(manifest))
-	backgroundJSContent := []byte(fmt.Sprintf(backgroundJS, proxy.Host, proxy.Port, proxy.Username, proxy.Password))
+	manifestContent := []byte(manifest)
+	backgroundJSContent := []byte(script)
 
 	err := util.CreateZip(filenames, [][]byte{manifestContent, backgroundJSContent}, extensionPath)
 	if err != nil {
@@ -79,6 +95,12 @@ func (w *Worker) GenerateProxyAuthExtension(proxy config.Proxy) (string, error)
 	return extensionPath, nil
 }
 
+// jsString возвращает строковый литерал JavaScript, экранируя кавычки и спецсимволы
+func jsString(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
 func (w *Worker) chromeExtensionPath() string {
-	return path.Join(w.d.TmpFolder, chromeExtensionFilename)
+	return path.Join(w.d.ExtensionFolder, chromeExtensionFilename)
 }
@@ -0,0 +1,139 @@
+package worker_test
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+	cfg "visasolution/internal/config"
+	"visasolution/internal/replay"
+	"visasolution/internal/service"
+	"visasolution/internal/session"
+	"visasolution/internal/worker"
+)
+
+const replayVisaTypeURL = "Global/bls/VisaTypeVerification"
+
+// fakeCaptchaSolver решает любую капчу одними и теми же карточками
+type fakeCaptchaSolver struct {
+	calls int
+}
+
+func (s *fakeCaptchaSolver) Solve(string, service.CaptchaLayout) ([]int, error) {
+	s.calls++
+	return []int{1, 5, 9}, nil
+}
+
+// replayWorker создает воркер, работающий с записью из testdata/replay/<name> через заглушку веб-драйвера
+func replayWorker(t *testing.T, name string) (*worker.Worker, *replay.Server, *fakeCaptchaSolver) {
+	t.Helper()
+
+	rec, err := replay.LoadRecording(filepath.Join("testdata", "replay", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := replay.NewServer(rec)
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(httpServer.Close)
+
+	selenium := service.NewSeleniumService(3, "", nil)
+	selenium.SetWebDriver(replay.NewDriver(httpServer.URL))
+
+	solver := &fakeCaptchaSolver{}
+	services := &service.Service{Selenium: selenium, CaptchaSolver: solver}
+
+	w := worker.NewWorker(services, worker.Deps{
+		BaseURL:         httpServer.URL + "/",
+		VisaTypeURL:     replayVisaTypeURL,
+		TmpFolder:       t.TempDir(),
+		ScreenshotFile:  "screenshot.png",
+		ExtensionFolder: t.TempDir(),
+		Profile: cfg.Profile{
+			Name:        "replay",
+			BlsEmail:    "applicant@example.com",
+			BlsPassword: "password",
+			Visa: cfg.VisaPreferences{
+				Jurisdiction: "Moscow",
+				VisaType:     "Schengen Visa",
+			},
+		},
+		CaptchaMaxTries: 2,
+		SessionKey:      bytes.Repeat([]byte{1}, session.KeySize),
+	})
+	if err := w.MakePreparation(); err != nil {
+		t.Fatal(err)
+	}
+
+	return w, server, solver
+}
+
+func TestRunReplay(t *testing.T) {
+	tests := []struct {
+		name      string
+		available bool
+		days      []service.AppointmentDay
+	}{
+		{
+			name:      "not_available",
+			available: false,
+		},
+		{
+			name:      "available",
+			available: true,
+			days: []service.AppointmentDay{
+				{Date: time.Date(2026, time.November, 25, 0, 0, 0, 0, time.Local), Slots: []string{"09:00-09:15", "10:30-10:45"}},
+				{Date: time.Date(2026, time.December, 3, 0, 0, 0, 0, time.Local), Slots: []string{"09:00-09:15", "10:30-10:45"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			w, server, solver := replayWorker(t, tt.name)
+
+			if err := w.Run(); err != nil {
+				t.Fatalf("Run() error: %v", err)
+			}
+			if remaining := server.Remaining(); remaining != 0 {
+				t.Errorf("recording not fully replayed: %d steps left", remaining)
+			}
+
+			report := w.LastReport()
+			if !report.AuthorizationNeeded {
+				t.Error("AuthorizationNeeded = false, want true")
+			}
+			if report.CaptchaSolved != 2 || solver.calls != 2 {
+				t.Errorf("captcha solved %d times with %d solver calls, want 2", report.CaptchaSolved, solver.calls)
+			}
+			if !report.AvailabilityChecked {
+				t.Error("AvailabilityChecked = false, want true")
+			}
+			if report.Available != tt.available {
+				t.Errorf("Available = %v, want %v", report.Available, tt.available)
+			}
+			if len(report.Days) != len(tt.days) {
+				t.Fatalf("Days = %v, want %v", report.Days, tt.days)
+			}
+			for i, day := range report.Days {
+				if !day.Date.Equal(tt.days[i].Date) || len(day.Slots) != len(tt.days[i].Slots) {
+					t.Errorf("Days[%d] = %v, want %v", i, day, tt.days[i])
+					continue
+				}
+				for j, slot := range day.Slots {
+					if slot != tt.days[i].Slots[j] {
+						t.Errorf("Days[%d].Slots[%d] = %s, want %s", i, j, slot, tt.days[i].Slots[j])
+					}
+				}
+			}
+
+			if _, err := w.LastScreenshot(); err != nil {
+				t.Errorf("screenshot not saved: %v", err)
+			}
+		})
+	}
+}
@@ -1,66 +1,180 @@
 package worker
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
-	"github.com/tebeka/selenium"
 	"log"
 	"os"
+	"path"
+	"sync"
+	"sync/atomic"
+	"time"
+	"visasolution/internal/apperr"
 	cfg "visasolution/internal/config"
+	"visasolution/internal/metrics"
+	"visasolution/internal/replay"
 	"visasolution/internal/service"
+	"visasolution/internal/session"
 	"visasolution/pkg/util"
 )
 
-// TooManyRequestsErr ошибка, возникающая при превышении лимита запросов к ресурсу
-type TooManyRequestsErr struct {
-	Msg string
-}
-
-func (e TooManyRequestsErr) Error() string {
-	return fmt.Sprintf("too many requests error: %s", e.Msg)
-}
-
-// WDConnectError ошибка подключения к Selenium WebDriver
-type WDConnectError struct {
-	Msg string
-}
-
-func (e WDConnectError) Error() string {
-	return fmt.Sprintf("webdriver connect error: %s", e.Msg)
-}
-
 type Deps struct {
 	BaseURL     string
 	VisaTypeURL string
 
 	TmpFolder      string
-	CookieFile     string
 	ScreenshotFile string
+	// ExtensionFolder общая для всех профилей папка, в которую генерируется расширение для авторизации прокси,
+	// т.к. веб-драйвер один на все профили
+	ExtensionFolder string
+
+	// Profile заявитель, для которого выполняется работа.
+	// TmpFolder должен быть уникальным для каждого профиля
+	Profile cfg.Profile
 
-	NotifiedEmail   string
 	CaptchaMaxTries int
+
+	// CurrentProxy возвращает прокси, через который подключен веб-драйвер. Сессия сайта сохраняется отдельно
+	// для каждого прокси. Если nil, сессия одна на профиль
+	CurrentProxy func() cfg.Proxy
+	// SessionTTL время жизни сессии сайта с последнего использования, SessionRefreshBefore - за сколько
+	// до ее истечения авторизоваться заново. Нулевые значения - значения по умолчанию (см. session.NewManager)
+	SessionTTL           time.Duration
+	SessionRefreshBefore time.Duration
+	// SessionKey ключ шифрования файлов сессий (session.KeySize байт)
+	SessionKey []byte
+
+	// Recorder записывает страницы сайта при каждом выполнении Run. Если nil, запись отключена
+	Recorder *replay.Recorder
+}
+
+// legacyCookieFile файл с куками авторизации в открытом виде, который удаляется при подготовке
+const legacyCookieFile = "cookies.json"
+
+// Этапы выполнения Run
+const (
+	PhaseIdle              = "idle"
+	PhaseOpenSite          = "open_site"
+	PhaseAuthorization     = "authorization"
+	PhaseCaptcha           = "captcha"
+	PhaseBookNew           = "book_new_appointment"
+	PhaseCheckAvailability = "check_availability"
+	PhaseCollectSlots      = "collect_slots"
+	PhaseBooking           = "booking"
+)
+
+// RunReport сводка о последнем выполнении Run
+type RunReport struct {
+	StartedAt  time.Time
+	FinishedAt time.Time
+	// PageLoad время загрузки главной страницы сайта, используется как задержка прокси
+	PageLoad time.Duration
+
+	AuthorizationNeeded bool
+
+	CaptchaAttempts int
+	CaptchaSolved   int
+	// CaptchaInvalid количество неверных решений капчи (InvalidSelectionError)
+	CaptchaInvalid int
+
+	AvailabilityChecked bool
+	Available           bool
+
+	// VisaCategory категория визы, для которой проверялась доступность записи
+	VisaCategory string
+	// Days доступные даты и слоты. Заполняется, только если запись доступна
+	Days []service.AppointmentDay
+
+	// Booking результат автоматического бронирования. nil, если бронирование не выполнялось
+	Booking *BookingResult
+	// NoMatchingSlot true, если запись доступна, но ни один слот не подходит под ограничения бронирования
+	NoMatchingSlot bool
+	// AlreadyBooked true, если бронирование пропущено, т.к. запись для профиля уже подтверждена ранее
+	AlreadyBooked bool
+	// DryRunRepeated true, если бронирование в режиме DryRun пропущено, т.к. для тех же даты и слота оно уже выполнялось
+	DryRunRepeated bool
 }
 
 type Worker struct {
 	services *service.Service
 	d        Deps
+	sessions *session.Manager
+	// session сессия, восстановленная в текущем выполнении Run. nil - сессия начата авторизацией
+	session *session.Session
+	// profileMu защищает d.Profile: профиль заменяется при перезагрузке конфигурации и читается из других горутин
+	profileMu sync.RWMutex
+
+	report RunReport
+	// phase текущий этап выполнения Run, читается из других горутин
+	phase atomic.Value
+	// phaseStartedAt время начала текущего этапа, используется для метрик
+	phaseStartedAt time.Time
 }
 
-func NewWorker(services *service.Service, emailDeps Deps) *Worker {
-	return &Worker{
+func NewWorker(services *service.Service, deps Deps) *Worker {
+	w := &Worker{
 		services: services,
-		d:        emailDeps,
+		d:        deps,
 	}
+	w.phase.Store(PhaseIdle)
+	return w
+}
+
+// Phase возвращает текущий этап выполнения Run. Безопасен для вызова из других горутин
+func (w *Worker) Phase() string {
+	return w.phase.Load().(string)
+}
+
+// setPhase переключает этап выполнения Run и записывает длительность предыдущего этапа в метрики
+func (w *Worker) setPhase(phase string) {
+	now := time.Now()
+	if prev := w.Phase(); prev != PhaseIdle {
+		metrics.PhaseDuration.WithLabelValues(prev).Observe(now.Sub(w.phaseStartedAt).Seconds())
+	}
+
+	w.phaseStartedAt = now
+	w.phase.Store(phase)
+}
+
+// Profile возвращает профиль заявителя, с которым работает воркер
+func (w *Worker) Profile() cfg.Profile {
+	w.profileMu.RLock()
+	defer w.profileMu.RUnlock()
+
+	return w.d.Profile
+}
+
+// SetProfile заменяет профиль заявителя. Вызывается между выполнениями Run, имя профиля не меняется
+func (w *Worker) SetProfile(profile cfg.Profile) {
+	w.profileMu.Lock()
+	defer w.profileMu.Unlock()
+
+	w.d.Profile = profile
 }
 
-// MakePreparation выполняет подготовительную работу
+// MakePreparation выполняет подготовительную работу: создает папки и менеджер сессий. Вызывается до Run
 func (w *Worker) MakePreparation() error {
 	err := util.CreateFolder(w.d.TmpFolder)
 	if err != nil {
 		return fmt.Errorf("cannot create tmp folder:%w", err)
 	}
 
+	err = util.CreateFolder(w.d.ExtensionFolder)
+	if err != nil {
+		return fmt.Errorf("cannot create extension folder:%w", err)
+	}
+
+	w.sessions, err = session.NewManager(w.d.TmpFolder, w.d.SessionKey, w.d.SessionTTL, w.d.SessionRefreshBefore)
+	if err != nil {
+		return fmt.Errorf("cannot create session manager:%w", err)
+	}
+
+	// Прежние версии хранили куки авторизации в открытом виде
+	err = os.Remove(path.Join(w.d.TmpFolder, legacyCookieFile))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("cannot remove plaintext cookies file:%w", err)
+	}
+
 	return nil
 }
 
@@ -68,7 +182,7 @@ func (w *Worker) MakePreparation() error {
 func (w *Worker) ConnectSameProxy(connector service.ProxyConnecter) error {
 	err := connector.ConnectWithProxy(w.chromeExtensionPath())
 	if err != nil {
-		return fmt.Errorf("selenium connect with proxy error:%w", err)
+		return apperr.Wrap(apperr.ClassWebDriverConnect, "selenium connect with proxy error", err)
 	}
 
 	return nil
@@ -85,7 +199,7 @@ func (w *Worker) ConnectGeneratedProxy(connector service.ProxyConnecter, proxy c
 
 	err = connector.ConnectWithProxy(extensionPath)
 	if err != nil {
-		return fmt.Errorf("selenium connect with new generated proxy error:%w", err)
+		return apperr.Wrap(apperr.ClassWebDriverConnect, "selenium connect with new generated proxy error", err)
 	}
 
 	return nil
@@ -93,11 +207,28 @@ func (w *Worker) ConnectGeneratedProxy(connector service.ProxyConnecter, proxy c
 
 // Run должен быть вызван только после инициализации всех сервисов.
 // Функция выполняет основной алгоритм работы бота.
+// Ошибки сервисов возвращаются обернутыми, их класс определяется через apperr.ClassOf
 func (w *Worker) Run() error {
-	err := w.services.Selenium.GoTo(w.d.BaseURL)
-	if errors.Is(err, service.InvalidSessionError) {
-		return WDConnectError{Msg: err.Error()}
+	log.Println("Run for profile:", w.d.Profile.Name)
+
+	w.report = RunReport{StartedAt: time.Now()}
+	defer func() {
+		w.report.FinishedAt = time.Now()
+		w.setPhase(PhaseIdle)
+	}()
+
+	if w.d.Recorder != nil {
+		if err := w.d.Recorder.Start(w.d.Profile.Name); err != nil {
+			log.Println("Cannot start recording:", err)
+		} else {
+			defer w.d.Recorder.Stop()
+		}
 	}
+
+	w.setPhase(PhaseOpenSite)
+	pageLoadStart := time.Now()
+	err := w.services.Selenium.GoTo(w.d.BaseURL)
+	w.report.PageLoad = time.Since(pageLoadStart)
 	if err != nil {
 		return fmt.Errorf("page parse error:%w", err)
 	}
@@ -108,18 +239,27 @@ func (w *Worker) Run() error {
 		return fmt.Errorf("cannot maximize window:%w", err)
 	}
 
-	err = w.LoadCookies()
+	err = w.restoreSession()
 	if err != nil {
-		log.Println("Cookies load error:", err)
+		log.Println("Session restore error:", err)
 	}
 
 	err = w.services.Selenium.GoTo(w.d.BaseURL + w.d.VisaTypeURL)
 	if err != nil {
-		return TooManyRequestsErr{Msg: "go to visa type verification page error"}
+		return fmt.Errorf("go to visa type verification page error:%w", err)
 	}
 
 	isAuthorized, _ := w.services.Selenium.IsAuthorized(w.d.BaseURL + w.d.VisaTypeURL)
+	w.report.AuthorizationNeeded = !isAuthorized
+	if !isAuthorized && w.session != nil {
+		log.Println("Saved session is not valid anymore")
+		if err := w.sessions.Delete(w.proxyKey()); err != nil {
+			log.Println("Cannot delete session:", err)
+		}
+		w.session = nil
+	}
 	if !isAuthorized {
+		w.setPhase(PhaseAuthorization)
 		err := w.handleAuthorization()
 		if err != nil {
 			return fmt.Errorf("authorization error:%w", err)
@@ -129,13 +269,15 @@ func (w *Worker) Run() error {
 	}
 
 	// Solving second captcha
+	w.setPhase(PhaseCaptcha)
 	err = w.handleCaptcha()
 	if err != nil {
 		return fmt.Errorf("second captcha error:%w", err)
 	}
 
 	// Book new appointment
-	err = w.services.Selenium.BookNewAppointment()
+	w.setPhase(PhaseBookNew)
+	err = w.services.Selenium.BookNewAppointment(w.d.Profile.Visa)
 	if err != nil {
 		return fmt.Errorf("book new appointment error:%w", err)
 	}
@@ -144,10 +286,21 @@ func (w *Worker) Run() error {
 	// DEBUG:
 	//time.Sleep(time.Second * 4)
 
+	w.setPhase(PhaseCheckAvailability)
 	isAppointmentAvailable, err := w.services.Selenium.CheckAvailability()
 	if err != nil {
 		return fmt.Errorf("check availability error:%w", err)
 	}
+	w.report.AvailabilityChecked = true
+	w.report.Available = isAppointmentAvailable
+	w.report.VisaCategory = w.d.Profile.Visa.String()
+
+	availabilityGauge := metrics.Availability.WithLabelValues(w.d.Profile.Name)
+	if isAppointmentAvailable {
+		availabilityGauge.Set(1)
+	} else {
+		availabilityGauge.Set(0)
+	}
 
 	if isAppointmentAvailable {
 		log.Println("!!!Appointment available!!!")
@@ -155,24 +308,50 @@ func (w *Worker) Run() error {
 		log.Println("!!!Appointment NOT available!!!")
 	}
 
-	// TEMP: Save page screenshot
+	// Скриншот прикрепляется к уведомлениям и доступен через HTTP API
 	err = w.savePageScreenshot()
 	if err != nil {
 		log.Println("Cannot save page screenshot:%w", err)
 	}
 
-	// TEMP: в качестве проверки правильности определния мест для записи, в любом случае отправляется письм
-	err = w.services.Email.SendAvailbilityNotification(w.d.NotifiedEmail)
-	if err != nil {
-		return fmt.Errorf("send availability notification error:%w", err)
+	if isAppointmentAvailable {
+		w.setPhase(PhaseCollectSlots)
+		w.report.Days, err = w.collectAppointmentDays()
+		if err != nil {
+			// Ошибка чтения календаря не отменяет факт доступности записи
+			log.Println("Cannot collect appointment slots:", err)
+		} else {
+			log.Printf("Available dates: %d, slots: %d\n", len(w.report.Days), service.CountSlots(w.report.Days))
+		}
+		metrics.OpenSlots.WithLabelValues(w.d.Profile.Name).Set(float64(service.CountSlots(w.report.Days)))
+	} else {
+		metrics.OpenSlots.WithLabelValues(w.d.Profile.Name).Set(0)
 	}
-	log.Println("Availability notification sent to ", w.d.NotifiedEmail)
+
+	if isAppointmentAvailable && w.d.Profile.Booking.Enabled {
+		w.setPhase(PhaseBooking)
+		err = w.handleBooking()
+		if err != nil {
+			// Сессия сохраняется и при ошибке бронирования, иначе следующее выполнение потребует авторизации
+			w.SaveSession()
+			return fmt.Errorf("booking error:%w", err)
+		}
+	}
+
+	// Веб-драйвер общий для всех профилей, поэтому сессия сохраняется перед переходом к следующему профилю.
+	// Сохранение продлевает срок действия сессии: сайт завершает ее только после простоя
+	w.SaveSession()
 
 	log.Println("Work done")
 
 	return nil
 }
 
+// LastReport возвращает сводку о последнем выполнении Run
+func (w *Worker) LastReport() RunReport {
+	return w.report
+}
+
 // handleAuthorization обрабатывает авторизацию на сайте.
 // Функция вызывается в случае, если необходимо авторизоваться.
 func (w *Worker) handleAuthorization() error {
@@ -183,7 +362,7 @@ func (w *Worker) handleAuthorization() error {
 
 	log.Println("Retry process first captcha successfully ended")
 
-	err = w.services.Selenium.Authorize()
+	err = w.services.Selenium.Authorize(w.d.Profile.BlsEmail, w.d.Profile.BlsPassword)
 	if err != nil {
 		return fmt.Errorf("authorization error:%w", err)
 	}
@@ -195,7 +374,14 @@ func (w *Worker) handleAuthorization() error {
 		return err
 	}
 
-	w.SaveCookies()
+	isAuthorized, err := w.services.Selenium.IsAuthorized(w.d.BaseURL + w.d.VisaTypeURL)
+	if err == nil && !isAuthorized {
+		return service.LoggedOutError
+	}
+
+	// Новая сессия начинается с этой авторизации
+	w.session = nil
+	w.SaveSession()
 
 	return nil
 }
@@ -220,68 +406,118 @@ func (w *Worker) handleCaptcha() error {
 	return nil
 }
 
-func (w *Worker) LoadCookies() error {
-	cookiesJson, err := os.ReadFile(w.cookieFilePath())
+// restoreSession восстанавливает сохраненную сессию профиля для текущего прокси: куки и localStorage.
+// Сессия, которая скоро истечет, удаляется, чтобы авторизоваться заново до того, как сайт завершит ее
+func (w *Worker) restoreSession() error {
+	w.session = nil
+
+	// Веб-драйвер общий для всех профилей: куки предыдущего профиля удаляются, даже если сессии нет
+	err := w.services.Selenium.DeleteAllCookies()
 	if err != nil {
-		return fmt.Errorf("cannot read cookies:%w", err)
+		return fmt.Errorf("cannot delete all cookies:%w", err)
 	}
 
-	var cookies []selenium.Cookie
-	err = json.Unmarshal(cookiesJson, &cookies)
+	proxyKey := w.proxyKey()
+	s, err := w.sessions.Load(proxyKey)
+	if errors.Is(err, session.NotFoundError) {
+		log.Println("No saved session")
+		return nil
+	}
+	if errors.Is(err, session.ExpiringError) {
+		log.Printf("Session expires at %s, re-authenticating\n", s.ExpiresAt.Format(time.RFC3339))
+		return w.sessions.Delete(proxyKey)
+	}
 	if err != nil {
-		return fmt.Errorf("cannot unmarshal cookies:%w", err)
+		return err
 	}
 
-	err = w.services.Selenium.DeleteAllCookies()
+	err = w.services.Selenium.SetCookies(s.Cookies)
 	if err != nil {
-		return fmt.Errorf("cannot delete all cookies:%w", err)
+		return fmt.Errorf("cannot set cookies:%w", err)
 	}
 
-	err = w.services.Selenium.SetCookies(cookies)
+	err = w.services.Selenium.SetLocalStorage(s.LocalStorage)
 	if err != nil {
-		return fmt.Errorf("cannot set cookies:%w", err)
+		return fmt.Errorf("cannot set local storage:%w", err)
+	}
+
+	err = w.services.Selenium.Refresh()
+	if err != nil {
+		return fmt.Errorf("cannot refresh page:%w", err)
 	}
 
-	return w.services.Selenium.Refresh()
+	w.session = s
+	log.Printf("Session restored, logged in at %s, expires at %s\n",
+		s.LoggedInAt.Format(time.RFC3339), s.ExpiresAt.Format(time.RFC3339))
+
+	return nil
 }
 
-// SaveCookies сохраняет куки в файл. Функция вызывается в defer
-func (w *Worker) SaveCookies() {
-	var cookies []selenium.Cookie
+// ResetSession удаляет сохраненные сессии профиля и куки в браузере,
+// чтобы следующее выполнение Run прошло авторизацию заново
+func (w *Worker) ResetSession() error {
+	w.session = nil
 
-	cookie, err := w.services.Selenium.AuthCookie()
+	err := w.sessions.DeleteAll()
 	if err != nil {
-		log.Println("cannot get auth cookie: ", err)
-		return
+		return err
+	}
+
+	err = w.services.Selenium.DeleteAllCookies()
+	if err != nil {
+		return fmt.Errorf("cannot delete all cookies:%w", err)
 	}
 
-	cookies = append(cookies, cookie)
+	return nil
+}
 
-	cookiesJson, err := json.Marshal(cookies)
+// SaveSession сохраняет все куки и localStorage сайта для текущего прокси. Ошибки записываются в лог
+func (w *Worker) SaveSession() {
+	cookies, err := w.services.Selenium.Cookies()
 	if err != nil {
-		log.Println("Cannot marshal cookies:%w", err)
+		log.Println("Cannot get cookies:", err)
 		return
 	}
 
-	err = util.WriteFile(w.cookieFilePath(), cookiesJson)
+	storage, err := w.services.Selenium.LocalStorage()
 	if err != nil {
-		log.Println("Cannot save cookies:%w", err)
+		log.Println("Cannot get local storage:", err)
 		return
 	}
 
-	log.Println("Cookies saved")
+	loggedInAt := time.Now()
+	if w.session != nil {
+		loggedInAt = w.session.LoggedInAt
+	}
+
+	s, err := w.sessions.Save(w.d.Profile.Name, w.proxyKey(), cookies, storage, loggedInAt)
+	if err != nil {
+		log.Println("Cannot save session:", err)
+		return
+	}
+	w.session = s
+
+	log.Printf("Session saved, expires at %s\n", s.ExpiresAt.Format(time.RFC3339))
+}
+
+// LastScreenshot возвращает последний сохраненный скриншот страницы
+func (w *Worker) LastScreenshot() ([]byte, error) {
+	return os.ReadFile(w.screenshotFilePath())
 }
 
-// savePageScreenshot сохраняет скриншот страницы.
-// Временная функция для отладки
+// ScreenshotPath возвращает путь к последнему сохраненному скриншоту страницы
+func (w *Worker) ScreenshotPath() string {
+	return w.screenshotFilePath()
+}
+
+// savePageScreenshot сохраняет скриншот страницы
 func (w *Worker) savePageScreenshot() error {
-	path := w.d.TmpFolder + w.d.ScreenshotFile
 	data, err := w.services.Selenium.PullPageScreenshot()
 	if err != nil {
 		return fmt.Errorf("cannot pull page screenshot:%w", err)
 	}
 
-	err = util.WriteFile(path, data)
+	err = util.WriteFile(w.screenshotFilePath(), data)
 	if err != nil {
 		return fmt.Errorf("cannot write screenshot:%w", err)
 	}
@@ -289,8 +525,21 @@ func (w *Worker) savePageScreenshot() error {
 	return nil
 }
 
-func (w *Worker) cookieFilePath() string {
-	return w.d.TmpFolder + w.d.CookieFile
+// proxyKey возвращает ключ текущего прокси, для которого сохраняется сессия
+func (w *Worker) proxyKey() string {
+	if w.d.CurrentProxy == nil {
+		return ""
+	}
+
+	proxy := w.d.CurrentProxy()
+	if proxy.IsEmpty() {
+		return ""
+	}
+	return proxy.Key()
+}
+
+func (w *Worker) screenshotFilePath() string {
+	return path.Join(w.d.TmpFolder, w.d.ScreenshotFile)
 }
 
 // LoadProxies загружает прокси из файла
@@ -302,3 +551,13 @@ func LoadProxies(filePath string) (*cfg.ProxiesManager, error) {
 
 	return cfg.ParseProxiesFile(proxiesFile)
 }
+
+// LoadProfiles загружает профили заявителей из файла
+func LoadProfiles(filePath string) ([]cfg.Profile, error) {
+	profilesFile, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read profiles file: %w", err)
+	}
+
+	return cfg.ParseProfilesFile(profilesFile)
+}
@@ -0,0 +1,743 @@
+package worker
+
+import (
+	"bytes"
+	"errors"
+	"github.com/tebeka/selenium"
+	"reflect"
+	"testing"
+	"time"
+	"visasolution/internal/apperr"
+	cfg "visasolution/internal/config"
+	"visasolution/internal/service"
+	"visasolution/internal/service/servicetest"
+	"visasolution/internal/session"
+)
+
+const (
+	testBaseURL     = "https://bls.test/"
+	testVisaTypeURL = "Global/bls/VisaTypeVerification"
+)
+
+var testProxy = cfg.Proxy{Host: "10.0.0.1", Port: "8080"}
+
+var testSessionKey = bytes.Repeat([]byte{1}, session.KeySize)
+
+// testCookies куки авторизованного пользователя
+var testCookies = []selenium.Cookie{
+	{Name: session.AuthCookie, Value: "auth"},
+	{Name: "antiforgery", Value: "token"},
+}
+
+// newTestWorker создает воркер с фейковыми сервисами. По умолчанию пользователь уже авторизован
+func newTestWorker(t *testing.T) (*Worker, *servicetest.Fakes) {
+	t.Helper()
+
+	services, fakes := servicetest.NewService()
+	fakes.Selenium.SetDefault("IsAuthorized", servicetest.Return(true))
+	fakes.Selenium.SetDefault("Cookies", servicetest.Return(testCookies))
+
+	w := NewWorker(services, Deps{
+		BaseURL:         testBaseURL,
+		VisaTypeURL:     testVisaTypeURL,
+		TmpFolder:       t.TempDir(),
+		ScreenshotFile:  "screenshot.png",
+		ExtensionFolder: t.TempDir(),
+		Profile: cfg.Profile{
+			Name:        "test",
+			BlsEmail:    "applicant@example.com",
+			BlsPassword: "password",
+			Visa:        cfg.VisaPreferences{Jurisdiction: "Moscow", VisaType: "Schengen Visa"},
+		},
+		CaptchaMaxTries: 3,
+		CurrentProxy:    func() cfg.Proxy { return testProxy },
+		SessionKey:      testSessionKey,
+	})
+	if err := w.MakePreparation(); err != nil {
+		t.Fatal(err)
+	}
+
+	return w, fakes
+}
+
+// saveTestSession сохраняет сессию воркера для текущего прокси, авторизованную час назад
+func saveTestSession(w *Worker, cookies []selenium.Cookie) *session.Session {
+	s, err := w.sessions.Save("test", testProxy.Key(), cookies, map[string]string{"lang": "ru"}, time.Now().Add(-time.Hour))
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// savedSession загружает сохраненную сессию воркера. nil - сессии нет
+func savedSession(t *testing.T, w *Worker) *session.Session {
+	t.Helper()
+
+	s, err := w.sessions.Load(testProxy.Key())
+	if errors.Is(err, session.NotFoundError) {
+		return nil
+	}
+	if err != nil {
+		t.Fatalf("load session error: %v", err)
+	}
+	return s
+}
+
+// checkErr проверяет класс ошибки (пустая строка - ошибки нет) и, если задано, ошибку в цепочке
+func checkErr(t *testing.T, err error, wantClass string, wantErr error) {
+	t.Helper()
+
+	if class := apperr.ClassOf(err); class != wantClass {
+		t.Errorf("error class = %q, want %q (error: %v)", class, wantClass, err)
+	}
+	if wantErr != nil && !errors.Is(err, wantErr) {
+		t.Errorf("error = %v, want %v in chain", err, wantErr)
+	}
+}
+
+func TestRun(t *testing.T) {
+	nov := time.Date(2026, time.November, 25, 0, 0, 0, 0, time.Local)
+	dec := time.Date(2026, time.December, 3, 0, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		name      string
+		setup     func(w *Worker, f *servicetest.Fakes)
+		wantClass string
+		wantErr   error
+		check     func(t *testing.T, r RunReport, f *servicetest.Fakes)
+	}{
+		{
+			name: "authorized, not available",
+			check: func(t *testing.T, r RunReport, f *servicetest.Fakes) {
+				want := []string{
+					"GoTo", "MaximizeWindow", "DeleteAllCookies", "GoTo", "IsAuthorized",
+					"ClickVerifyBtn", "PullCaptchaImage", "SolveCaptcha",
+					"BookNewAppointment", "CheckAvailability", "PullPageScreenshot", "Cookies", "LocalStorage",
+				}
+				if got := f.Selenium.Methods(); !reflect.DeepEqual(got, want) {
+					t.Errorf("calls = %v, want %v", got, want)
+				}
+				if r.AuthorizationNeeded || !r.AvailabilityChecked || r.Available || r.Days != nil {
+					t.Errorf("unexpected report: %+v", r)
+				}
+				if r.CaptchaSolved != 1 || r.VisaCategory != "Moscow / Schengen Visa" {
+					t.Errorf("unexpected report: %+v", r)
+				}
+			},
+		},
+		{
+			name: "authorization needed",
+			setup: func(w *Worker, f *servicetest.Fakes) {
+				f.Selenium.Queue("IsAuthorized", servicetest.Return(false), servicetest.Return(true))
+			},
+			check: func(t *testing.T, r RunReport, f *servicetest.Fakes) {
+				if !r.AuthorizationNeeded || r.CaptchaSolved != 2 {
+					t.Errorf("unexpected report: %+v", r)
+				}
+				calls := f.Selenium.Calls("Authorize")
+				if len(calls) != 1 || calls[0].Args[0] != "applicant@example.com" || calls[0].Args[1] != "password" {
+					t.Errorf("Authorize calls = %v", calls)
+				}
+			},
+		},
+		{
+			name: "saved session restored",
+			setup: func(w *Worker, f *servicetest.Fakes) {
+				saveTestSession(w, testCookies)
+			},
+			check: func(t *testing.T, r RunReport, f *servicetest.Fakes) {
+				want := []string{"DeleteAllCookies", "SetCookies", "SetLocalStorage", "Refresh"}
+				if got := f.Selenium.Methods()[2:6]; !reflect.DeepEqual(got, want) {
+					t.Errorf("restore calls = %v, want %v", got, want)
+				}
+				if cookies := f.Selenium.Calls("SetCookies")[0].Args[0]; !reflect.DeepEqual(cookies, testCookies) {
+					t.Errorf("SetCookies(%v), want %v", cookies, testCookies)
+				}
+				if storage := f.Selenium.Calls("SetLocalStorage")[0].Args[0]; !reflect.DeepEqual(storage, map[string]string{"lang": "ru"}) {
+					t.Errorf("SetLocalStorage(%v)", storage)
+				}
+				if r.AuthorizationNeeded {
+					t.Error("AuthorizationNeeded = true, want false")
+				}
+			},
+		},
+		{
+			name: "expiring session not restored",
+			setup: func(w *Worker, f *servicetest.Fakes) {
+				// Кука авторизации истекает раньше, чем через SessionRefreshBefore
+				expiring := []selenium.Cookie{{Name: session.AuthCookie, Value: "auth", Expiry: uint(time.Now().Add(time.Minute).Unix())}}
+				saveTestSession(w, expiring)
+				f.Selenium.Queue("IsAuthorized", servicetest.Return(false), servicetest.Return(true))
+			},
+			check: func(t *testing.T, r RunReport, f *servicetest.Fakes) {
+				if f.Selenium.CallCount("SetCookies") != 0 {
+					t.Error("expiring session restored")
+				}
+				if !r.AuthorizationNeeded || f.Selenium.CallCount("Authorize") != 1 {
+					t.Errorf("not re-authenticated: %+v", r)
+				}
+			},
+		},
+		{
+			name: "session encrypted with another key rejected",
+			setup: func(w *Worker, f *servicetest.Fakes) {
+				other, err := session.NewManager(w.d.TmpFolder, bytes.Repeat([]byte{2}, session.KeySize), 0, 0)
+				if err != nil {
+					panic(err)
+				}
+				if _, err := other.Save("test", testProxy.Key(), testCookies, nil, time.Now()); err != nil {
+					panic(err)
+				}
+				f.Selenium.Queue("IsAuthorized", servicetest.Return(false), servicetest.Return(true))
+			},
+			check: func(t *testing.T, r RunReport, f *servicetest.Fakes) {
+				if f.Selenium.CallCount("SetCookies") != 0 {
+					t.Error("session encrypted with another key restored")
+				}
+				if !r.AuthorizationNeeded || f.Selenium.CallCount("Authorize") != 1 {
+					t.Errorf("not re-authenticated: %+v", r)
+				}
+			},
+		},
+		{
+			name: "invalid saved session replaced",
+			setup: func(w *Worker, f *servicetest.Fakes) {
+				saveTestSession(w, testCookies)
+				f.Selenium.Queue("IsAuthorized", servicetest.Return(false), servicetest.Return(true))
+			},
+			check: func(t *testing.T, r RunReport, f *servicetest.Fakes) {
+				if f.Selenium.CallCount("SetCookies") != 1 || f.Selenium.CallCount("Authorize") != 1 {
+					t.Errorf("SetCookies calls = %d, Authorize calls = %d, want 1",
+						f.Selenium.CallCount("SetCookies"), f.Selenium.CallCount("Authorize"))
+				}
+			},
+		},
+		{
+			name: "available, slots collected",
+			setup: func(w *Worker, f *servicetest.Fakes) {
+				f.Selenium.SetDefault("CheckAvailability", servicetest.Return(true))
+				f.Selenium.SetDefault("AvailableDates", servicetest.Return([]time.Time{dec, nov}))
+				f.Selenium.Queue("AvailableSlots",
+					servicetest.Return([]string{"09:00-09:15"}),
+					servicetest.Return([]string{"10:30-10:45", "11:00-11:15"}),
+				)
+			},
+			check: func(t *testing.T, r RunReport, f *servicetest.Fakes) {
+				want := []service.AppointmentDay{
+					{Date: nov, Slots: []string{"09:00-09:15"}},
+					{Date: dec, Slots: []string{"10:30-10:45", "11:00-11:15"}},
+				}
+				if !r.Available || !reflect.DeepEqual(r.Days, want) {
+					t.Errorf("Days = %v, want %v", r.Days, want)
+				}
+				if r.Booking != nil {
+					t.Errorf("Booking = %+v, want nil", r.Booking)
+				}
+			},
+		},
+		{
+			name: "available, calendar error ignored",
+			setup: func(w *Worker, f *servicetest.Fakes) {
+				f.Selenium.SetDefault("CheckAvailability", servicetest.Return(true))
+				f.Selenium.SetDefault("AvailableDates", servicetest.Fail(errors.New("calendar not found")))
+			},
+			check: func(t *testing.T, r RunReport, f *servicetest.Fakes) {
+				if !r.Available || r.Days != nil {
+					t.Errorf("unexpected report: %+v", r)
+				}
+			},
+		},
+		{
+			name: "booking dry run",
+			setup: func(w *Worker, f *servicetest.Fakes) {
+				w.d.Profile.Booking = cfg.BookingPreferences{Enabled: true, DryRun: true}
+				f.Selenium.SetDefault("CheckAvailability", servicetest.Return(true))
+				f.Selenium.SetDefault("AvailableDates", servicetest.Return([]time.Time{nov}))
+				f.Selenium.SetDefault("AvailableSlots", servicetest.Return([]string{"10:30-10:45", "09:00-09:15"}))
+			},
+			check: func(t *testing.T, r RunReport, f *servicetest.Fakes) {
+				if r.Booking == nil || r.Booking.Slot != "09:00-09:15" || !r.Booking.DryRun {
+					t.Fatalf("Booking = %+v", r.Booking)
+				}
+				if f.Selenium.CallCount("ConfirmBooking") != 0 {
+					t.Error("ConfirmBooking called in dry run")
+				}
+			},
+		},
+		{
+			name: "booking without matching slot",
+			setup: func(w *Worker, f *servicetest.Fakes) {
+				w.d.Profile.Booking = cfg.BookingPreferences{Enabled: true, TimeFrom: "12:00"}
+				f.Selenium.SetDefault("CheckAvailability", servicetest.Return(true))
+				f.Selenium.SetDefault("AvailableDates", servicetest.Return([]time.Time{nov}))
+				f.Selenium.SetDefault("AvailableSlots", servicetest.Return([]string{"09:00-09:15"}))
+			},
+			check: func(t *testing.T, r RunReport, f *servicetest.Fakes) {
+				if !r.NoMatchingSlot || r.Booking != nil {
+					t.Errorf("unexpected report: %+v", r)
+				}
+				if f.Selenium.CallCount("SelectSlot") != 0 {
+					t.Error("slot selected without matching slots")
+				}
+			},
+		},
+		{
+			name: "booking confirmed",
+			setup: func(w *Worker, f *servicetest.Fakes) {
+				w.d.Profile.Booking = cfg.BookingPreferences{Enabled: true}
+				f.Selenium.SetDefault("CheckAvailability", servicetest.Return(true))
+				f.Selenium.SetDefault("AvailableDates", servicetest.Return([]time.Time{nov}))
+				f.Selenium.SetDefault("AvailableSlots", servicetest.Return([]string{"09:00-09:15"}))
+				f.Selenium.SetDefault("BookingReference", servicetest.Return("MOW-123"))
+			},
+			check: func(t *testing.T, r RunReport, f *servicetest.Fakes) {
+				if r.Booking == nil || r.Booking.Reference != "MOW-123" || r.Booking.BookedAt.IsZero() {
+					t.Fatalf("Booking = %+v", r.Booking)
+				}
+			},
+		},
+		{
+			name: "already booked",
+			setup: func(w *Worker, f *servicetest.Fakes) {
+				w.d.Profile.Booking = cfg.BookingPreferences{Enabled: true}
+				if err := w.saveMarker(w.bookedPath(), &BookingResult{Date: nov, Slot: "09:00-09:15", Reference: "MOW-123"}); err != nil {
+					panic(err)
+				}
+				f.Selenium.SetDefault("CheckAvailability", servicetest.Return(true))
+				f.Selenium.SetDefault("AvailableDates", servicetest.Return([]time.Time{nov}))
+				f.Selenium.SetDefault("AvailableSlots", servicetest.Return([]string{"09:00-09:15"}))
+			},
+			check: func(t *testing.T, r RunReport, f *servicetest.Fakes) {
+				if !r.AlreadyBooked || r.Booking != nil {
+					t.Errorf("unexpected report: %+v", r)
+				}
+				if f.Selenium.CallCount("SelectSlot") != 0 || f.Selenium.CallCount("ConfirmBooking") != 0 {
+					t.Errorf("booking repeated: %v", f.Selenium.Methods())
+				}
+			},
+		},
+		{
+			name: "booking error",
+			setup: func(w *Worker, f *servicetest.Fakes) {
+				w.d.Profile.Booking = cfg.BookingPreferences{Enabled: true}
+				f.Selenium.SetDefault("CheckAvailability", servicetest.Return(true))
+				f.Selenium.SetDefault("AvailableDates", servicetest.Return([]time.Time{nov}))
+				f.Selenium.SetDefault("AvailableSlots", servicetest.Return([]string{"09:00-09:15"}))
+				f.Selenium.SetDefault("ConfirmBooking", servicetest.Fail(errors.New("confirm button not found")))
+			},
+			wantClass: apperr.ClassUnknown,
+			check: func(t *testing.T, r RunReport, f *servicetest.Fakes) {
+				if r.Booking == nil || !r.Booking.BookedAt.IsZero() {
+					t.Errorf("Booking = %+v", r.Booking)
+				}
+				// Сессия сохранена, несмотря на ошибку
+				if f.Selenium.CallCount("Cookies") != 1 {
+					t.Errorf("session not saved: %v", f.Selenium.Methods())
+				}
+			},
+		},
+		{
+			name: "too many requests on home page",
+			setup: func(w *Worker, f *servicetest.Fakes) {
+				f.Selenium.QueueErr("GoTo", service.TooManyRequestsError)
+			},
+			wantClass: apperr.ClassTooManyRequests,
+			check: func(t *testing.T, r RunReport, f *servicetest.Fakes) {
+				if f.Selenium.CallCount("GoTo") != 1 || r.AvailabilityChecked {
+					t.Errorf("run continued after error: %v", f.Selenium.Methods())
+				}
+			},
+		},
+		{
+			name: "session lost on visa type page",
+			setup: func(w *Worker, f *servicetest.Fakes) {
+				f.Selenium.QueueErr("GoTo", nil, service.InvalidSessionError)
+			},
+			wantClass: apperr.ClassSessionLost,
+			wantErr:   service.InvalidSessionError,
+		},
+		{
+			name: "logged out after authorization",
+			setup: func(w *Worker, f *servicetest.Fakes) {
+				f.Selenium.SetDefault("IsAuthorized", servicetest.Return(false))
+			},
+			wantClass: apperr.ClassLoggedOut,
+			wantErr:   service.LoggedOutError,
+		},
+		{
+			name: "captcha unsolvable",
+			setup: func(w *Worker, f *servicetest.Fakes) {
+				f.Selenium.SetDefault("SolveCaptcha", servicetest.Fail(service.InvalidSelectionError))
+			},
+			wantClass: apperr.ClassCaptchaUnsolvable,
+			check: func(t *testing.T, r RunReport, f *servicetest.Fakes) {
+				if r.CaptchaAttempts != 3 || r.CaptchaInvalid != 3 {
+					t.Errorf("unexpected report: %+v", r)
+				}
+			},
+		},
+		{
+			name: "form layout changed",
+			setup: func(w *Worker, f *servicetest.Fakes) {
+				f.Selenium.SetDefault("BookNewAppointment", servicetest.Fail(apperr.New(apperr.ClassLayoutChanged, "no form controls found")))
+			},
+			wantClass: apperr.ClassLayoutChanged,
+		},
+		{
+			name: "check availability error",
+			setup: func(w *Worker, f *servicetest.Fakes) {
+				f.Selenium.SetDefault("CheckAvailability", servicetest.Fail(errors.New("modal not found")))
+			},
+			wantClass: apperr.ClassUnknown,
+			check: func(t *testing.T, r RunReport, f *servicetest.Fakes) {
+				if r.AvailabilityChecked {
+					t.Error("AvailabilityChecked = true, want false")
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, fakes := newTestWorker(t)
+			if tt.setup != nil {
+				tt.setup(w, fakes)
+			}
+
+			err := w.Run()
+			checkErr(t, err, tt.wantClass, tt.wantErr)
+
+			if w.Phase() != PhaseIdle {
+				t.Errorf("Phase() = %s after Run, want %s", w.Phase(), PhaseIdle)
+			}
+			if tt.check != nil {
+				tt.check(t, w.LastReport(), fakes)
+			}
+		})
+	}
+}
+
+// TestRunDryRunOnce проверяет, что бронирование в режиме DryRun не повторяется для тех же даты и слота
+func TestRunDryRunOnce(t *testing.T) {
+	w, fakes := newTestWorker(t)
+	w.d.Profile.Booking = cfg.BookingPreferences{Enabled: true, DryRun: true}
+	nov := time.Date(2026, time.November, 25, 0, 0, 0, 0, time.Local)
+	fakes.Selenium.SetDefault("CheckAvailability", servicetest.Return(true))
+	fakes.Selenium.SetDefault("AvailableDates", servicetest.Return([]time.Time{nov}))
+	fakes.Selenium.SetDefault("AvailableSlots", servicetest.Return([]string{"09:00-09:15"}))
+
+	for i := 0; i < 2; i++ {
+		if err := w.Run(); err != nil {
+			t.Fatalf("Run() error: %v", err)
+		}
+	}
+
+	if n := fakes.Selenium.CallCount("FillApplicantDetails"); n != 1 {
+		t.Errorf("FillApplicantDetails called %d times, want 1", n)
+	}
+	if r := w.LastReport(); !r.DryRunRepeated || r.Booking != nil {
+		t.Errorf("unexpected report: %+v", r)
+	}
+
+	// Появился более ранний слот, бронирование в режиме DryRun выполняется для него
+	fakes.Selenium.SetDefault("AvailableSlots", servicetest.Return([]string{"08:30-08:45", "09:00-09:15"}))
+	if err := w.Run(); err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+	if r := w.LastReport(); r.DryRunRepeated || r.Booking == nil || r.Booking.Slot != "08:30-08:45" {
+		t.Errorf("unexpected report: %+v", r)
+	}
+	if n := fakes.Selenium.CallCount("ConfirmBooking"); n != 0 {
+		t.Errorf("ConfirmBooking called %d times in dry run", n)
+	}
+	if booked, _ := w.loadBooked(); booked != nil {
+		t.Errorf("booked marker saved in dry run: %+v", booked)
+	}
+}
+
+// TestRunBooksOnce проверяет, что после подтвержденного бронирования следующие выполнения не бронируют запись повторно
+func TestRunBooksOnce(t *testing.T) {
+	w, fakes := newTestWorker(t)
+	w.d.Profile.Booking = cfg.BookingPreferences{Enabled: true}
+	fakes.Selenium.SetDefault("CheckAvailability", servicetest.Return(true))
+	fakes.Selenium.SetDefault("AvailableDates", servicetest.Return([]time.Time{time.Date(2026, time.November, 25, 0, 0, 0, 0, time.Local)}))
+	fakes.Selenium.SetDefault("AvailableSlots", servicetest.Return([]string{"09:00-09:15"}))
+	fakes.Selenium.SetDefault("BookingReference", servicetest.Return("MOW-123"))
+
+	for i := 0; i < 2; i++ {
+		if err := w.Run(); err != nil {
+			t.Fatalf("Run() error: %v", err)
+		}
+	}
+
+	if n := fakes.Selenium.CallCount("ConfirmBooking"); n != 1 {
+		t.Errorf("ConfirmBooking called %d times, want 1", n)
+	}
+	if r := w.LastReport(); !r.AlreadyBooked || r.Booking != nil {
+		t.Errorf("unexpected report: %+v", r)
+	}
+
+	booked, err := w.loadBooked()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if booked == nil || booked.Reference != "MOW-123" || booked.Slot != "09:00-09:15" {
+		t.Errorf("booked marker = %+v", booked)
+	}
+}
+
+func TestRunSavesSession(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(w *Worker, f *servicetest.Fakes)
+		// wantRelogin сессия начата новой авторизацией, а не продолжена
+		wantRelogin bool
+	}{
+		{
+			name: "restored session extended",
+			setup: func(w *Worker, f *servicetest.Fakes) {
+				saveTestSession(w, testCookies)
+			},
+		},
+		{
+			name: "new session after authorization",
+			setup: func(w *Worker, f *servicetest.Fakes) {
+				saveTestSession(w, testCookies)
+				f.Selenium.Queue("IsAuthorized", servicetest.Return(false), servicetest.Return(true))
+			},
+			wantRelogin: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, fakes := newTestWorker(t)
+			tt.setup(w, fakes)
+			before := savedSession(t, w)
+
+			if err := w.Run(); err != nil {
+				t.Fatalf("Run() error: %v", err)
+			}
+
+			after := savedSession(t, w)
+			if after == nil {
+				t.Fatal("session not saved")
+			}
+			if relogin := !after.LoggedInAt.Equal(before.LoggedInAt); relogin != tt.wantRelogin {
+				t.Errorf("logged in at %v, was %v", after.LoggedInAt, before.LoggedInAt)
+			}
+			if !after.ExpiresAt.After(before.ExpiresAt) {
+				t.Errorf("session not extended: expires at %v, was %v", after.ExpiresAt, before.ExpiresAt)
+			}
+			if !reflect.DeepEqual(after.Cookies, testCookies) || after.Profile != "test" || after.Proxy != testProxy.Key() {
+				t.Errorf("unexpected session: %+v", after)
+			}
+		})
+	}
+}
+
+func TestRetryProcessCaptcha(t *testing.T) {
+	solveErr := errors.New("chat api unavailable")
+
+	tests := []struct {
+		name         string
+		setup        func(f *servicetest.Fakes)
+		wantClass    string
+		wantErr      error
+		wantAttempts int
+		wantInvalid  int
+		wantSolves   int
+	}{
+		{
+			name:         "solved on first try",
+			wantAttempts: 1,
+			wantSolves:   1,
+		},
+		{
+			name: "invalid selection, then solved",
+			setup: func(f *servicetest.Fakes) {
+				f.Selenium.QueueErr("SolveCaptcha", service.InvalidSelectionError, service.InvalidSelectionError)
+			},
+			wantAttempts: 3,
+			wantInvalid:  2,
+			wantSolves:   3,
+		},
+		{
+			name: "invalid selection every try",
+			setup: func(f *servicetest.Fakes) {
+				f.Selenium.SetDefault("SolveCaptcha", servicetest.Fail(service.InvalidSelectionError))
+			},
+			wantClass:    apperr.ClassCaptchaUnsolvable,
+			wantAttempts: 3,
+			wantInvalid:  3,
+			wantSolves:   3,
+		},
+		{
+			name: "captcha image not pulled",
+			setup: func(f *servicetest.Fakes) {
+				f.Selenium.QueueErr("PullCaptchaImage", service.InvalidSessionError)
+			},
+			wantClass:    apperr.ClassSessionLost,
+			wantErr:      service.InvalidSessionError,
+			wantAttempts: 1,
+		},
+		{
+			name: "solver error",
+			setup: func(f *servicetest.Fakes) {
+				f.CaptchaSolver.QueueErr("Solve", solveErr)
+			},
+			wantClass:    apperr.ClassUnknown,
+			wantErr:      solveErr,
+			wantAttempts: 1,
+			wantSolves:   1,
+		},
+		{
+			name: "quota exceeded",
+			setup: func(f *servicetest.Fakes) {
+				f.CaptchaSolver.QueueErr("Solve", apperr.New(apperr.ClassQuotaExceeded, "insufficient quota"))
+			},
+			wantClass:    apperr.ClassQuotaExceeded,
+			wantAttempts: 1,
+			wantSolves:   1,
+		},
+		{
+			name: "captcha layout changed",
+			setup: func(f *servicetest.Fakes) {
+				f.Selenium.QueueErr("SolveCaptcha", apperr.New(apperr.ClassLayoutChanged, "card image not found"))
+			},
+			wantClass:    apperr.ClassLayoutChanged,
+			wantAttempts: 1,
+			wantSolves:   1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, fakes := newTestWorker(t)
+			if tt.setup != nil {
+				tt.setup(fakes)
+			}
+
+			err := w.RetryProcessCaptcha(3)
+			checkErr(t, err, tt.wantClass, tt.wantErr)
+
+			r := w.LastReport()
+			if r.CaptchaAttempts != tt.wantAttempts || r.CaptchaInvalid != tt.wantInvalid {
+				t.Errorf("attempts = %d, invalid = %d, want %d, %d", r.CaptchaAttempts, r.CaptchaInvalid, tt.wantAttempts, tt.wantInvalid)
+			}
+			if solves := fakes.CaptchaSolver.CallCount("Solve"); solves != tt.wantSolves {
+				t.Errorf("Solve calls = %d, want %d", solves, tt.wantSolves)
+			}
+			if err == nil && r.CaptchaSolved != 1 {
+				t.Errorf("CaptchaSolved = %d, want 1", r.CaptchaSolved)
+			}
+
+			for _, c := range fakes.Selenium.Calls("SolveCaptcha") {
+				if !reflect.DeepEqual(c.Args[0], []int{1, 5, 9}) {
+					t.Errorf("SolveCaptcha(%v), want solver cards [1 5 9]", c.Args[0])
+				}
+			}
+		})
+	}
+}
+
+func TestHandleAuthorization(t *testing.T) {
+	authErr := errors.New("submit not found")
+
+	tests := []struct {
+		name        string
+		setup       func(f *servicetest.Fakes)
+		wantClass   string
+		wantErr     error
+		wantSaved   bool
+		wantCalls   []string
+		wantNoCalls []string
+	}{
+		{
+			name:      "authorized",
+			wantSaved: true,
+			wantCalls: []string{
+				"ClickVerifyBtn", "PullCaptchaImage", "SolveCaptcha",
+				"Authorize", "GoTo", "IsAuthorized", "Cookies", "LocalStorage",
+			},
+		},
+		{
+			name: "verify button not found",
+			setup: func(f *servicetest.Fakes) {
+				f.Selenium.QueueErr("ClickVerifyBtn", apperr.New(apperr.ClassLayoutChanged, "verify button not found"))
+			},
+			wantClass:   apperr.ClassLayoutChanged,
+			wantNoCalls: []string{"Authorize"},
+		},
+		{
+			name: "captcha unsolvable",
+			setup: func(f *servicetest.Fakes) {
+				f.Selenium.SetDefault("SolveCaptcha", servicetest.Fail(service.InvalidSelectionError))
+			},
+			wantClass:   apperr.ClassCaptchaUnsolvable,
+			wantNoCalls: []string{"Authorize"},
+		},
+		{
+			name: "authorization form error",
+			setup: func(f *servicetest.Fakes) {
+				f.Selenium.QueueErr("Authorize", authErr)
+			},
+			wantClass:   apperr.ClassUnknown,
+			wantErr:     authErr,
+			wantNoCalls: []string{"GoTo"},
+		},
+		{
+			name: "site maintenance after login",
+			setup: func(f *servicetest.Fakes) {
+				f.Selenium.QueueErr("GoTo", service.SiteMaintenanceError)
+			},
+			wantClass:   apperr.ClassSiteMaintenance,
+			wantErr:     service.SiteMaintenanceError,
+			wantNoCalls: []string{"IsAuthorized"},
+		},
+		{
+			name: "not authorized after login",
+			setup: func(f *servicetest.Fakes) {
+				f.Selenium.SetDefault("IsAuthorized", servicetest.Return(false))
+			},
+			wantClass:   apperr.ClassLoggedOut,
+			wantErr:     service.LoggedOutError,
+			wantNoCalls: []string{"Cookies"},
+		},
+		{
+			name: "authorization check error",
+			setup: func(f *servicetest.Fakes) {
+				f.Selenium.QueueErr("IsAuthorized", service.InvalidSessionError)
+			},
+			// Ошибка проверки не считается выходом из аккаунта, сессия сохраняется
+			wantSaved: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, fakes := newTestWorker(t)
+			if tt.setup != nil {
+				tt.setup(fakes)
+			}
+
+			err := w.handleAuthorization()
+			checkErr(t, err, tt.wantClass, tt.wantErr)
+
+			if tt.wantCalls != nil {
+				if got := fakes.Selenium.Methods(); !reflect.DeepEqual(got, tt.wantCalls) {
+					t.Errorf("calls = %v, want %v", got, tt.wantCalls)
+				}
+			}
+			for _, method := range tt.wantNoCalls {
+				if fakes.Selenium.CallCount(method) != 0 {
+					t.Errorf("%s called after error", method)
+				}
+			}
+
+			if saved := savedSession(t, w) != nil; saved != tt.wantSaved {
+				t.Errorf("session saved = %v, want %v", saved, tt.wantSaved)
+			}
+		})
+	}
+}
@@ -4,26 +4,18 @@ import (
 	"archive/zip"
 	"encoding/base64"
 	"fmt"
-	"io/ioutil"
+	"net/http"
 	"os"
 	"path"
+	"path/filepath"
+	"runtime"
 )
 
-// EncodeBase64Image для кодирования изображения в base64
-func EncodeBase64Image(imagePath string) (string, error) {
-	imageFile, err := os.Open(imagePath)
-	if err != nil {
-		return "", err
-	}
-	defer imageFile.Close()
-
-	imageData, err := ioutil.ReadAll(imageFile)
-	if err != nil {
-		return "", err
-	}
-
-	base64Image := base64.StdEncoding.EncodeToString(imageData)
-	return base64Image, nil
+// EncodeBase64Image кодирует изображение в base64 data URL: "data:image/png;base64,...".
+// MIME-тип определяется по содержимому
+func EncodeBase64Image(imageData []byte) string {
+	mimeType := http.DetectContentType(imageData)
+	return fmt.Sprintf("data:%s;base64,%s", mimeType, base64.StdEncoding.EncodeToString(imageData))
 }
 
 func GetAbsolutePath(relativePath string) string {
@@ -42,6 +34,55 @@ func WriteFile(filePath string, data []byte) error {
 	return err
 }
 
+// WriteFileAtomic записывает данные во временный файл в той же папке и переименовывает его в filePath,
+// поэтому при сбое во время записи старое содержимое файла не теряется.
+// Файл и папка синхронизируются с диском, чтобы после сбоя питания не остался пустой или старый файл
+func WriteFileAtomic(filePath string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(filePath), filepath.Base(filePath)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), filePath); err != nil {
+		return err
+	}
+
+	return syncDir(filepath.Dir(filePath))
+}
+
+// syncDir сохраняет на диск запись папки, в том числе переименование файла в ней.
+// В Windows папку нельзя синхронизировать, переименование там сохраняется файловой системой
+func syncDir(dirPath string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+
+	dir, err := os.Open(dirPath)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+
+	return dir.Sync()
+}
+
 // CreateZip создает ZIP-файл с заданными именами файлов и содержимым
 func CreateZip(filenames []string, contents [][]byte, zipPath string) error {
 	// Создаем новый ZIP-файл